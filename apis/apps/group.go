@@ -43,27 +43,28 @@ import (
 const (
 	DefaultNamespace = "kubeflow"
 	// TODO: find the latest tag dynamically
-	DefaultVersion         = "master"
-	KfConfigFile           = "app.yaml"
-	KustomizationFile      = "kustomization.yaml"
-	KustomizationParamFile = "params.env"
-	DefaultCacheDir        = ".cache"
-	KubeflowRepoName       = "kubeflow"
-	ManifestsRepoName      = "manifests"
-	KubeflowRepo           = "kubeflow"
-	ManifestsRepo          = "manifests"
-	DefaultConfigDir       = "bootstrap/config"
-	DefaultZone            = "us-east1-d"
-	DefaultGkeApiVer       = "v1beta1"
-	DefaultAppLabel        = "app.kubernetes.io/name"
-	DefaultAppVersion      = "app.kubernetes.io/version"
-	DefaultAppType         = "kubeflow"
-	KUBEFLOW_USERNAME      = "KUBEFLOW_USERNAME"
-	KUBEFLOW_PASSWORD      = "KUBEFLOW_PASSWORD"
-	DefaultSwaggerFile     = "bootstrap/k8sSpec/v1.11.7/api/openapi-spec/swagger.json"
-	YamlSeparator          = "(?m)^---[ \t]*$"
-	Dns1123LabelFmt        = "[a-z0-9]([-a-z0-9]*[a-z0-9])?"
-	ProfileNameMaxLen      = 30
+	DefaultVersion               = "master"
+	KfConfigFile                 = "app.yaml"
+	KustomizationFile            = "kustomization.yaml"
+	KustomizationParamFile       = "params.env"
+	KustomizationParamSchemaFile = "params.schema.yaml"
+	DefaultCacheDir              = ".cache"
+	KubeflowRepoName             = "kubeflow"
+	ManifestsRepoName            = "manifests"
+	KubeflowRepo                 = "kubeflow"
+	ManifestsRepo                = "manifests"
+	DefaultConfigDir             = "bootstrap/config"
+	DefaultZone                  = "us-east1-d"
+	DefaultGkeApiVer             = "v1beta1"
+	DefaultAppLabel              = "app.kubernetes.io/name"
+	DefaultAppVersion            = "app.kubernetes.io/version"
+	DefaultAppType               = "kubeflow"
+	KUBEFLOW_USERNAME            = "KUBEFLOW_USERNAME"
+	KUBEFLOW_PASSWORD            = "KUBEFLOW_PASSWORD"
+	DefaultSwaggerFile           = "bootstrap/k8sSpec/v1.11.7/api/openapi-spec/swagger.json"
+	YamlSeparator                = "(?m)^---[ \t]*$"
+	Dns1123LabelFmt              = "[a-z0-9]([-a-z0-9]*[a-z0-9])?"
+	ProfileNameMaxLen            = 30
 )
 
 type SupportedResourceType string
@@ -39,6 +39,25 @@ type KfDefSpec struct {
 	Plugins      []Plugin      `json:"plugins,omitempty"`
 	Secrets      []Secret      `json:"secrets,omitempty"`
 	Repos        []Repo        `json:"repos,omitempty"`
+	// Export writes the rendered manifests for a GitOps tool instead of applying them.
+	Export *ExportSpec `json:"export,omitempty"`
+}
+
+// ExportSpec configures where the rendered manifests are exported.
+// Every application is written to its own directory, one sorted YAML file per resource,
+// with an index.yaml listing the files. At least one target must be set.
+type ExportSpec struct {
+	// Path is a directory under the export root of the operator, e.g. a mounted volume, the manifests are
+	// written to. It is relative to the export root, or an absolute path under it.
+	Path string `json:"path,omitempty"`
+	// ConfigMap is the name of a ConfigMap in the KfDef namespace holding the manifests.
+	// Keys are the file paths with "/" replaced by "__".
+	ConfigMap string `json:"configMap,omitempty"`
+	// Archive is a file under the export root of the operator the manifests are written to as an OCI image
+	// layout tarball. It is relative to the export root, or an absolute path under it.
+	Archive string `json:"archive,omitempty"`
+	// OperatorAnnotations adds the annotation the operator uses to track the resources of the KfDef.
+	OperatorAnnotations bool `json:"operatorAnnotations,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -66,12 +85,76 @@ type KfDefList struct {
 type Application struct {
 	Name            string           `json:"name,omitempty"`
 	KustomizeConfig *KustomizeConfig `json:"kustomizeConfig,omitempty"`
+	HelmConfig      *HelmConfig      `json:"helmConfig,omitempty"`
 }
 
 type KustomizeConfig struct {
 	RepoRef    *RepoRef    `json:"repoRef,omitempty"`
 	Overlays   []string    `json:"overlays,omitempty"`
 	Parameters []NameValue `json:"parameters,omitempty"`
+	// PatchesStrategicMerge are inline strategic merge patches applied on top of the selected overlays.
+	PatchesStrategicMerge []string `json:"patchesStrategicMerge,omitempty"`
+	// PatchesJson6902 are inline JSON 6902 patches applied on top of the selected overlays.
+	PatchesJson6902 []JSONPatch `json:"patchesJson6902,omitempty"`
+	// Images overrides container images, like the images field of a kustomization.
+	// An entry replaces any image with the same name set by the manifests.
+	Images []Image `json:"images,omitempty"`
+}
+
+// JSONPatch is an inline JSON 6902 patch and the resource it applies to.
+type JSONPatch struct {
+	Target PatchTarget `json:"target"`
+	// Patch is the list of operations, in YAML or JSON.
+	Patch string `json:"patch"`
+}
+
+// PatchTarget selects the resource a JSONPatch applies to, by its name in the manifests.
+type PatchTarget struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Image overrides the name, tag or digest of a container image.
+type Image struct {
+	// Name is the tag-less image name to match.
+	Name string `json:"name"`
+	// NewName replaces the image name.
+	NewName string `json:"newName,omitempty"`
+	// NewTag replaces the image tag.
+	NewTag string `json:"newTag,omitempty"`
+	// Digest replaces the image tag with a digest. NewTag is ignored when set.
+	Digest string `json:"digest,omitempty"`
+}
+
+// HelmConfig renders an application from a Helm chart instead of a kustomize package.
+// Exactly one of RepoRef or ChartPath must be set.
+type HelmConfig struct {
+	// RepoRef points to a chart inside one of the repos listed in Spec.Repos.
+	RepoRef *RepoRef `json:"repoRef,omitempty"`
+	// ChartPath is a local path to a chart directory or packaged chart (.tgz), relative to or under the
+	// chart root of the operator.
+	ChartPath string `json:"chartPath,omitempty"`
+	// ReleaseName is the name exposed to templates as .Release.Name. Defaults to the application name.
+	ReleaseName string `json:"releaseName,omitempty"`
+	// Namespace the chart is rendered into. Defaults to the KfDef namespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Values are inline values merged over the chart defaults and ValuesFrom.
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Values *runtime.RawExtension `json:"values,omitempty"`
+	// ValuesFrom lists ConfigMaps in the KfDef namespace holding values files.
+	// They are merged in order, before inline Values.
+	ValuesFrom []HelmValuesReference `json:"valuesFrom,omitempty"`
+}
+
+// HelmValuesReference points to a values file stored in a ConfigMap key.
+type HelmValuesReference struct {
+	// Name of the ConfigMap.
+	Name string `json:"name"`
+	// Key holding the values YAML. Defaults to values.yaml.
+	Key string `json:"key,omitempty"`
 }
 
 type RepoRef struct {
@@ -82,6 +165,22 @@ type RepoRef struct {
 type NameValue struct {
 	Name  string `json:"name,omitempty"`
 	Value string `json:"value,omitempty"`
+	// ValueFrom reads the value from a ConfigMap or Secret in the KfDef namespace. Value is ignored when set.
+	// The KfDef is reconciled again when the referenced object changes.
+	ValueFrom *ParameterSource `json:"valueFrom,omitempty"`
+}
+
+// ParameterSource selects the ConfigMap or Secret key holding a parameter value.
+// Exactly one of ConfigMapKeyRef or SecretKeyRef must be set.
+type ParameterSource struct {
+	ConfigMapKeyRef *KeySelector `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *KeySelector `json:"secretKeyRef,omitempty"`
+}
+
+// KeySelector selects a key of a ConfigMap or Secret in the KfDef namespace.
+type KeySelector struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
 }
 
 // Plugin can be used to customize the generation and deployment of Kubeflow
@@ -101,17 +200,43 @@ type Secret struct {
 
 type SecretSource struct {
 	LiteralSource *LiteralSource `json:"literalSource,omitempty"`
-	EnvSource     *EnvSource     `json:"envSource,omitempty"`
+	// HashedSource holds a value that is already hashed, e.g. a password for basic auth.
+	HashedSource *HashedSource `json:"hashedSource,omitempty"`
+	EnvSource    *EnvSource    `json:"envSource,omitempty"`
+	// SecretKeyRef reads the value from a key of a Secret in the KfDef namespace.
+	// The KfDef is reconciled again when the referenced Secret changes.
+	SecretKeyRef *KeySelector `json:"secretKeyRef,omitempty"`
+	// ExternalSource reads the value from an external secrets store through a secret provider of the operator.
+	ExternalSource *ExternalSource `json:"externalSource,omitempty"`
 }
 
 type LiteralSource struct {
 	Value string `json:"value,omitempty"`
 }
 
+type HashedSource struct {
+	HashedValue string `json:"value,omitempty"`
+}
+
 type EnvSource struct {
 	Name string `json:"name,omitempty"`
 }
 
+// ExternalSource selects a secret of an external secrets store.
+type ExternalSource struct {
+	// Provider is the name of the secret provider, "file" for files mounted in the operator pod
+	// or "vault" for a Vault-compatible KV API.
+	Provider string `json:"provider"`
+	// Path of the secret, relative to the secrets of the namespace of the KfDef, e.g. db for the
+	// <namespace>/db directory of the file provider or the <mount>/data/<namespace>/db secret of
+	// the vault provider.
+	Path string `json:"path"`
+	// Key of the value in the secret.
+	Key string `json:"key,omitempty"`
+	// Hashed is true when the value is already hashed, as with HashedSource.
+	Hashed bool `json:"hashed,omitempty"`
+}
+
 // SecretRef is a reference to a secret
 type SecretRef struct {
 	// Name of the secret
@@ -126,7 +251,62 @@ type Repo struct {
 	// URI where repository can be obtained.
 	// Can use any URI understood by go-getter:
 	// https://github.com/hashicorp/go-getter/blob/master/README.md#installation-and-usage
+	// Git repositories are identified by a git:: prefix, a .git suffix, the git or ssh schemes,
+	// or when one of Ref, Tag or Commit is set.
+	// bundled://<name> and bundled://<name>@<version> refer to the manifests bundled in the operator
+	// image or mounted in its bundle directories, fetched without network access.
 	URI string `json:"uri,omitempty"`
+	// Ref is the branch or other named ref of a git repository to fetch.
+	Ref string `json:"ref,omitempty"`
+	// Tag is the tag of a git repository to fetch.
+	Tag string `json:"tag,omitempty"`
+	// Commit is the full or abbreviated id of the commit of a git repository to fetch.
+	// Only one of Ref, Tag and Commit can be set; the default branch is fetched when none is.
+	Commit string `json:"commit,omitempty"`
+	// Sha256 is the hex encoded SHA-256 digest of the tarball at URI. The tarball isn't extracted
+	// when it doesn't match.
+	Sha256 string `json:"sha256,omitempty"`
+	// Signature is a detached signature of the tarball at URI, verified before extraction.
+	Signature *RepoSignature `json:"signature,omitempty"`
+	// AuthSecretRef references a Secret in the namespace of the KfDef holding the credentials used to
+	// fetch the repository, its signature and its config files. The Secret can hold a username and
+	// password, or a token, for HTTP basic or bearer authentication; an ssh-privatekey and the
+	// known_hosts checking the host keys of the servers for git over SSH; a tls.crt and tls.key client certificate; and a ca.crt bundle of
+	// additional certificate authorities.
+	AuthSecretRef *SecretRef `json:"authSecretRef,omitempty"`
+	// Channel makes the repository follow a release channel. The repository is fetched from the URI of
+	// the version of the channel applied, recorded in the status, and URI must not be set.
+	Channel *RepoChannel `json:"channel,omitempty"`
+}
+
+// RepoChannel is a release channel: an index listing the versions of a repository, polled for new versions.
+type RepoChannel struct {
+	// IndexURI is the URI of the index, a YAML or JSON file listing the versions of the repository:
+	//   versions:
+	//   - version: 1.2.0
+	//     uri: https://example.com/manifests-1.2.0.tar.gz
+	//     sha256: <optional hex encoded SHA-256 digest of the tarball>
+	// Relative URIs are resolved against IndexURI.
+	IndexURI string `json:"indexURI"`
+	// Constraint is a semantic version constraint, e.g. ">= 1.2, < 2.0", the versions applied must satisfy.
+	// Any version can be applied when empty.
+	Constraint string `json:"constraint,omitempty"`
+	// Approval is Automatic, the default, to apply the latest version as soon as it's found, or Manual to
+	// apply the version of the approved-version.<repo name> annotation. The latest version is applied when
+	// none is yet.
+	Approval string `json:"approval,omitempty"`
+}
+
+// RepoSignature locates the detached signature of a repository tarball and the public key verifying it.
+type RepoSignature struct {
+	// Type is cosign for a base64 encoded ECDSA signature, as produced by cosign sign-blob,
+	// or gpg for an armored or binary OpenPGP signature.
+	Type string `json:"type"`
+	// URI of the signature. Defaults to the repository URI with a .sig suffix.
+	URI string `json:"uri,omitempty"`
+	// PublicKeySecretRef selects the Secret key holding the PEM encoded public key for cosign,
+	// or the armored public keyring for gpg.
+	PublicKeySecretRef KeySelector `json:"publicKeySecretRef"`
 }
 
 // KfDefStatus defines the observed state of KfDef
@@ -134,11 +314,45 @@ type KfDefStatus struct {
 	Conditions []KfDefCondition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 	// ReposCache is used to cache information about local caching of the URIs.
 	ReposCache []RepoCache `json:"reposCache,omitempty"`
+	// InvalidParameters lists the application parameters rejected by the parameter schema of their application.
+	InvalidParameters []InvalidParameter `json:"invalidParameters,omitempty"`
+	// Channels are the versions of the release channels of the repositories.
+	Channels []RepoChannelStatus `json:"channels,omitempty"`
+}
+
+// RepoChannelStatus is the version applied and the latest version of the release channel of a repository.
+type RepoChannelStatus struct {
+	// Name is the name of the repository.
+	Name string `json:"name"`
+	// Version is the version applied, fetched from URI.
+	Version string `json:"version,omitempty"`
+	URI     string `json:"uri,omitempty"`
+	// Sha256 is the digest of the tarball of the version applied, when listed by the index.
+	Sha256 string `json:"sha256,omitempty"`
+	// LatestVersion is the latest version satisfying the constraint of the channel.
+	LatestVersion string `json:"latestVersion,omitempty"`
+	// LastPollTime is the last time the index was polled.
+	LastPollTime metav1.Time `json:"lastPollTime,omitempty"`
+	// Message is the error of the last poll.
+	Message string `json:"message,omitempty"`
+}
+
+// InvalidParameter is an application parameter that doesn't satisfy the parameter schema of its application.
+type InvalidParameter struct {
+	Application string `json:"application"`
+	Name        string `json:"name"`
+	// Reason is one of Unknown, Invalid or Missing.
+	Reason  string `json:"reason"`
+	Message string `json:"message,omitempty"`
 }
 
 type RepoCache struct {
 	Name      string `json:"name,omitempty"`
 	LocalPath string `json:"localPath,string"`
+	// Commit is the commit a git repository was resolved to.
+	Commit string `json:"commit,omitempty"`
+	// Version is the version of a bundled repository, when its metadata has one.
+	Version string `json:"version,omitempty"`
 }
 
 type KfDefConditionType string
@@ -152,6 +366,12 @@ const (
 
 	// Pending means Kubeflow services is being updated.
 	Pending KfDefConditionType = "Pending"
+
+	// KfParametersInvalid means application parameters don't satisfy the parameter schema of their application.
+	KfParametersInvalid KfDefConditionType = "ParametersInvalid"
+
+	// KfRepoVerificationFailed means a repository doesn't match its checksum or signature.
+	KfRepoVerificationFailed KfDefConditionType = "RepoVerificationFailed"
 )
 
 type KfDefCondition struct {
@@ -254,9 +474,21 @@ func (d *KfDef) GetSecret(name string) (string, error) {
 		if s.SecretSource.LiteralSource != nil {
 			return s.SecretSource.LiteralSource.Value, nil
 		}
+		if s.SecretSource.HashedSource != nil {
+			return s.SecretSource.HashedSource.HashedValue, nil
+		}
 		if s.SecretSource.EnvSource != nil {
 			return os.Getenv(s.SecretSource.EnvSource.Name), nil
 		}
+		if ref := s.SecretSource.SecretKeyRef; ref != nil {
+			return d.readSecretKey(name, ref)
+		}
+		if s.SecretSource.ExternalSource != nil {
+			return "", &kfapis.KfError{
+				Code:    int(kfapis.INVALID_ARGUMENT),
+				Message: fmt.Sprintf("secret %v is read from provider %v by the operator", name, s.SecretSource.ExternalSource.Provider),
+			}
+		}
 
 		return "", fmt.Errorf("No secret source provided for secret %v", name)
 	}
@@ -266,6 +498,32 @@ func (d *KfDef) GetSecret(name string) (string, error) {
 	}
 }
 
+// SecretReader returns the data of the Secret name of namespace. GetSecret reads the secretKeyRef
+// sources with it; the kfconfig package sets it to its own SecretReader.
+var SecretReader func(namespace string, name string) (map[string][]byte, error)
+
+// readSecretKey returns the value of the key of the Secret referenced by the secret name.
+func (d *KfDef) readSecretKey(name string, ref *KeySelector) (string, error) {
+	if SecretReader == nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INTERNAL_ERROR),
+			Message: fmt.Sprintf("secret %v references Secret %v but no Secret reader is configured", name, ref.Name),
+		}
+	}
+	data, err := SecretReader(d.Namespace, ref.Name)
+	value, ok := data[ref.Key]
+	if err == nil && !ok {
+		err = fmt.Errorf("key %v not found in Secret %v", ref.Key, ref.Name)
+	}
+	if err != nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't read secret %v: %v", name, err),
+		}
+	}
+	return string(value), nil
+}
+
 // SetSecret sets the specified secret; if a secret with the given name already exists it is overwritten.
 func (d *KfDef) SetSecret(newSecret Secret) {
 	for i, s := range d.Spec.Secrets {
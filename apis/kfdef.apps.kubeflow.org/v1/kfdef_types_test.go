@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSecret(t *testing.T) {
+	defer func(f func(namespace, name string) (map[string][]byte, error)) { SecretReader = f }(SecretReader)
+	SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		if namespace != "kubeflow" || name != "creds" {
+			return nil, fmt.Errorf("Secret %v/%v not found", namespace, name)
+		}
+		return map[string][]byte{"password": []byte("secret")}, nil
+	}
+
+	d := &KfDef{
+		ObjectMeta: metav1.ObjectMeta{Name: "kfdef", Namespace: "kubeflow"},
+		Spec: KfDefSpec{
+			Secrets: []Secret{
+				{Name: "literal", SecretSource: &SecretSource{LiteralSource: &LiteralSource{Value: "value"}}},
+				{Name: "ref", SecretSource: &SecretSource{SecretKeyRef: &KeySelector{Name: "creds", Key: "password"}}},
+				{Name: "missing-key", SecretSource: &SecretSource{SecretKeyRef: &KeySelector{Name: "creds", Key: "token"}}},
+				{Name: "missing-secret", SecretSource: &SecretSource{SecretKeyRef: &KeySelector{Name: "other", Key: "password"}}},
+			},
+		},
+	}
+
+	type testCase struct {
+		name      string
+		expected  string
+		expectErr bool
+	}
+	testCases := []testCase{
+		{name: "literal", expected: "value"},
+		{name: "ref", expected: "secret"},
+		{name: "missing-key", expectErr: true},
+		{name: "missing-secret", expectErr: true},
+		{name: "unknown", expectErr: true},
+	}
+	for _, c := range testCases {
+		value, err := d.GetSecret(c.name)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("secret %v: expected an error, got value %v", c.name, value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("secret %v: unexpected error: %v", c.name, err)
+			continue
+		}
+		if value != c.expected {
+			t.Errorf("secret %v: expected %v, got %v", c.name, c.expected, value)
+		}
+	}
+
+	SecretReader = nil
+	if _, err := d.GetSecret("ref"); err == nil {
+		t.Errorf("expected an error without a Secret reader")
+	}
+}
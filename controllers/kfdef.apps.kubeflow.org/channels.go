@@ -0,0 +1,81 @@
+package kfdefappskubefloworg
+
+import (
+	"sync"
+	"time"
+
+	kfdefappskubefloworgv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	kfloaders "github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig/loaders"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// defaultChannelPollInterval is how often the release channels of the repositories are polled when
+// KfDefReconciler.ChannelPollInterval isn't set.
+const defaultChannelPollInterval = time.Hour
+
+// appliedKfDefs records the generation of the KfDefs following release channels last applied successfully.
+// The requeues polling their channels only apply them again when a channel moved to a new version, the
+// spec changed, or a resource they deploy or a parameter source changed since.
+var appliedKfDefs = struct {
+	sync.Mutex
+	generations map[types.NamespacedName]int64
+}{generations: map[types.NamespacedName]int64{}}
+
+// setApplied records that the generation of the KfDef key was applied.
+func setApplied(key types.NamespacedName, generation int64) {
+	appliedKfDefs.Lock()
+	defer appliedKfDefs.Unlock()
+	appliedKfDefs.generations[key] = generation
+}
+
+// isApplied returns true if the generation of the KfDef key was applied and nothing changed since.
+func isApplied(key types.NamespacedName, generation int64) bool {
+	appliedKfDefs.Lock()
+	defer appliedKfDefs.Unlock()
+	applied, ok := appliedKfDefs.generations[key]
+	return ok && applied == generation
+}
+
+// forgetApplied makes the next reconcile of the KfDef key apply it again.
+func forgetApplied(key types.NamespacedName) {
+	appliedKfDefs.Lock()
+	defer appliedKfDefs.Unlock()
+	delete(appliedKfDefs.generations, key)
+}
+
+// hasChannels returns true if a repository of kfdef follows a release channel.
+func hasChannels(kfdef *kfdefappskubefloworgv1.KfDef) bool {
+	for _, repo := range kfdef.Spec.Repos {
+		if repo.Channel != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *KfDefReconciler) channelPollInterval() time.Duration {
+	if r.ChannelPollInterval > 0 {
+		return r.ChannelPollInterval
+	}
+	return defaultChannelPollInterval
+}
+
+// pollChannels polls the release channels of the repositories of kfdef that are due and records their
+// versions in its status. It returns true when the version applied of a channel changed.
+func (r *KfDefReconciler) pollChannels(kfdef *kfdefappskubefloworgv1.KfDef) (bool, error) {
+	if !hasChannels(kfdef) {
+		return false, nil
+	}
+	config, err := kfloaders.V1{}.LoadKfConfig(kfdef)
+	if err != nil {
+		return false, err
+	}
+	changed, pollErr := config.PollChannels(r.channelPollInterval())
+
+	polled := &kfdefappskubefloworgv1.KfDef{}
+	if err := (kfloaders.V1{}).LoadKfDef(*config, polled); err != nil {
+		return false, err
+	}
+	kfdef.Status.Channels = polled.Status.Channels
+	return changed, pollErr
+}
@@ -0,0 +1,50 @@
+package kfdefappskubefloworg
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	kfdefv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	kfutils "github.com/opendatahub-io/opendatahub-operator/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestAppliedKfDefs(t *testing.T) {
+	key := types.NamespacedName{Name: "odh", Namespace: "opendatahub"}
+	defer forgetApplied(key)
+
+	if isApplied(key, 1) {
+		t.Errorf("expected a KfDef never applied to be applied again")
+	}
+	setApplied(key, 1)
+	if !isApplied(key, 1) {
+		t.Errorf("expected the requeues of an applied KfDef to only poll the channels")
+	}
+	if isApplied(key, 2) {
+		t.Errorf("expected a KfDef whose spec changed to be applied again")
+	}
+
+	// a change of a deployed resource applies the KfDef again
+	scheme := runtime.NewScheme()
+	kfdefv1.AddToScheme(scheme)
+	r := &KfDefReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&kfdefv1.KfDef{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}).Build(),
+		Log: logr.Discard(),
+	}
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:        "dashboard",
+		Namespace:   key.Namespace,
+		Annotations: map[string]string{kfutils.KfDefAnnotation + "/" + kfutils.KfDefInstance: key.Name + "." + key.Namespace},
+	}}
+	if requests := r.watchKubeflowResources(deployment); len(requests) != 1 || requests[0].NamespacedName != key {
+		t.Errorf("expected a request for the KfDef, got %v", requests)
+	}
+	if isApplied(key, 1) {
+		t.Errorf("expected a KfDef whose resources changed to be applied again")
+	}
+}
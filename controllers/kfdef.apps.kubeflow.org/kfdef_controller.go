@@ -48,6 +48,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 	"strings"
+	"time"
 
 	ofapi "github.com/operator-framework/api/pkg/operators/v1alpha1"
 	olmclientset "github.com/operator-framework/operator-lifecycle-manager/pkg/api/client/clientset/versioned/typed/operators/v1alpha1"
@@ -59,6 +60,7 @@ import (
 	kftypesv3 "github.com/opendatahub-io/opendatahub-operator/apis/apps"
 	kfdefappskubefloworgv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
 	"github.com/opendatahub-io/opendatahub-operator/pkg/kfapp/coordinator"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
 	kfloaders "github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig/loaders"
 	kfutils "github.com/opendatahub-io/opendatahub-operator/pkg/utils"
 )
@@ -91,6 +93,8 @@ type KfDefReconciler struct {
 	Log        logr.Logger
 	// Recorder to generate events
 	Recorder record.EventRecorder
+	// ChannelPollInterval is how often the release channels of the repositories are polled.
+	ChannelPollInterval time.Duration
 }
 
 //+kubebuilder:rbac:groups=*,resources=*,verbs=*
@@ -161,6 +165,8 @@ func (r *KfDefReconciler) Reconcile(ctx context.Context, request ctrl.Request) (
 			return ctrl.Result{}, err
 		}
 		r.Log.Info("kfAppDir deleted.")
+		kfconfig.SharedRepoCache.Release(instance.GetNamespace(), instance.GetName())
+		forgetApplied(request.NamespacedName)
 
 		// Remove this KfDef instance
 		delete(kfdefInstances, strings.Join([]string{instance.GetName(), instance.GetNamespace()}, "."))
@@ -198,8 +204,11 @@ func (r *KfDefReconciler) Reconcile(ctx context.Context, request ctrl.Request) (
 		}
 	}
 
+	// The requeues of a KfDef following release channels that is already applied only poll the channels
+	pollOnly := hasChannels(instance) && isApplied(request.NamespacedName, instance.Generation)
+
 	// If this is a kfdef change, for now, remove the kfapp config path
-	if request.Name == instance.GetName() && request.Namespace == instance.GetNamespace() {
+	if !pollOnly && request.Name == instance.GetName() && request.Namespace == instance.GetNamespace() {
 		kfAppDir := path.Join("/tmp", instance.GetNamespace(), instance.GetName())
 		if err = os.RemoveAll(kfAppDir); err != nil {
 			r.Log.Error(err, "failed to delete the app directory")
@@ -233,7 +242,38 @@ func (r *KfDefReconciler) Reconcile(ctx context.Context, request ctrl.Request) (
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	channelsChanged, err := r.pollChannels(instance)
+	if err != nil {
+		r.Log.Error(err, "failed to poll the release channels", "instance", instance.Name)
+		r.Recorder.Eventf(instance, v1.EventTypeWarning, "ChannelPollFailed",
+			"Error polling the release channels of KF instance %s: %v", instance.Name, err)
+	}
+	if pollOnly && !channelsChanged {
+		// Record the poll, and poll the release channels again when they are due
+		if err := r.reconcileStatus(instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: r.channelPollInterval()}, nil
+	}
+	if channelsChanged {
+		// Fetch the repositories again at the versions applied
+		kfAppDir := path.Join("/tmp", instance.GetNamespace(), instance.GetName())
+		if err = os.RemoveAll(kfAppDir); err != nil {
+			r.Log.Error(err, "failed to delete the app directory")
+			return ctrl.Result{}, err
+		}
+		for _, channel := range instance.Status.Channels {
+			r.Recorder.Eventf(instance, v1.EventTypeNormal, "ChannelVersionApplied",
+				"Repo %s of KF instance %s follows version %s", channel.Name, instance.Name, channel.Version)
+		}
+	}
+
 	err = getReconcileStatus(instance, kfApply(instance))
+	if err == nil && hasChannels(instance) {
+		setApplied(request.NamespacedName, instance.Generation)
+	} else {
+		forgetApplied(request.NamespacedName)
+	}
 	if err == nil {
 		r.Log.Info("KubeFlow Deployment Completed.")
 		r.Recorder.Eventf(instance, v1.EventTypeNormal, "KfDefCreationSuccessful",
@@ -251,7 +291,10 @@ func (r *KfDefReconciler) Reconcile(ctx context.Context, request ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
-	// If deployment created successfully - don't requeue
+	// Poll the release channels again when they are due, otherwise don't requeue
+	if hasChannels(instance) {
+		return ctrl.Result{RequeueAfter: r.channelPollInterval()}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
@@ -262,8 +305,15 @@ func (r *KfDefReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	watchKfdefHandler := handler.EnqueueRequestsFromMapFunc(r.watchKfDef)
 	watchedHandler := handler.EnqueueRequestsFromMapFunc(r.watchKubeflowResources)
+	parameterSourceHandler := handler.EnqueueRequestsFromMapFunc(r.watchParameterSources)
+
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &kfdefappskubefloworgv1.KfDef{},
+		parameterSourcesIndex, indexParameterSources)
+	if err != nil {
+		return err
+	}
 
-	err := ctrl.NewControllerManagedBy(mgr).Named("kfdef-controller").
+	err = ctrl.NewControllerManagedBy(mgr).Named("kfdef-controller").
 		For(&kfdefappskubefloworgv1.KfDef{}).
 		Watches(&source.Kind{Type: &kfdefappskubefloworgv1.KfDef{}}, watchKfdefHandler, builder.WithPredicates(kfdefPredicates)).
 		Watches(&source.Kind{Type: &appsv1.Deployment{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
@@ -282,6 +332,8 @@ func (r *KfDefReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Watches(&source.Kind{Type: &admv1.ValidatingWebhookConfiguration{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
 		Watches(&source.Kind{Type: &v1.Secret{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
 		Watches(&source.Kind{Type: &v1.ConfigMap{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
+		Watches(&source.Kind{Type: &v1.Secret{}}, parameterSourceHandler, builder.WithPredicates(parameterSourcePredicates)).
+		Watches(&source.Kind{Type: &v1.ConfigMap{}}, parameterSourceHandler, builder.WithPredicates(parameterSourcePredicates)).
 		Watches(&source.Kind{Type: &v1.ServiceAccount{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
 		Watches(&source.Kind{Type: &rbacv1.Role{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
 		Watches(&source.Kind{Type: &rbacv1.RoleBinding{}}, watchedHandler, builder.WithPredicates(ownedResourcePredicates)).
@@ -342,6 +394,7 @@ func (r *KfDefReconciler) watchKubeflowResources(a client.Object) (requests []re
 			return nil
 		}
 		r.Log.Info("Watch a change for Kubeflow resource", "instance", a.GetName(), "namespace", a.GetNamespace())
+		forgetApplied(namespacedName)
 		return []reconcile.Request{{NamespacedName: namespacedName}}
 	} else if a.GetObjectKind().GroupVersionKind().Kind == "ConfigMap" {
 		labels := a.GetLabels()
@@ -0,0 +1,114 @@
+package kfdefappskubefloworg
+
+import (
+	"context"
+	"sort"
+
+	kfdefappskubefloworgv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// parameterSourcesIndex indexes KfDefs by the ConfigMaps and Secrets their applications read values from.
+const parameterSourcesIndex = "kfdef.parameterSources"
+
+// parameterSourceKey returns the index key of the object of the given kind and name.
+func parameterSourceKey(kind string, name string) string {
+	return kind + "/" + name
+}
+
+// parameterSourceKeys returns the index keys of the ConfigMaps and Secrets referenced by the parameters
+// and helm values of the applications of kfdef, and by its secrets.
+func parameterSourceKeys(kfdef *kfdefappskubefloworgv1.KfDef) []string {
+	keys := map[string]bool{}
+	for _, app := range kfdef.Spec.Applications {
+		if app.KustomizeConfig != nil {
+			for _, param := range app.KustomizeConfig.Parameters {
+				if param.ValueFrom == nil {
+					continue
+				}
+				if ref := param.ValueFrom.ConfigMapKeyRef; ref != nil {
+					keys[parameterSourceKey("ConfigMap", ref.Name)] = true
+				}
+				if ref := param.ValueFrom.SecretKeyRef; ref != nil {
+					keys[parameterSourceKey("Secret", ref.Name)] = true
+				}
+			}
+		}
+		if app.HelmConfig != nil {
+			for _, ref := range app.HelmConfig.ValuesFrom {
+				keys[parameterSourceKey("ConfigMap", ref.Name)] = true
+			}
+		}
+	}
+	for _, secret := range kfdef.Spec.Secrets {
+		if secret.SecretSource != nil && secret.SecretSource.SecretKeyRef != nil {
+			keys[parameterSourceKey("Secret", secret.SecretSource.SecretKeyRef.Name)] = true
+		}
+	}
+	result := make([]string, 0, len(keys))
+	for key := range keys {
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// indexParameterSources is the indexer function of parameterSourcesIndex.
+func indexParameterSources(obj client.Object) []string {
+	kfdef, ok := obj.(*kfdefappskubefloworgv1.KfDef)
+	if !ok {
+		return nil
+	}
+	return parameterSourceKeys(kfdef)
+}
+
+// watchParameterSources requeues the KfDefs reading parameter values from the changed ConfigMap or Secret.
+func (r *KfDefReconciler) watchParameterSources(a client.Object) (requests []reconcile.Request) {
+	var kind string
+	switch a.(type) {
+	case *v1.ConfigMap:
+		kind = "ConfigMap"
+	case *v1.Secret:
+		kind = "Secret"
+	default:
+		return nil
+	}
+	kfdefs := &kfdefappskubefloworgv1.KfDefList{}
+	err := r.Client.List(context.TODO(), kfdefs, client.InNamespace(a.GetNamespace()),
+		client.MatchingFields{parameterSourcesIndex: parameterSourceKey(kind, a.GetName())})
+	if err != nil {
+		r.Log.Error(err, "Failed to list KfDefs referencing parameter source", "name", a.GetName(), "namespace", a.GetNamespace())
+		return nil
+	}
+	for _, kfdef := range kfdefs.Items {
+		if kfdef.GetDeletionTimestamp() != nil {
+			continue
+		}
+		r.Log.Info("Watch a change for parameter source", "kind", kind, "name", a.GetName(), "instance", kfdef.Name)
+		namespacedName := types.NamespacedName{Name: kfdef.Name, Namespace: kfdef.Namespace}
+		forgetApplied(namespacedName)
+		requests = append(requests, reconcile.Request{NamespacedName: namespacedName})
+	}
+	return requests
+}
+
+// parameterSourcePredicates ignore resyncs and status-only updates of parameter sources.
+var parameterSourcePredicates = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return true
+	},
+	GenericFunc: func(_ event.GenericEvent) bool {
+		return false
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		return true
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectOld.GetResourceVersion() != e.ObjectNew.GetResourceVersion()
+	},
+}
@@ -0,0 +1,53 @@
+package kfdefappskubefloworg
+
+import (
+	"reflect"
+	"testing"
+
+	kfdefv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+)
+
+func TestParameterSourceKeys(t *testing.T) {
+	kfdef := &kfdefv1.KfDef{
+		Spec: kfdefv1.KfDefSpec{
+			Applications: []kfdefv1.Application{
+				{
+					Name: "dashboard",
+					KustomizeConfig: &kfdefv1.KustomizeConfig{
+						Parameters: []kfdefv1.NameValue{
+							{Name: "literal", Value: "1"},
+							{Name: "host", ValueFrom: &kfdefv1.ParameterSource{
+								ConfigMapKeyRef: &kfdefv1.KeySelector{Name: "cluster-config", Key: "host"},
+							}},
+							{Name: "token", ValueFrom: &kfdefv1.ParameterSource{
+								SecretKeyRef: &kfdefv1.KeySelector{Name: "credentials", Key: "token"},
+							}},
+						},
+					},
+				},
+				{
+					Name: "chart",
+					HelmConfig: &kfdefv1.HelmConfig{
+						ValuesFrom: []kfdefv1.HelmValuesReference{{Name: "cluster-config"}, {Name: "chart-values"}},
+					},
+				},
+				{
+					Name:            "no-params",
+					KustomizeConfig: &kfdefv1.KustomizeConfig{},
+				},
+			},
+			Secrets: []kfdefv1.Secret{
+				{Name: "literal", SecretSource: &kfdefv1.SecretSource{LiteralSource: &kfdefv1.LiteralSource{Value: "1"}}},
+				{Name: "password", SecretSource: &kfdefv1.SecretSource{
+					SecretKeyRef: &kfdefv1.KeySelector{Name: "basic-auth", Key: "password"},
+				}},
+			},
+		},
+	}
+
+	expected := []string{"ConfigMap/chart-values", "ConfigMap/cluster-config", "Secret/basic-auth", "Secret/credentials"}
+	actual := parameterSourceKeys(kfdef)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("parameterSourceKeys; expect %v, got %v", expected, actual)
+	}
+}
@@ -2,14 +2,21 @@ package kfdefappskubefloworg
 
 import (
 	"context"
+	"errors"
 	"reflect"
 
 	kfdefv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfapp/kustomize"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
-const DeploymentCompleted string = "Kubeflow Deployment completed"
+const (
+	DeploymentCompleted string = "Kubeflow Deployment completed"
+	InvalidParameters   string = "InvalidParameters"
+	RepoVerification    string = "RepoVerificationFailed"
+)
 
 // The setKfDefStatus method accepts a custom resource of type KfDef type
 // It retrieves the current stored version of the resource and compares the
@@ -40,6 +47,37 @@ func (r *KfDefReconciler) reconcileStatus(cr *kfdefv1.KfDef) error {
 func getReconcileStatus(cr *kfdefv1.KfDef, err error) error {
 	conditions := []kfdefv1.KfDefCondition{}
 
+	cr.Status.InvalidParameters = nil
+	var paramErr *kustomize.ParameterError
+	if errors.As(err, &paramErr) {
+		for _, issue := range paramErr.Issues {
+			cr.Status.InvalidParameters = append(cr.Status.InvalidParameters, kfdefv1.InvalidParameter{
+				Application: issue.Application,
+				Name:        issue.Name,
+				Reason:      issue.Reason,
+				Message:     issue.Message,
+			})
+		}
+		conditions = append(conditions, kfdefv1.KfDefCondition{
+			LastUpdateTime: cr.CreationTimestamp,
+			Status:         corev1.ConditionTrue,
+			Reason:         InvalidParameters,
+			Message:        paramErr.Error(),
+			Type:           kfdefv1.KfParametersInvalid,
+		})
+	}
+
+	var verifyErr *kfconfig.RepoVerificationError
+	if errors.As(err, &verifyErr) {
+		conditions = append(conditions, kfdefv1.KfDefCondition{
+			LastUpdateTime: cr.CreationTimestamp,
+			Status:         corev1.ConditionTrue,
+			Reason:         RepoVerification,
+			Message:        verifyErr.Error(),
+			Type:           kfdefv1.KfRepoVerificationFailed,
+		})
+	}
+
 	if err != nil {
 		conditions = append(conditions, kfdefv1.KfDefCondition{
 			LastUpdateTime: cr.CreationTimestamp,
@@ -0,0 +1,69 @@
+package kfdefappskubefloworg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	kfdefv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfapp/kustomize"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+)
+
+func TestGetReconcileStatus(t *testing.T) {
+	paramErr := &kustomize.ParameterError{
+		Issues: []kustomize.ParameterIssue{
+			{Application: "dashboard", Name: "replica", Reason: kustomize.UnknownParameterReason, Message: "parameter is not defined by the application"},
+		},
+	}
+	cases := map[string]struct {
+		err               error
+		conditionTypes    []kfdefv1.KfDefConditionType
+		invalidParameters []kfdefv1.InvalidParameter
+	}{
+		"Deployment succeeded": {
+			conditionTypes: []kfdefv1.KfDefConditionType{kfdefv1.KfAvailable},
+		},
+		"Deployment failed": {
+			err:            errors.New("apply failed"),
+			conditionTypes: []kfdefv1.KfDefConditionType{kfdefv1.KfDegraded, kfdefv1.KfAvailable},
+		},
+		"Parameters are invalid": {
+			err:            fmt.Errorf("couldn't generate KfApp: %w", paramErr),
+			conditionTypes: []kfdefv1.KfDefConditionType{kfdefv1.KfParametersInvalid, kfdefv1.KfDegraded, kfdefv1.KfAvailable},
+			invalidParameters: []kfdefv1.InvalidParameter{
+				{Application: "dashboard", Name: "replica", Reason: "Unknown", Message: "parameter is not defined by the application"},
+			},
+		},
+		"Repo verification failed": {
+			err:            fmt.Errorf("couldn't generate KfApp: %w", &kfconfig.RepoVerificationError{Repo: "manifests", Message: "invalid signature"}),
+			conditionTypes: []kfdefv1.KfDefConditionType{kfdefv1.KfRepoVerificationFailed, kfdefv1.KfDegraded, kfdefv1.KfAvailable},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cr := &kfdefv1.KfDef{}
+			cr.Status.InvalidParameters = []kfdefv1.InvalidParameter{{Name: "stale"}}
+			if err := getReconcileStatus(cr, tc.err); err != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+			if len(cr.Status.Conditions) != len(tc.conditionTypes) {
+				t.Fatalf("expected conditions %v, got %v", tc.conditionTypes, cr.Status.Conditions)
+			}
+			for i, condType := range tc.conditionTypes {
+				if cr.Status.Conditions[i].Type != condType {
+					t.Errorf("expected condition %v at %v, got %v", condType, i, cr.Status.Conditions[i].Type)
+				}
+			}
+			if len(cr.Status.InvalidParameters) != len(tc.invalidParameters) {
+				t.Fatalf("expected invalid parameters %v, got %v", tc.invalidParameters, cr.Status.InvalidParameters)
+			}
+			for i, p := range tc.invalidParameters {
+				if cr.Status.InvalidParameters[i] != p {
+					t.Errorf("expected invalid parameter %v, got %v", p, cr.Status.InvalidParameters[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package secretgenerator
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+
+	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// OAUTH2_PROXY_SECRET_SUFFIX is appended to the name of the source secret to name the secret holding
+	// the oauth2-proxy credentials of an ingress
+	OAUTH2_PROXY_SECRET_SUFFIX = "-oauth2-proxy"
+	// OAUTH2_PROXY_CALLBACK_PATH is the path of the redirect URL of oauth2-proxy
+	OAUTH2_PROXY_CALLBACK_PATH = "/oauth2/callback"
+
+	// The keys of the oauth2-proxy secret, as read by its Helm chart
+	OAUTH2_PROXY_CLIENT_ID_KEY     = "client-id"
+	OAUTH2_PROXY_CLIENT_SECRET_KEY = "client-secret"
+	OAUTH2_PROXY_COOKIE_SECRET_KEY = "cookie-secret"
+	OAUTH2_PROXY_REDIRECT_URL_KEY  = "redirect-url"
+)
+
+// ingressPredicates watch the creation and deletion of ingresses, and the updates changing their host.
+var ingressPredicates = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldIngress, okOld := e.ObjectOld.(*netv1.Ingress)
+		newIngress, okNew := e.ObjectNew.(*netv1.Ingress)
+		return !okOld || !okNew || firstIngressHost(oldIngress) != firstIngressHost(newIngress)
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// firstIngressHost returns the host of the first rule of the ingress that sets one.
+func firstIngressHost(ingress *netv1.Ingress) string {
+	for _, rule := range ingress.Spec.Rules {
+		if rule.Host != "" {
+			return rule.Host
+		}
+	}
+	return ""
+}
+
+// ingressHost returns the host of the ingress, or an empty string when it doesn't exist or has no host.
+func (r *SecretGeneratorReconciler) ingressHost(namespace string, name string) (string, error) {
+	ingress := &netv1.Ingress{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, ingress)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return firstIngressHost(ingress), nil
+}
+
+// reconcileOAuth2ProxySecret creates or updates the secret holding the OAuth client credentials of the
+// source secret in the format of oauth2-proxy: the name of the source secret as client id, the generated
+// value as client secret, a cookie secret generated once, and the redirect URL of host. The secret is
+// owned by the source secret, an existing secret of the same name it doesn't control is a conflict.
+func (r *SecretGeneratorReconciler) reconcileOAuth2ProxySecret(foundSecret *v1.Secret, host string,
+	clientSecret string) error {
+	data := map[string][]byte{
+		OAUTH2_PROXY_CLIENT_ID_KEY:     []byte(foundSecret.Name),
+		OAUTH2_PROXY_CLIENT_SECRET_KEY: []byte(clientSecret),
+		OAUTH2_PROXY_REDIRECT_URL_KEY:  []byte("https://" + host + OAUTH2_PROXY_CALLBACK_PATH),
+	}
+
+	proxySecret := &v1.Secret{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: foundSecret.Name + OAUTH2_PROXY_SECRET_SUFFIX,
+		Namespace: foundSecret.Namespace}, proxySecret)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+		if data[OAUTH2_PROXY_COOKIE_SECRET_KEY], err = newCookieSecret(); err != nil {
+			return err
+		}
+		secGenLog.Info("Generating the oauth2-proxy secret for host", "host", host)
+		proxySecret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      foundSecret.Name + OAUTH2_PROXY_SECRET_SUFFIX,
+				Namespace: foundSecret.Namespace,
+				Labels:    foundSecret.Labels,
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(foundSecret, foundSecret.GroupVersionKind()),
+				},
+			},
+			Data: data,
+		}
+		return r.Client.Create(context.TODO(), proxySecret)
+	}
+
+	// Never take over a secret of the same name the source secret doesn't control
+	if !metav1.IsControlledBy(proxySecret, foundSecret) {
+		return withReason(reasonOAuthClientConflict,
+			fmt.Errorf("oauth2-proxy secret %v exists and wasn't generated for secret %v", proxySecret.Name, foundSecret.Name))
+	}
+	if cookieSecret, found := proxySecret.Data[OAUTH2_PROXY_COOKIE_SECRET_KEY]; found {
+		data[OAUTH2_PROXY_COOKIE_SECRET_KEY] = cookieSecret
+	} else if data[OAUTH2_PROXY_COOKIE_SECRET_KEY], err = newCookieSecret(); err != nil {
+		return err
+	}
+	if reflect.DeepEqual(proxySecret.Data, data) {
+		return nil
+	}
+	secGenLog.Info("Updating the oauth2-proxy secret for host", "host", host)
+	proxySecret.Data = data
+	return r.Client.Update(context.TODO(), proxySecret)
+}
+
+// newCookieSecret returns a random cookie secret of 32 bytes, one of the sizes oauth2-proxy requires.
+func newCookieSecret() ([]byte, error) {
+	cookieSecret := make([]byte, 32)
+	if _, err := rand.Read(cookieSecret); err != nil {
+		return nil, err
+	}
+	return []byte(base64.URLEncoding.EncodeToString(cookieSecret)), nil
+}
+
+// apiAvailable returns true if the cluster serves the resource of the group version.
+func apiAvailable(dc discovery.DiscoveryInterface, gv schema.GroupVersion, resource string) (bool, error) {
+	resources, err := dc.ServerResourcesForGroupVersion(gv.String())
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,151 @@
+package secretgenerator
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	routev1 "github.com/openshift/api/route/v1"
+	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileOAuth2ProxySecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "opendatahub", UID: "source-uid"}}
+	source.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+	ingress := func(host string) *netv1.Ingress {
+		return &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "opendatahub"},
+			Spec:       netv1.IngressSpec{Rules: []netv1.IngressRule{{Host: host}}},
+		}
+	}
+	cases := map[string]struct {
+		objects     []client.Object
+		pending     bool
+		conflict    bool
+		redirectURL string
+	}{
+		"Ingress doesn't exist": {
+			pending: true,
+		},
+		"Ingress has no host": {
+			objects: []client.Object{ingress("")},
+			pending: true,
+		},
+		"oauth2-proxy secret doesn't exist": {
+			objects:     []client.Object{ingress("dashboard.example.com")},
+			redirectURL: "https://dashboard.example.com/oauth2/callback",
+		},
+		"oauth2-proxy secret is out of date": {
+			objects: []client.Object{
+				ingress("dashboard.example.com"),
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            "example-oauth2-proxy",
+						Namespace:       "opendatahub",
+						OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(source, source.GroupVersionKind())},
+					},
+					Data: map[string][]byte{
+						OAUTH2_PROXY_CLIENT_SECRET_KEY: []byte("old"),
+						OAUTH2_PROXY_COOKIE_SECRET_KEY: []byte("cookie"),
+						OAUTH2_PROXY_REDIRECT_URL_KEY:  []byte("https://old.example.com/oauth2/callback"),
+					},
+				},
+			},
+			redirectURL: "https://dashboard.example.com/oauth2/callback",
+		},
+		"oauth2-proxy secret wasn't generated for the source secret": {
+			objects: []client.Object{
+				ingress("dashboard.example.com"),
+				&v1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "example-oauth2-proxy", Namespace: "opendatahub"},
+					Data:       map[string][]byte{OAUTH2_PROXY_CLIENT_SECRET_KEY: []byte("user")},
+				},
+			},
+			conflict: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &SecretGeneratorReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build(),
+				Scheme: scheme,
+			}
+			secret := &Secret{Name: "secret", OAuthClientIngress: "dashboard"}
+			err := r.reconcileOAuth(source, secret, "secret", nil)
+			if tc.conflict {
+				var reconcileErr *reconcileError
+				if !errors.As(err, &reconcileErr) || reconcileErr.reason != reasonOAuthClientConflict {
+					t.Errorf("Expected an oauth client conflict, got: %v\n", err)
+				}
+				proxySecret := &v1.Secret{}
+				if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: "example-oauth2-proxy",
+					Namespace: "opendatahub"}, proxySecret); err != nil || string(proxySecret.Data[OAUTH2_PROXY_CLIENT_SECRET_KEY]) != "user" {
+					t.Errorf("Expected the oauth2-proxy secret to be unchanged, got: %v (%v)\n", proxySecret.Data, err)
+				}
+				return
+			}
+			if pending := isPending(err); pending != tc.pending {
+				t.Errorf("Expected pending: %v, got: %v\n", tc.pending, err)
+			} else if err != nil && !pending {
+				t.Fatalf("Failed to reconcile the oauth2-proxy secret: %v", err)
+			}
+
+			proxySecret := &v1.Secret{}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "example-oauth2-proxy",
+				Namespace: "opendatahub"}, proxySecret)
+			if tc.redirectURL == "" {
+				if err == nil {
+					t.Errorf("Expected no oauth2-proxy secret, got: %v\n", proxySecret.Data)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected an oauth2-proxy secret: %v", err)
+			}
+			if string(proxySecret.Data[OAUTH2_PROXY_CLIENT_ID_KEY]) != "example" ||
+				string(proxySecret.Data[OAUTH2_PROXY_CLIENT_SECRET_KEY]) != "secret" {
+				t.Errorf("Expected the client credentials, got: %v\n", proxySecret.Data)
+			}
+			if string(proxySecret.Data[OAUTH2_PROXY_REDIRECT_URL_KEY]) != tc.redirectURL {
+				t.Errorf("Expected redirect url: %v, got: %s\n", tc.redirectURL,
+					proxySecret.Data[OAUTH2_PROXY_REDIRECT_URL_KEY])
+			}
+			cookieSecret := proxySecret.Data[OAUTH2_PROXY_COOKIE_SECRET_KEY]
+			if decoded, err := base64.URLEncoding.DecodeString(string(cookieSecret)); string(cookieSecret) != "cookie" &&
+				(err != nil || len(decoded) != 32) {
+				t.Errorf("Expected the cookie secret to be kept or of 32 bytes, got: %s\n", cookieSecret)
+			}
+		})
+	}
+}
+
+func TestAPIAvailable(t *testing.T) {
+	dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	dc.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: routev1.SchemeGroupVersion.String(),
+			APIResources: []metav1.APIResource{{Name: "routes"}},
+		},
+	}
+
+	if available, err := apiAvailable(dc, routev1.SchemeGroupVersion, "routes"); err != nil || !available {
+		t.Errorf("Expected the routes to be available, got: %v, %v\n", available, err)
+	}
+	if available, err := apiAvailable(dc, routev1.SchemeGroupVersion, "routes/status"); err != nil || available {
+		t.Errorf("Expected the resource not to be available, got: %v, %v\n", available, err)
+	}
+}
@@ -0,0 +1,190 @@
+package secretgenerator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"text/template"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	// SECRET_KEYS_ANNOTATION holds the YAML list of the keys of a multi-key generated secret, e.g.
+	//
+	//	- name: user
+	//	  value: odh
+	//	- name: password
+	//	  type: random
+	//	  complexity: 24
+	//	- name: uri
+	//	  template: postgresql://{{ .user }}:{{ .password | urlquery }}@postgresql:5432/odh
+	//
+	// The name annotation selects the key linked to the OAuthClient, the first key by default.
+	SECRET_KEYS_ANNOTATION = "secret-generator.opendatahub.io/keys"
+
+	secretAnnotationPrefix = "secret-generator.opendatahub.io/"
+	// multiKeyType is the type of the secrets of the keys annotation
+	multiKeyType = "multi"
+
+	errInvalidKeys       = "keys annotation is not a valid list of keys"
+	errKeyNameNotFound   = "key name not found in keys annotation"
+	errDuplicateKey      = "key is defined twice in keys annotation"
+	errInvalidKey        = "key must set exactly one of type, value and template"
+	errUnknownKeyOption  = "key option is not supported"
+	errPrimaryKeyMissing = "name annotation doesn't select a key of keys annotation"
+)
+
+// KeySpec describes a key of a multi-key generated secret: a value generated like the secrets of the
+// type annotation, a literal value, or a value derived from the previous keys by a Go template.
+type KeySpec struct {
+	Name       string `json:"name"`
+	Type       string `json:"type,omitempty"`
+	Complexity int    `json:"complexity,omitempty"`
+	// Options are the annotations of the type without the secret-generator.opendatahub.io/ prefix,
+	// e.g. charset or tls-sans
+	Options  map[string]string `json:"options,omitempty"`
+	Value    string            `json:"value,omitempty"`
+	Template string            `json:"template,omitempty"`
+}
+
+// keyOptions are the annotations that can be set as options of a key.
+var keyOptions = map[string]bool{
+	SECRET_CHARSET_ANNOTATION:       true,
+	SECRET_HTPASSWD_USER_ANNOTATION: true,
+	SECRET_KEY_FORMAT_ANNOTATION:    true,
+	SECRET_TLS_SANS_ANNOTATION:      true,
+	SECRET_TLS_VALIDITY_ANNOTATION:  true,
+}
+
+// templateFuncs are the functions of the templates of the derived keys, in addition to the builtin ones
+// like urlquery.
+var templateFuncs = template.FuncMap{
+	"base64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+}
+
+// parseKeySpecs returns the keys of the keys annotation, and the name of the key selected by the name annotation,
+// the first key by default.
+func parseKeySpecs(annotations map[string]string, keys string) ([]KeySpec, string, error) {
+	var specs []KeySpec
+	if err := yaml.Unmarshal([]byte(keys), &specs); err != nil || len(specs) == 0 {
+		return nil, "", errors.New(errInvalidKeys)
+	}
+
+	names := map[string]bool{}
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, "", errors.New(errKeyNameNotFound)
+		}
+		if names[spec.Name] {
+			return nil, "", errors.New(errDuplicateKey)
+		}
+		names[spec.Name] = true
+		set := 0
+		for _, s := range []string{spec.Type, spec.Value, spec.Template} {
+			if s != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, "", errors.New(errInvalidKey)
+		}
+		for option := range spec.Options {
+			if !keyOptions[secretAnnotationPrefix+option] {
+				return nil, "", errors.New(errUnknownKeyOption)
+			}
+		}
+		if spec.Type != "" {
+			// Check the type and complexity of the generated keys before generating any of them
+			if _, err := parseSecret(keyAnnotations(spec)); err != nil {
+				return nil, "", fmt.Errorf("key %v: %v", spec.Name, err)
+			}
+		}
+	}
+
+	primary := specs[0].Name
+	if name, found := annotations[SECRET_NAME_ANNOTATION]; found {
+		primary = name
+	}
+	return specs, primary, nil
+}
+
+// newMultiKeySecret generates the keys of the keys annotation in order. The value of the secret is the
+// key selected by the name annotation, the other keys are its data.
+func newMultiKeySecret(annotations map[string]string, keys string) (*Secret, error) {
+	specs, primary, err := parseKeySpecs(annotations, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for _, spec := range specs {
+		if _, found := values[spec.Name]; found {
+			return nil, errors.New(errDuplicateKey)
+		}
+		switch {
+		case spec.Value != "":
+			values[spec.Name] = spec.Value
+		case spec.Template != "":
+			value, err := renderKeyTemplate(spec, values)
+			if err != nil {
+				return nil, err
+			}
+			values[spec.Name] = value
+		default:
+			key, err := newSecret(keyAnnotations(spec))
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %v", spec.Name, err)
+			}
+			for k, v := range key.values() {
+				values[k] = v
+			}
+		}
+	}
+
+	value, found := values[primary]
+	if !found {
+		return nil, errors.New(errPrimaryKeyMissing)
+	}
+	delete(values, primary)
+	return &Secret{
+		Name:       primary,
+		Type:       multiKeyType,
+		Complexity: SECRET_DEFAULT_COMPLEXITY,
+		Value:      value,
+		Data:       values,
+	}, nil
+}
+
+// keyAnnotations returns the annotations of a secret of the type, complexity and options of spec.
+func keyAnnotations(spec KeySpec) map[string]string {
+	annotations := map[string]string{
+		SECRET_NAME_ANNOTATION: spec.Name,
+		SECRET_TYPE_ANNOTATION: spec.Type,
+	}
+	if spec.Complexity != 0 {
+		annotations[SECRET_LENGTH_ANNOTATION] = strconv.Itoa(spec.Complexity)
+	}
+	for option, value := range spec.Options {
+		annotations[secretAnnotationPrefix+option] = value
+	}
+	return annotations
+}
+
+// renderKeyTemplate returns the value of the template of spec, which references the previous keys by
+// name, e.g. {{ .user }}, or {{ index . "key.pub" }} for names that aren't identifiers.
+func renderKeyTemplate(spec KeySpec, values map[string]string) (string, error) {
+	tmpl, err := template.New(spec.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(spec.Template)
+	if err != nil {
+		return "", fmt.Errorf("key %v: invalid template: %v", spec.Name, err)
+	}
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, values); err != nil {
+		return "", fmt.Errorf("key %v: %v", spec.Name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,122 @@
+package secretgenerator
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestNewMultiKeySecret(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		err         error
+		check       func(t *testing.T, secret *Secret)
+	}{
+		"Generate a database secret": {
+			annotations: map[string]string{
+				SECRET_KEYS_ANNOTATION: `
+- name: user
+  value: odh
+- name: password
+  type: charset
+  complexity: 24
+  options:
+    charset: "ab:@/"
+- name: uri
+  template: postgresql://{{ .user }}:{{ .password | urlquery }}@postgresql:5432/odh
+`,
+			},
+			check: func(t *testing.T, secret *Secret) {
+				values := secret.values()
+				if secret.Name != "user" || secret.Value != "odh" || len(values) != 3 {
+					t.Fatalf("Expected the user key and 2 other keys, got: %v\n", values)
+				}
+				if len(values["password"]) != 24 {
+					t.Errorf("Expected a password of 24 characters, got: %v\n", values["password"])
+				}
+				u, err := url.Parse(values["uri"])
+				if err != nil {
+					t.Fatalf("Invalid uri: %v\n", err)
+				}
+				if password, _ := u.User.Password(); u.User.Username() != "odh" || password != values["password"] {
+					t.Errorf("Expected the uri to hold the user and password, got: %v\n", values["uri"])
+				}
+			},
+		},
+		"Select the primary key and derive keys of key pairs": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION: "key",
+				SECRET_KEYS_ANNOTATION: `
+- name: key
+  type: ed25519
+  options:
+    key-format: ssh
+- name: authorized_keys
+  template: '{{ index . "key.pub" }}'
+- name: encoded
+  template: '{{ .key | base64 }}'
+`,
+			},
+			check: func(t *testing.T, secret *Secret) {
+				if secret.Name != "key" || !strings.Contains(secret.Value, "OPENSSH PRIVATE KEY") {
+					t.Fatalf("Expected the private key to be the value, got: %v\n", secret.Name)
+				}
+				if secret.Data["authorized_keys"] != secret.Data["key.pub"] || secret.Data["encoded"] == "" {
+					t.Errorf("Expected the derived keys, got: %v\n", secret.Data)
+				}
+			},
+		},
+		"Keys annotation is invalid": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "user: odh"},
+			err:         errors.New(errInvalidKeys),
+		},
+		"Key name is not defined": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "- type: uuid"},
+			err:         errors.New(errKeyNameNotFound),
+		},
+		"Key is defined twice": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "[{name: a, type: uuid}, {name: a, type: hex}]"},
+			err:         errors.New(errDuplicateKey),
+		},
+		"Key sets a type and a value": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "[{name: a, type: uuid, value: b}]"},
+			err:         errors.New(errInvalidKey),
+		},
+		"Key option is not supported": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "[{name: a, type: uuid, options: {keys: '[]'}}]"},
+			err:         errors.New(errUnknownKeyOption),
+		},
+		"Key type is not supported": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "[{name: a, type: ssh}]"},
+			err:         errors.New("key a: " + errUnsupportedType),
+		},
+		"Template references a later key": {
+			annotations: map[string]string{SECRET_KEYS_ANNOTATION: "[{name: a, template: '{{ .b }}'}, {name: b, type: uuid}]"},
+			err:         errors.New(`key a: template: a:1:3: executing "a" at <.b>: map has no entry for key "b"`),
+		},
+		"Name annotation doesn't select a key": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION: "password",
+				SECRET_KEYS_ANNOTATION: "[{name: a, type: uuid}]",
+			},
+			err: errors.New(errPrimaryKeyMissing),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			secret, err := newSecret(tc.annotations)
+			if tc.err != nil {
+				if err == nil || err.Error() != tc.err.Error() {
+					t.Errorf("Expected error: %v, got: %v\n", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v\n", err)
+			}
+			tc.check(t, secret)
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package secretgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	ocv1 "github.com/openshift/api/oauth/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION records on the OAuthClient the namespace/name of the source
+	// secret it was generated for
+	SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION = "secret-generator.opendatahub.io/source"
+
+	errRouteAPIUnavailable = "oauth client route requires the OpenShift route and oauth client APIs"
+)
+
+// routePredicates watch the creation and deletion of routes, and the updates changing their host.
+var routePredicates = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldRoute, okOld := e.ObjectOld.(*routev1.Route)
+		newRoute, okNew := e.ObjectNew.(*routev1.Route)
+		return !okOld || !okNew || oldRoute.Spec.Host != newRoute.Spec.Host
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// oauthClientPredicates watch the OAuthClients generated for a source secret, to repair them when
+// they're modified or deleted.
+var oauthClientPredicates = predicate.Funcs{
+	CreateFunc: func(e event.CreateEvent) bool {
+		return false
+	},
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		_, found := e.ObjectNew.GetAnnotations()[SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION]
+		return found
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		_, found := e.Object.GetAnnotations()[SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION]
+		return found
+	},
+	GenericFunc: func(e event.GenericEvent) bool {
+		return false
+	},
+}
+
+// secretsReferencing returns a function mapping a route or an ingress to the requests of the source
+// secrets of its namespace whose annotation names it.
+func (r *SecretGeneratorReconciler) secretsReferencing(annotation string) handler.MapFunc {
+	return func(object client.Object) []reconcile.Request {
+		secrets := &v1.SecretList{}
+		if err := r.Client.List(context.TODO(), secrets, client.InNamespace(object.GetNamespace())); err != nil {
+			secGenLog.Error(err, "error listing the secrets of a namespace", "namespace", object.GetNamespace())
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, secret := range secrets.Items {
+			if secret.Annotations[annotation] == object.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{
+					Name: secret.Name, Namespace: secret.Namespace}})
+			}
+		}
+		return requests
+	}
+}
+
+// secretForOAuthClient returns the request of the source secret the OAuthClient was generated for.
+func secretForOAuthClient(oauthClient client.Object) []reconcile.Request {
+	source := oauthClient.GetAnnotations()[SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION]
+	namespace, name, found := strings.Cut(source, "/")
+	if !found {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+// oauthClientSecrets returns the value of the generated secret the OAuthClient authenticates with,
+// and the previous value it accepts during the grace period of a rotation.
+func oauthClientSecrets(generatedSecret *v1.Secret, secret *Secret) (string, []string) {
+	var additionalSecrets []string
+	if previous, found := generatedSecret.Data[secret.Name+SECRET_PREVIOUS_KEY_SUFFIX]; found {
+		additionalSecrets = []string{string(previous)}
+	}
+	return string(generatedSecret.Data[secret.Name]), additionalSecrets
+}
+
+// reconcileOAuth creates or updates the OAuthClient of the source secret, with the given secrets and a
+// redirect URI to the host of its route or ingress, and the oauth2-proxy credentials of an ingress. The
+// reconcile is pending while the route or ingress doesn't exist or has no host yet.
+func (r *SecretGeneratorReconciler) reconcileOAuth(foundSecret *v1.Secret, secret *Secret, clientSecret string,
+	additionalSecrets []string) error {
+	var host string
+	var err error
+	redirectKind, redirectName := "route", secret.OAuthClientRoute
+	if secret.OAuthClientRoute != "" {
+		if !r.routeAPI || !r.oauthClientAPI {
+			return errors.New(errRouteAPIUnavailable)
+		}
+		host, err = r.routeHost(foundSecret.Namespace, secret.OAuthClientRoute)
+	} else {
+		redirectKind, redirectName = "ingress", secret.OAuthClientIngress
+		host, err = r.ingressHost(foundSecret.Namespace, secret.OAuthClientIngress)
+	}
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return withReason(reasonOAuthRedirectPending,
+			fmt.Errorf("%v %v has no host yet", redirectKind, redirectName))
+	}
+
+	if r.oauthClientAPI {
+		if err := r.reconcileOAuthClient(foundSecret, host, clientSecret, additionalSecrets); err != nil {
+			return err
+		}
+	}
+	if secret.OAuthClientIngress != "" {
+		return r.reconcileOAuth2ProxySecret(foundSecret, host, clientSecret)
+	}
+	return nil
+}
+
+// routeHost returns the host of the route, or an empty string when it doesn't exist or has no host yet.
+func (r *SecretGeneratorReconciler) routeHost(namespace string, name string) (string, error) {
+	route := &routev1.Route{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, route)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return route.Spec.Host, nil
+}
+
+// reconcileOAuthClient creates or updates the OAuthClient of the source secret, with the given secrets
+// and a redirect URI to host. An existing OAuthClient of the same name that wasn't generated for the
+// source secret is left alone and reported as a conflict.
+func (r *SecretGeneratorReconciler) reconcileOAuthClient(foundSecret *v1.Secret, host string, secret string,
+	additionalSecrets []string) error {
+	redirectURIs := []string{"https://" + host}
+	source := foundSecret.Namespace + "/" + foundSecret.Name
+	oauthClient := &ocv1.OAuthClient{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: foundSecret.Name}, oauthClient)
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+		secGenLog.Info("Generating an oauth client resource for host", "host", host)
+		oauthClient = &ocv1.OAuthClient{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        foundSecret.Name,
+				Annotations: map[string]string{SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION: source},
+			},
+			Secret:            secret,
+			AdditionalSecrets: additionalSecrets,
+			RedirectURIs:      redirectURIs,
+			GrantMethod:       ocv1.GrantHandlerAuto,
+		}
+		return r.Client.Create(context.TODO(), oauthClient)
+	}
+
+	// Never take over an OAuthClient of the platform, or of a secret of the same name in another namespace
+	if oauthClient.Annotations[SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION] != source {
+		return withReason(reasonOAuthClientConflict,
+			fmt.Errorf("oauth client %v exists and wasn't generated for secret %v", oauthClient.Name, source))
+	}
+	if oauthClient.Secret == secret && reflect.DeepEqual(oauthClient.AdditionalSecrets, additionalSecrets) &&
+		reflect.DeepEqual(oauthClient.RedirectURIs, redirectURIs) {
+		return nil
+	}
+	secGenLog.Info("Updating the oauth client resource for host", "host", host)
+	oauthClient.Secret = secret
+	oauthClient.AdditionalSecrets = additionalSecrets
+	oauthClient.RedirectURIs = redirectURIs
+	return r.Client.Update(context.TODO(), oauthClient)
+}
+
+// earliestResult merges the results of the reconciliation steps, requeuing at the earliest.
+func earliestResult(a ctrl.Result, b ctrl.Result) ctrl.Result {
+	if a.RequeueAfter == 0 || (b.RequeueAfter != 0 && b.RequeueAfter < a.RequeueAfter) {
+		a.RequeueAfter = b.RequeueAfter
+	}
+	a.Requeue = a.Requeue || b.Requeue
+	return a
+}
@@ -0,0 +1,247 @@
+package secretgenerator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	ocv1 "github.com/openshift/api/oauth/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestReconcileOAuthClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	ocv1.AddToScheme(scheme)
+	routev1.AddToScheme(scheme)
+
+	source := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "opendatahub"}}
+	route := func(host string) *routev1.Route {
+		return &routev1.Route{
+			ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "opendatahub"},
+			Spec:       routev1.RouteSpec{Host: host},
+		}
+	}
+	cases := map[string]struct {
+		objects         []client.Object
+		noOpenShiftAPIs bool
+		err             error
+		pending         bool
+		expectedClient  bool
+		expectedSecrets []string
+	}{
+		"Route doesn't exist": {
+			pending: true,
+		},
+		"Route has no host": {
+			objects: []client.Object{route("")},
+			pending: true,
+		},
+		"OAuthClient doesn't exist": {
+			objects:        []client.Object{route("dashboard.apps.cluster")},
+			expectedClient: true,
+		},
+		"OAuthClient is out of date": {
+			objects: []client.Object{
+				route("dashboard.apps.cluster"),
+				&ocv1.OAuthClient{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "example",
+						Annotations: map[string]string{SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION: "opendatahub/example"},
+					},
+					Secret:       "old",
+					RedirectURIs: []string{"https://dashboard.apps.old-cluster"},
+				},
+			},
+			expectedClient: true,
+		},
+		"OAuthClient wasn't generated": {
+			objects: []client.Object{
+				route("dashboard.apps.cluster"),
+				&ocv1.OAuthClient{ObjectMeta: metav1.ObjectMeta{Name: "example"}, Secret: "platform"},
+			},
+			err: errors.New("oauth client example exists and wasn't generated for secret opendatahub/example"),
+		},
+		"OAuthClient belongs to a secret of another namespace": {
+			objects: []client.Object{
+				route("dashboard.apps.cluster"),
+				&ocv1.OAuthClient{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "example",
+						Annotations: map[string]string{SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION: "other/example"},
+					},
+					Secret: "platform",
+				},
+			},
+			err: errors.New("oauth client example exists and wasn't generated for secret opendatahub/example"),
+		},
+		"OpenShift APIs are not available": {
+			objects:         []client.Object{route("dashboard.apps.cluster")},
+			noOpenShiftAPIs: true,
+			err:             errors.New(errRouteAPIUnavailable),
+		},
+		"OAuthClient accepts the previous secret": {
+			objects:         []client.Object{route("dashboard.apps.cluster")},
+			expectedClient:  true,
+			expectedSecrets: []string{"old"},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &SecretGeneratorReconciler{
+				Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.objects...).Build(),
+				Scheme:         scheme,
+				routeAPI:       !tc.noOpenShiftAPIs,
+				oauthClientAPI: !tc.noOpenShiftAPIs,
+			}
+			secret := &Secret{Name: "secret", OAuthClientRoute: "dashboard"}
+			err := r.reconcileOAuth(source, secret, "secret", tc.expectedSecrets)
+			if tc.err != nil {
+				if err == nil || err.Error() != tc.err.Error() {
+					t.Errorf("Expected error: %v, got: %v\n", tc.err, err)
+				}
+				oauthClient := &ocv1.OAuthClient{}
+				r.Client.Get(context.TODO(), types.NamespacedName{Name: "example"}, oauthClient)
+				if oauthClient.Secret != "" && oauthClient.Secret != "platform" {
+					t.Errorf("Expected the oauth client to be left alone, got: %v\n", oauthClient.Secret)
+				}
+				return
+			}
+			if pending := isPending(err); pending != tc.pending {
+				t.Errorf("Expected pending: %v, got: %v\n", tc.pending, err)
+			} else if err != nil && !pending {
+				t.Fatalf("Failed to reconcile the oauth client: %v", err)
+			}
+
+			oauthClient := &ocv1.OAuthClient{}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "example"}, oauthClient)
+			if !tc.expectedClient {
+				if err == nil {
+					t.Errorf("Expected no oauth client, got: %v\n", oauthClient)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected an oauth client: %v", err)
+			}
+			if oauthClient.Secret != "secret" || !reflect.DeepEqual(oauthClient.AdditionalSecrets, tc.expectedSecrets) {
+				t.Errorf("Expected the secrets of the generated secret, got: %v and %v\n", oauthClient.Secret,
+					oauthClient.AdditionalSecrets)
+			}
+			if !reflect.DeepEqual(oauthClient.RedirectURIs, []string{"https://dashboard.apps.cluster"}) {
+				t.Errorf("Expected a redirect to the route host, got: %v\n", oauthClient.RedirectURIs)
+			}
+			if oauthClient.Annotations[SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION] != "opendatahub/example" {
+				t.Errorf("Expected the source secret to be recorded, got: %v\n", oauthClient.Annotations)
+			}
+		})
+	}
+}
+
+func TestDeleteOAuthClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	ocv1.AddToScheme(scheme)
+
+	oauthClient := func(source string) *ocv1.OAuthClient {
+		return &ocv1.OAuthClient{ObjectMeta: metav1.ObjectMeta{
+			Name:        "example",
+			Annotations: map[string]string{SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION: source},
+		}}
+	}
+	cases := map[string]struct {
+		oauthClient     *ocv1.OAuthClient
+		deleted         bool
+		noOpenShiftAPIs bool
+	}{
+		"OAuthClient was generated for the secret": {
+			oauthClient: oauthClient("opendatahub/example"),
+			deleted:     true,
+		},
+		"OAuthClient belongs to a secret of another namespace": {
+			oauthClient: oauthClient("other/example"),
+		},
+		"OAuthClient wasn't generated": {
+			oauthClient: &ocv1.OAuthClient{ObjectMeta: metav1.ObjectMeta{Name: "example"}},
+		},
+		"Cluster doesn't serve the OAuthClient API": {
+			noOpenShiftAPIs: true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if tc.noOpenShiftAPIs {
+				// The kind of OAuthClient isn't known to a vanilla Kubernetes cluster
+				vanillaScheme := runtime.NewScheme()
+				clientgoscheme.AddToScheme(vanillaScheme)
+				r := &SecretGeneratorReconciler{
+					Client: fake.NewClientBuilder().WithScheme(vanillaScheme).Build(),
+					Scheme: vanillaScheme,
+				}
+				if err := r.deleteOAuthClient(types.NamespacedName{Name: "example", Namespace: "opendatahub"}); err != nil {
+					t.Errorf("Expected no error without the OAuthClient API, got: %v\n", err)
+				}
+				return
+			}
+			r := &SecretGeneratorReconciler{
+				Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(tc.oauthClient).Build(),
+				Scheme:         scheme,
+				oauthClientAPI: true,
+			}
+			err := r.deleteOAuthClient(types.NamespacedName{Name: "example", Namespace: "opendatahub"})
+			if err != nil {
+				t.Fatalf("Failed to delete the oauth client: %v", err)
+			}
+			err = r.Client.Get(context.TODO(), types.NamespacedName{Name: "example"}, &ocv1.OAuthClient{})
+			if deleted := err != nil; deleted != tc.deleted {
+				t.Errorf("Expected deleted: %v, got: %v\n", tc.deleted, err)
+			}
+		})
+	}
+}
+
+func TestSecretsReferencing(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+
+	secret := func(name string, namespace string, route string) *v1.Secret {
+		return &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{SECRET_OAUTH_CLIENT_ANNOTATION: route},
+		}}
+	}
+	r := &SecretGeneratorReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			secret("dashboard-oauth", "opendatahub", "dashboard"),
+			secret("notebooks-oauth", "opendatahub", "notebooks"),
+			secret("dashboard-oauth", "other", "dashboard"),
+		).Build(),
+		Scheme: scheme,
+	}
+
+	route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: "dashboard", Namespace: "opendatahub"}}
+	expected := []reconcile.Request{{NamespacedName: types.NamespacedName{
+		Name: "dashboard-oauth", Namespace: "opendatahub"}}}
+	if requests := r.secretsReferencing(SECRET_OAUTH_CLIENT_ANNOTATION)(route); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected the secrets referencing the route, got: %v\n", requests)
+	}
+
+	oauthClient := &ocv1.OAuthClient{ObjectMeta: metav1.ObjectMeta{
+		Name:        "dashboard-oauth",
+		Annotations: map[string]string{SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION: "opendatahub/dashboard-oauth"},
+	}}
+	if requests := secretForOAuthClient(oauthClient); !reflect.DeepEqual(requests, expected) {
+		t.Errorf("Expected the source secret of the oauth client, got: %v\n", requests)
+	}
+}
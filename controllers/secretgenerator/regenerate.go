@@ -0,0 +1,106 @@
+package secretgenerator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// SECRET_SPEC_HASH_ANNOTATION records on the generated secret the hash of the annotations of the
+	// source secret its values were generated from
+	SECRET_SPEC_HASH_ANNOTATION = "secret-generator.opendatahub.io/spec-hash"
+	// SECRET_REGENERATE_ANNOTATION regenerates the values of the generated secret when set on the source
+	// secret, and is removed once they are
+	SECRET_REGENERATE_ANNOTATION = "secret-generator.opendatahub.io/regenerate"
+)
+
+// specAnnotations are the annotations of the source secret the generated values depend on.
+var specAnnotations = []string{
+	SECRET_NAME_ANNOTATION,
+	SECRET_TYPE_ANNOTATION,
+	SECRET_LENGTH_ANNOTATION,
+	SECRET_KEYS_ANNOTATION,
+	SECRET_CHARSET_ANNOTATION,
+	SECRET_HTPASSWD_USER_ANNOTATION,
+	SECRET_KEY_FORMAT_ANNOTATION,
+	SECRET_TLS_SANS_ANNOTATION,
+	SECRET_TLS_VALIDITY_ANNOTATION,
+}
+
+// specHash returns the hash of the annotations the generated values depend on. The OAuthClient route and
+// the rotation annotations are read at every reconcile and don't change the values.
+func specHash(annotations map[string]string) string {
+	keys := make([]string, 0, len(specAnnotations))
+	for _, key := range specAnnotations {
+		if _, found := annotations[key]; found {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(annotations[key]))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// updateGeneratedSecret regenerates the values of the generated secret when the annotations they depend
+// on changed, or when the regenerate annotation is set, generating the values of secret, and patches its
+// labels otherwise. A generated
+// secret without a hash, created by a previous version of the controller, is adopted as is. It returns
+// true when the values were regenerated.
+func (r *SecretGeneratorReconciler) updateGeneratedSecret(foundSecret *v1.Secret, generatedSecret *v1.Secret,
+	secret *Secret) (bool, error) {
+	hash := specHash(foundSecret.GetAnnotations())
+	previousHash, hashFound := generatedSecret.Annotations[SECRET_SPEC_HASH_ANNOTATION]
+	_, regenerateNow := foundSecret.GetAnnotations()[SECRET_REGENERATE_ANNOTATION]
+	regenerate := regenerateNow || (hashFound && previousHash != hash)
+	if !regenerate && hashFound && reflect.DeepEqual(generatedSecret.Labels, foundSecret.Labels) {
+		return false, nil
+	}
+
+	if generatedSecret.Annotations == nil {
+		generatedSecret.Annotations = map[string]string{}
+	}
+	generatedSecret.Annotations[SECRET_SPEC_HASH_ANNOTATION] = hash
+	generatedSecret.Labels = foundSecret.Labels
+	if regenerate {
+		secGenLog.Info("Regenerating the values of a generated secret", "secret", generatedSecret.Name,
+			"namespace", generatedSecret.Namespace)
+		if err := secret.generate(foundSecret.GetAnnotations()); err != nil {
+			return false, withReason(reasonInvalidAnnotations, err)
+		}
+		generatedSecret.Data = map[string][]byte{}
+		for key, value := range secret.values() {
+			generatedSecret.Data[key] = []byte(value)
+		}
+		if secret.RotationInterval > 0 {
+			generatedSecret.Annotations[SECRET_LAST_ROTATION_ANNOTATION] = r.now().UTC().Format(time.RFC3339)
+		} else {
+			delete(generatedSecret.Annotations, SECRET_LAST_ROTATION_ANNOTATION)
+		}
+	}
+	if err := r.Client.Update(context.TODO(), generatedSecret); err != nil {
+		return false, err
+	}
+	if !regenerate {
+		return false, nil
+	}
+
+	if regenerateNow {
+		delete(foundSecret.Annotations, SECRET_REGENERATE_ANNOTATION)
+		if err := r.Client.Update(context.TODO(), foundSecret); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,122 @@
+package secretgenerator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileUpdate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+
+	annotations := map[string]string{
+		SECRET_NAME_ANNOTATION: "password",
+		SECRET_TYPE_ANNOTATION: "random",
+	}
+	withAnnotations := func(extra map[string]string) map[string]string {
+		a := map[string]string{}
+		for k, v := range annotations {
+			a[k] = v
+		}
+		for k, v := range extra {
+			a[k] = v
+		}
+		return a
+	}
+	cases := map[string]struct {
+		annotations          map[string]string
+		labels               map[string]string
+		generatedAnnotations map[string]string
+		regenerated          bool
+	}{
+		"Annotations are unchanged": {
+			annotations:          annotations,
+			generatedAnnotations: map[string]string{SECRET_SPEC_HASH_ANNOTATION: specHash(annotations)},
+		},
+		"Complexity changed": {
+			annotations:          withAnnotations(map[string]string{SECRET_LENGTH_ANNOTATION: "32"}),
+			generatedAnnotations: map[string]string{SECRET_SPEC_HASH_ANNOTATION: specHash(annotations)},
+			regenerated:          true,
+		},
+		"Rotation interval changed": {
+			annotations: withAnnotations(map[string]string{SECRET_ROTATION_INTERVAL_ANNOTATION: "720h"}),
+			generatedAnnotations: map[string]string{
+				SECRET_SPEC_HASH_ANNOTATION:     specHash(annotations),
+				SECRET_LAST_ROTATION_ANNOTATION: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		"Labels changed": {
+			annotations:          annotations,
+			labels:               map[string]string{"app": "example"},
+			generatedAnnotations: map[string]string{SECRET_SPEC_HASH_ANNOTATION: specHash(annotations)},
+		},
+		"Regenerate annotation is set": {
+			annotations:          withAnnotations(map[string]string{SECRET_REGENERATE_ANNOTATION: "true"}),
+			generatedAnnotations: map[string]string{SECRET_SPEC_HASH_ANNOTATION: specHash(annotations)},
+			regenerated:          true,
+		},
+		"Generated secret has no hash": {
+			annotations: withAnnotations(map[string]string{SECRET_LENGTH_ANNOTATION: "32"}),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "example",
+					Namespace:   "opendatahub",
+					Annotations: tc.annotations,
+					Labels:      tc.labels,
+				},
+			}
+			generated := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "example-generated",
+					Namespace:   "opendatahub",
+					Annotations: tc.generatedAnnotations,
+				},
+				Data: map[string][]byte{"password": []byte("old")},
+			}
+			r := &SecretGeneratorReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(source, generated).Build(),
+				Scheme: scheme,
+			}
+			_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{
+				Name: "example", Namespace: "opendatahub"}})
+			if err != nil {
+				t.Fatalf("Failed to reconcile: %v", err)
+			}
+
+			generated = &v1.Secret{}
+			source = &v1.Secret{}
+			r.Client.Get(context.TODO(), types.NamespacedName{Name: "example-generated", Namespace: "opendatahub"}, generated)
+			r.Client.Get(context.TODO(), types.NamespacedName{Name: "example", Namespace: "opendatahub"}, source)
+			value := string(generated.Data["password"])
+			if regenerated := value != "old"; regenerated != tc.regenerated {
+				t.Errorf("Expected regenerated: %v, got value: %v\n", tc.regenerated, value)
+			}
+			if tc.regenerated && len(value) != 32 && len(value) != SECRET_DEFAULT_COMPLEXITY {
+				t.Errorf("Expected a value of the complexity of the annotations, got: %v\n", value)
+			}
+			if generated.Annotations[SECRET_SPEC_HASH_ANNOTATION] != specHash(source.Annotations) {
+				t.Errorf("Expected the hash of the annotations to be recorded, got: %v\n", generated.Annotations)
+			}
+			if generated.Labels["app"] != tc.labels["app"] {
+				t.Errorf("Expected the labels of the source secret, got: %v\n", generated.Labels)
+			}
+			if _, found := source.Annotations[SECRET_REGENERATE_ANNOTATION]; found {
+				t.Errorf("Expected the regenerate annotation to be removed\n")
+			}
+		})
+	}
+}
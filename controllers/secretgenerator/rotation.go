@@ -0,0 +1,123 @@
+package secretgenerator
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// SECRET_LAST_ROTATION_ANNOTATION records when the value of a generated secret was last rotated
+	SECRET_LAST_ROTATION_ANNOTATION = "secret-generator.opendatahub.io/last-rotation"
+	// SECRET_PREVIOUS_KEY_SUFFIX is appended to the key holding the previous value during the grace period
+	SECRET_PREVIOUS_KEY_SUFFIX = "-previous"
+)
+
+// lastRotation returns when the value of the generated secret was last rotated, or its creation
+// time when it never was.
+func lastRotation(generatedSecret *v1.Secret) time.Time {
+	if value, found := generatedSecret.Annotations[SECRET_LAST_ROTATION_ANNOTATION]; found {
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t
+		}
+	}
+	return generatedSecret.CreationTimestamp.Time
+}
+
+// rotationDue returns true when the rotation interval of the value of the generated secret elapsed at now.
+func rotationDue(generatedSecret *v1.Secret, secret *Secret, now time.Time) bool {
+	return !now.Before(lastRotation(generatedSecret).Add(secret.RotationInterval))
+}
+
+// previousKeys returns the secondary keys of the generated secret holding the previous values.
+func previousKeys(generatedSecret *v1.Secret) []string {
+	var keys []string
+	for key := range generatedSecret.Data {
+		current := strings.TrimSuffix(key, SECRET_PREVIOUS_KEY_SUFFIX)
+		if _, found := generatedSecret.Data[current]; found && current != key {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// rotate updates the data of the generated secret at now: the values are replaced by the new values of
+// secret, generated by the caller, when the rotation interval elapsed, keeping the previous values under
+// secondary keys, which are removed at the end of the grace period. It returns true when the data changed,
+// and when the generated secret must be rotated or cleaned up next.
+func rotate(generatedSecret *v1.Secret, secret *Secret, now time.Time) (bool, time.Time) {
+	changed := false
+	last := lastRotation(generatedSecret)
+	if generatedSecret.Data == nil {
+		generatedSecret.Data = map[string][]byte{}
+	}
+	if rotationDue(generatedSecret, secret, now) {
+		for key, value := range secret.values() {
+			if current, found := generatedSecret.Data[key]; found {
+				generatedSecret.Data[key+SECRET_PREVIOUS_KEY_SUFFIX] = current
+			}
+			generatedSecret.Data[key] = []byte(value)
+		}
+		if generatedSecret.Annotations == nil {
+			generatedSecret.Annotations = map[string]string{}
+		}
+		generatedSecret.Annotations[SECRET_LAST_ROTATION_ANNOTATION] = now.UTC().Format(time.RFC3339)
+		last = now
+		changed = true
+	} else if previous := previousKeys(generatedSecret); len(previous) > 0 && !now.Before(last.Add(secret.RotationGracePeriod)) {
+		for _, key := range previous {
+			delete(generatedSecret.Data, key)
+		}
+		changed = true
+	}
+
+	next := last.Add(secret.RotationInterval)
+	if len(previousKeys(generatedSecret)) > 0 {
+		if expiry := last.Add(secret.RotationGracePeriod); expiry.Before(next) {
+			next = expiry
+		}
+	}
+	return changed, next
+}
+
+// rotateSecret rotates the value of the generated secret when due, generating the new values. The linked
+// OAuthClient is then updated by the reconcile, and accepts the previous value during the grace period. It
+// requeues the request for the next rotation or cleanup.
+func (r *SecretGeneratorReconciler) rotateSecret(foundSecret *v1.Secret, generatedSecret *v1.Secret,
+	secret *Secret) (ctrl.Result, error) {
+	now := r.now()
+	due := rotationDue(generatedSecret, secret, now)
+	if due {
+		if err := secret.generate(foundSecret.GetAnnotations()); err != nil {
+			return ctrl.Result{}, withReason(reasonInvalidAnnotations, err)
+		}
+	}
+	changed, next := rotate(generatedSecret, secret, now)
+	if changed {
+		if due {
+			secGenLog.Info("Rotating the value of a generated secret", "secret", generatedSecret.Name,
+				"namespace", generatedSecret.Namespace)
+		} else {
+			secGenLog.Info("Removing the previous value of a generated secret", "secret", generatedSecret.Name,
+				"namespace", generatedSecret.Namespace)
+		}
+		if err := r.Client.Update(context.TODO(), generatedSecret); err != nil {
+			return ctrl.Result{}, err
+		}
+		// Only the rotation is recorded, not the removal of the previous value after the grace period
+		if due {
+			r.recordGenerated(foundSecret, secret, reasonRotated, "Rotated the secret "+generatedSecret.Name)
+		}
+	}
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+func (r *SecretGeneratorReconciler) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
+}
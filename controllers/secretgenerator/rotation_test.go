@@ -0,0 +1,198 @@
+package secretgenerator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ocv1 "github.com/openshift/api/oauth/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRotate(t *testing.T) {
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	secret := &Secret{Name: "password", Value: "new", RotationInterval: 24 * time.Hour, RotationGracePeriod: time.Hour}
+	cases := map[string]struct {
+		data         map[string]string
+		annotations  map[string]string
+		now          time.Time
+		changed      bool
+		expectedData map[string]string
+		expectedNext time.Time
+	}{
+		"Not due since the creation": {
+			data:         map[string]string{"password": "old"},
+			now:          created.Add(time.Hour),
+			expectedData: map[string]string{"password": "old"},
+			expectedNext: created.Add(24 * time.Hour),
+		},
+		"Due since the creation": {
+			data:         map[string]string{"password": "old"},
+			now:          created.Add(25 * time.Hour),
+			changed:      true,
+			expectedData: map[string]string{"password": "new", "password-previous": "old"},
+			expectedNext: created.Add(26 * time.Hour),
+		},
+		"Not due since the last rotation": {
+			data:         map[string]string{"password": "old"},
+			annotations:  map[string]string{SECRET_LAST_ROTATION_ANNOTATION: created.Add(24 * time.Hour).Format(time.RFC3339)},
+			now:          created.Add(25 * time.Hour),
+			expectedData: map[string]string{"password": "old"},
+			expectedNext: created.Add(48 * time.Hour),
+		},
+		"Previous value in the grace period": {
+			data:         map[string]string{"password": "old", "password-previous": "older"},
+			annotations:  map[string]string{SECRET_LAST_ROTATION_ANNOTATION: created.Format(time.RFC3339)},
+			now:          created.Add(30 * time.Minute),
+			expectedData: map[string]string{"password": "old", "password-previous": "older"},
+			expectedNext: created.Add(time.Hour),
+		},
+		"Previous value after the grace period": {
+			data:         map[string]string{"password": "old", "password-previous": "older"},
+			annotations:  map[string]string{SECRET_LAST_ROTATION_ANNOTATION: created.Format(time.RFC3339)},
+			now:          created.Add(2 * time.Hour),
+			changed:      true,
+			expectedData: map[string]string{"password": "old"},
+			expectedNext: created.Add(24 * time.Hour),
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			generatedSecret := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations:       tc.annotations,
+					CreationTimestamp: metav1.NewTime(created),
+				},
+				Data: map[string][]byte{},
+			}
+			for k, v := range tc.data {
+				generatedSecret.Data[k] = []byte(v)
+			}
+			changed, next := rotate(generatedSecret, secret, tc.now)
+			if changed != tc.changed || !next.Equal(tc.expectedNext) {
+				t.Errorf("Expected changed %v and next %v, got: %v and %v\n", tc.changed, tc.expectedNext, changed, next)
+			}
+			if len(generatedSecret.Data) != len(tc.expectedData) {
+				t.Errorf("Expected data: %v, got: %v\n", tc.expectedData, generatedSecret.Data)
+			}
+			for k, v := range tc.expectedData {
+				if string(generatedSecret.Data[k]) != v {
+					t.Errorf("Expected %v: %v, got: %v\n", k, v, string(generatedSecret.Data[k]))
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileRotation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	ocv1.AddToScheme(scheme)
+	routev1.AddToScheme(scheme)
+
+	created := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "example",
+			Namespace: "opendatahub",
+			Annotations: map[string]string{
+				SECRET_NAME_ANNOTATION:                  "secret",
+				SECRET_TYPE_ANNOTATION:                  "oauth",
+				SECRET_OAUTH_CLIENT_ANNOTATION:          "example",
+				SECRET_ROTATION_INTERVAL_ANNOTATION:     "24h",
+				SECRET_ROTATION_GRACE_PERIOD_ANNOTATION: "1h",
+			},
+		},
+	}
+	generated := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "example-generated",
+			Namespace:   "opendatahub",
+			Annotations: map[string]string{SECRET_LAST_ROTATION_ANNOTATION: created.Format(time.RFC3339)},
+		},
+		Data: map[string][]byte{"secret": []byte("old")},
+	}
+	oauthClient := &ocv1.OAuthClient{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "example",
+			Annotations: map[string]string{SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION: "opendatahub/example"},
+		},
+		Secret: "old",
+	}
+	route := &routev1.Route{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "opendatahub"},
+		Spec:       routev1.RouteSpec{Host: "example.apps.cluster"},
+	}
+
+	now := created
+	r := &SecretGeneratorReconciler{
+		Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(source, generated, oauthClient, route).Build(),
+		Scheme:         scheme,
+		clock:          func() time.Time { return now },
+		routeAPI:       true,
+		oauthClientAPI: true,
+	}
+	reconcile := func(expectedRequeue time.Duration) (*v1.Secret, *ocv1.OAuthClient) {
+		t.Helper()
+		result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{
+			Name: "example", Namespace: "opendatahub"}})
+		if err != nil {
+			t.Fatalf("Failed to reconcile: %v", err)
+		}
+		if result.RequeueAfter != expectedRequeue {
+			t.Errorf("Expected requeue after %v, got: %v", expectedRequeue, result.RequeueAfter)
+		}
+		secret := &v1.Secret{}
+		r.Client.Get(context.TODO(), types.NamespacedName{Name: "example-generated", Namespace: "opendatahub"}, secret)
+		client := &ocv1.OAuthClient{}
+		r.Client.Get(context.TODO(), types.NamespacedName{Name: "example"}, client)
+		return secret, client
+	}
+
+	rotations := func() float64 {
+		return testutil.ToFloat64(secretsGenerated.WithLabelValues("oauth", reasonRotated))
+	}
+
+	now = created.Add(time.Hour)
+	reconcile(23 * time.Hour)
+
+	now = created.Add(24 * time.Hour)
+	before := rotations()
+	secret, client := reconcile(time.Hour)
+	if count := rotations() - before; count != 1 {
+		t.Errorf("Expected the rotation to be recorded once, got: %v", count)
+	}
+	value := string(secret.Data["secret"])
+	if value == "old" || string(secret.Data["secret-previous"]) != "old" {
+		t.Errorf("Expected the value to be rotated, got: %v", secret.Data)
+	}
+	if secret.Annotations[SECRET_LAST_ROTATION_ANNOTATION] != now.Format(time.RFC3339) {
+		t.Errorf("Expected the rotation time to be recorded, got: %v", secret.Annotations)
+	}
+	if client.Secret != value || len(client.AdditionalSecrets) != 1 || client.AdditionalSecrets[0] != "old" {
+		t.Errorf("Expected the oauth client to accept both values, got: %v and %v", client.Secret, client.AdditionalSecrets)
+	}
+
+	now = created.Add(25 * time.Hour)
+	before = rotations()
+	secret, client = reconcile(23 * time.Hour)
+	if count := rotations() - before; count != 0 {
+		t.Errorf("Expected the removal of the previous value not to be recorded as a rotation, got: %v", count)
+	}
+	if _, found := secret.Data["secret-previous"]; found || string(secret.Data["secret"]) != value {
+		t.Errorf("Expected the previous value to be removed, got: %v", secret.Data)
+	}
+	if client.Secret != value || len(client.AdditionalSecrets) != 0 {
+		t.Errorf("Expected the oauth client to accept the new value only, got: %v and %v", client.Secret,
+			client.AdditionalSecrets)
+	}
+}
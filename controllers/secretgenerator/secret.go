@@ -6,6 +6,7 @@ import (
 	"errors"
 	"math/big"
 	"strconv"
+	"time"
 )
 
 const (
@@ -14,6 +15,18 @@ const (
 	SECRET_LENGTH_ANNOTATION       = "secret-generator.opendatahub.io/complexity"
 	SECRET_OAUTH_CLIENT_ANNOTATION = "secret-generator.opendatahub.io/oauth-client-route"
 	SECRET_DEFAULT_COMPLEXITY      = 16
+	// SECRET_MAX_COMPLEXITY bounds the length of the generated values
+	SECRET_MAX_COMPLEXITY = 4096
+
+	// SECRET_OAUTH_CLIENT_INGRESS_ANNOTATION names the ingress the OAuth client redirects to, on clusters
+	// without OpenShift routes
+	SECRET_OAUTH_CLIENT_INGRESS_ANNOTATION = "secret-generator.opendatahub.io/oauth-client-ingress"
+
+	// SECRET_ROTATION_INTERVAL_ANNOTATION regenerates the value on schedule, e.g. "720h"
+	SECRET_ROTATION_INTERVAL_ANNOTATION = "secret-generator.opendatahub.io/rotation-interval"
+	// SECRET_ROTATION_GRACE_PERIOD_ANNOTATION is how long the previous value is kept after a rotation
+	SECRET_ROTATION_GRACE_PERIOD_ANNOTATION = "secret-generator.opendatahub.io/rotation-grace-period"
+	SECRET_DEFAULT_ROTATION_GRACE_PERIOD    = time.Hour
 
 	letterRunes = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
 
@@ -21,22 +34,58 @@ const (
 	errNameAnnotationNotFound = "name annotation not found in secret"
 	errTypeAnnotationNotFound = "type annotation not found in secret"
 	errUnsupportedType        = "secret type is not supported"
+	errInvalidRotation        = "rotation interval and grace period must be positive durations"
+	errOAuthClientRedirect    = "oauth client route and ingress annotations are mutually exclusive"
 )
 
 type Secret struct {
-	Name             string
-	Type             string
-	Complexity       int
-	Value            string
+	Name       string
+	Type       string
+	Complexity int
+	Value      string
+	// Data holds the other keys of the generated secret, e.g. the public key of a key pair
+	Data             map[string]string
 	OAuthClientRoute string
+	// OAuthClientIngress is the ingress the OAuth client redirects to, instead of OAuthClientRoute
+	OAuthClientIngress string
+	// RotationInterval is zero when the value is never rotated
+	RotationInterval    time.Duration
+	RotationGracePeriod time.Duration
 }
 
+// newSecret parses the annotations of a source secret and generates its values.
 func newSecret(annotations map[string]string) (*Secret, error) {
+	secret, err := parseSecret(annotations)
+	if err != nil {
+		return nil, err
+	}
+	if err := secret.generate(annotations); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// parseSecret returns the secret described by the annotations of a source secret, without generating its
+// values: its keys, type and complexity, OAuthClient and rotation.
+func parseSecret(annotations map[string]string) (*Secret, error) {
 	// Check if annotations is not empty
 	if len(annotations) == 0 {
 		return nil, errors.New(errEmptyAnnotation)
 	}
 
+	// Parse the keys of the keys annotation
+	if keys, found := annotations[SECRET_KEYS_ANNOTATION]; found {
+		_, primary, err := parseKeySpecs(annotations, keys)
+		if err != nil {
+			return nil, err
+		}
+		secret := &Secret{Name: primary, Type: multiKeyType, Complexity: SECRET_DEFAULT_COMPLEXITY}
+		if err := setSecretOptions(secret, annotations); err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+
 	var secret Secret
 
 	// Get name from annotation
@@ -52,6 +101,11 @@ func newSecret(annotations map[string]string) (*Secret, error) {
 	} else {
 		return nil, errors.New(errTypeAnnotationNotFound)
 	}
+	switch secret.Type {
+	case "random", "oauth", "hex", "uuid", "charset", "htpasswd", "rsa", "ecdsa", "ed25519", "tls":
+	default:
+		return nil, errors.New(errUnsupportedType)
+	}
 
 	// Get complexity from annotation
 	if secretComplexity, found := annotations[SECRET_LENGTH_ANNOTATION]; found {
@@ -61,32 +115,115 @@ func newSecret(annotations map[string]string) (*Secret, error) {
 		}
 		secret.Complexity = secretComplexity
 	} else {
-		secret.Complexity = SECRET_DEFAULT_COMPLEXITY
+		switch secret.Type {
+		case "rsa":
+			secret.Complexity = SECRET_DEFAULT_RSA_BITS
+		case "ecdsa":
+			secret.Complexity = SECRET_DEFAULT_ECDSA_BITS
+		default:
+			secret.Complexity = SECRET_DEFAULT_COMPLEXITY
+		}
+	}
+
+	if err := validateComplexity(&secret); err != nil {
+		return nil, err
 	}
 
+	if err := setSecretOptions(&secret, annotations); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// generate generates the values of secret, parsed from the annotations by parseSecret. Key pairs,
+// certificates and hashes are expensive, the values are only generated when the generated secret is
+// created, regenerated or rotated.
+func (s *Secret) generate(annotations map[string]string) error {
 	// Generate a random value based on the secret type
-	switch secret.Type {
+	switch s.Type {
+	case multiKeyType:
+		generated, err := newMultiKeySecret(annotations, annotations[SECRET_KEYS_ANNOTATION])
+		if err != nil {
+			return err
+		}
+		s.Value, s.Data = generated.Value, generated.Data
 	case "random":
-		randomValue := make([]byte, secret.Complexity)
-		for i := 0; i < secret.Complexity; i++ {
+		randomValue := make([]byte, s.Complexity)
+		for i := 0; i < s.Complexity; i++ {
 			num, err := rand.Int(rand.Reader, big.NewInt(int64(len(letterRunes))))
 			if err != nil {
-				return nil, err
+				return err
 			}
 			randomValue[i] = letterRunes[num.Int64()]
 		}
-		secret.Value = string(randomValue)
+		s.Value = string(randomValue)
 	case "oauth":
-		randomValue := make([]byte, secret.Complexity)
+		randomValue := make([]byte, s.Complexity)
 		rand.Read(randomValue)
-		secret.Value = base64.StdEncoding.EncodeToString(
+		s.Value = base64.StdEncoding.EncodeToString(
 			[]byte(base64.StdEncoding.EncodeToString(randomValue)))
+	case "hex":
+		return generateHex(s)
+	case "uuid":
+		return generateUUID(s)
+	case "charset":
+		return generateCharset(s, annotations)
+	case "htpasswd":
+		return generateHtpasswd(s, annotations)
+	case "rsa", "ecdsa", "ed25519":
+		return generateKeyPair(s, annotations)
+	case "tls":
+		return generateTLS(s, annotations)
 	default:
-		return nil, errors.New(errUnsupportedType)
+		return errors.New(errUnsupportedType)
 	}
-	// Get OAuthClient route name from annotation
+	return nil
+}
+
+// setSecretOptions sets the OAuthClient route or ingress and the rotation of secret from the annotations.
+func setSecretOptions(secret *Secret, annotations map[string]string) error {
+	// Get OAuthClient route or ingress name from annotation
 	if secretOAuthClientRoute, found := annotations[SECRET_OAUTH_CLIENT_ANNOTATION]; found {
 		secret.OAuthClientRoute = secretOAuthClientRoute
 	}
-	return &secret, nil
+	if secretOAuthClientIngress, found := annotations[SECRET_OAUTH_CLIENT_INGRESS_ANNOTATION]; found {
+		if secret.OAuthClientRoute != "" {
+			return errors.New(errOAuthClientRedirect)
+		}
+		secret.OAuthClientIngress = secretOAuthClientIngress
+	}
+
+	// Get rotation interval and grace period from annotations
+	if rotationInterval, found := annotations[SECRET_ROTATION_INTERVAL_ANNOTATION]; found {
+		interval, err := time.ParseDuration(rotationInterval)
+		if err != nil || interval <= 0 {
+			return errors.New(errInvalidRotation)
+		}
+		secret.RotationInterval = interval
+		secret.RotationGracePeriod = SECRET_DEFAULT_ROTATION_GRACE_PERIOD
+		if gracePeriod, found := annotations[SECRET_ROTATION_GRACE_PERIOD_ANNOTATION]; found {
+			grace, err := time.ParseDuration(gracePeriod)
+			if err != nil || grace <= 0 {
+				return errors.New(errInvalidRotation)
+			}
+			secret.RotationGracePeriod = grace
+		}
+	}
+	return nil
+}
+
+// isGeneratorSecret returns true if the annotations of a secret request a generated secret.
+func isGeneratorSecret(annotations map[string]string) bool {
+	_, nameFound := annotations[SECRET_NAME_ANNOTATION]
+	_, keysFound := annotations[SECRET_KEYS_ANNOTATION]
+	return nameFound || keysFound
+}
+
+// values returns the keys of the generated secret and their values.
+func (s *Secret) values() map[string]string {
+	values := map[string]string{s.Name: s.Value}
+	for key, value := range s.Data {
+		values[key] = value
+	}
+	return values
 }
@@ -2,7 +2,9 @@ package secretgenerator
 
 import (
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 )
 
 func TestNewSecret(t *testing.T) {
@@ -68,6 +70,46 @@ func TestNewSecret(t *testing.T) {
 				Complexity: SECRET_DEFAULT_COMPLEXITY,
 			},
 		},
+		"Rotation interval is invalid": {
+			annotations: map[string]string{
+				"secret-generator.opendatahub.io/name":              "example",
+				"secret-generator.opendatahub.io/type":              "random",
+				"secret-generator.opendatahub.io/rotation-interval": "monthly",
+			},
+			err: errors.New(errInvalidRotation),
+		},
+		"Rotation grace period is invalid": {
+			annotations: map[string]string{
+				"secret-generator.opendatahub.io/name":                  "example",
+				"secret-generator.opendatahub.io/type":                  "random",
+				"secret-generator.opendatahub.io/rotation-interval":     "720h",
+				"secret-generator.opendatahub.io/rotation-grace-period": "-1h",
+			},
+			err: errors.New(errInvalidRotation),
+		},
+		"OAuth client route and ingress are both set": {
+			annotations: map[string]string{
+				"secret-generator.opendatahub.io/name":                 "example",
+				"secret-generator.opendatahub.io/type":                 "oauth",
+				"secret-generator.opendatahub.io/oauth-client-route":   "dashboard",
+				"secret-generator.opendatahub.io/oauth-client-ingress": "dashboard",
+			},
+			err: errors.New(errOAuthClientRedirect),
+		},
+		"Generate a rotated secret": {
+			annotations: map[string]string{
+				"secret-generator.opendatahub.io/name":              "example",
+				"secret-generator.opendatahub.io/type":              "random",
+				"secret-generator.opendatahub.io/rotation-interval": "720h",
+			},
+			secret: Secret{
+				Name:                "example",
+				Type:                "random",
+				Complexity:          SECRET_DEFAULT_COMPLEXITY,
+				RotationInterval:    720 * time.Hour,
+				RotationGracePeriod: SECRET_DEFAULT_ROTATION_GRACE_PERIOD,
+			},
+		},
 		"Generate an OAuth secret with custom complexity": {
 			annotations: map[string]string{
 				"secret-generator.opendatahub.io/name":       "example",
@@ -93,7 +135,9 @@ func TestNewSecret(t *testing.T) {
 			} else {
 				if secret.Name != tc.secret.Name ||
 					secret.Type != tc.secret.Type ||
-					secret.Complexity != tc.secret.Complexity {
+					secret.Complexity != tc.secret.Complexity ||
+					secret.RotationInterval != tc.secret.RotationInterval ||
+					secret.RotationGracePeriod != tc.secret.RotationGracePeriod {
 					t.Errorf("Expected secret: %v, got: %v\n",
 						tc.secret, secret)
 				}
@@ -104,3 +148,76 @@ func TestNewSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestParseSecret(t *testing.T) {
+	cases := map[string]struct {
+		annotations map[string]string
+		secret      Secret
+		err         error
+	}{
+		"Secret type is not supported": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION: "example",
+				SECRET_TYPE_ANNOTATION: "ssh",
+			},
+			err: errors.New(errUnsupportedType),
+		},
+		"Negative complexity": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION:   "example",
+				SECRET_TYPE_ANNOTATION:   "random",
+				SECRET_LENGTH_ANNOTATION: "-1",
+			},
+			err: errors.New(errInvalidComplexity),
+		},
+		"Complexity above the maximum": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION:   "example",
+				SECRET_TYPE_ANNOTATION:   "oauth",
+				SECRET_LENGTH_ANNOTATION: "1000000000",
+			},
+			err: errors.New(errInvalidComplexity),
+		},
+		"Multi-key secret with an invalid complexity": {
+			annotations: map[string]string{
+				SECRET_KEYS_ANNOTATION: "- name: user\n  value: odh\n- name: password\n  type: random\n  complexity: -16\n",
+			},
+			err: fmt.Errorf("key password: %v", errInvalidComplexity),
+		},
+		"Key pair secret": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION: "example",
+				SECRET_TYPE_ANNOTATION: "rsa",
+			},
+			secret: Secret{Name: "example", Type: "rsa", Complexity: SECRET_DEFAULT_RSA_BITS},
+		},
+		"Multi-key secret": {
+			annotations: map[string]string{
+				SECRET_KEYS_ANNOTATION: "- name: user\n  value: odh\n- name: key\n  type: rsa\n",
+				SECRET_NAME_ANNOTATION: "key",
+			},
+			secret: Secret{Name: "key", Type: multiKeyType, Complexity: SECRET_DEFAULT_COMPLEXITY},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			secret, err := parseSecret(tc.annotations)
+			if tc.err != nil {
+				if err == nil || err.Error() != tc.err.Error() {
+					t.Errorf("Expected error: %v, got: %v\n", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse the secret: %v", err)
+			}
+			if secret.Name != tc.secret.Name || secret.Type != tc.secret.Type || secret.Complexity != tc.secret.Complexity {
+				t.Errorf("Expected secret: %v, got: %v\n", tc.secret, secret)
+			}
+			if secret.Value != "" || len(secret.Data) != 0 {
+				t.Errorf("Expected no generated values, got: %v and %v\n", secret.Value, secret.Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package secretgenerator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// SECRET_KEY_FORMAT_ANNOTATION is the format of the key pairs, "pem" (default) or "ssh"
+	SECRET_KEY_FORMAT_ANNOTATION = "secret-generator.opendatahub.io/key-format"
+	// SECRET_TLS_SANS_ANNOTATION lists the comma separated DNS names and IP addresses of a TLS certificate
+	SECRET_TLS_SANS_ANNOTATION = "secret-generator.opendatahub.io/tls-sans"
+	// SECRET_TLS_VALIDITY_ANNOTATION is how long a TLS certificate is valid, e.g. "8760h"
+	SECRET_TLS_VALIDITY_ANNOTATION = "secret-generator.opendatahub.io/tls-validity"
+	// SECRET_HTPASSWD_USER_ANNOTATION is the user of an htpasswd entry
+	SECRET_HTPASSWD_USER_ANNOTATION = "secret-generator.opendatahub.io/htpasswd-user"
+	// SECRET_CHARSET_ANNOTATION holds the characters of a charset secret
+	SECRET_CHARSET_ANNOTATION = "secret-generator.opendatahub.io/charset"
+
+	SECRET_DEFAULT_RSA_BITS     = 2048
+	SECRET_DEFAULT_ECDSA_BITS   = 256
+	SECRET_DEFAULT_TLS_VALIDITY = 365 * 24 * time.Hour
+
+	// Suffixes of the keys of the generated secret holding the other parts of a secret
+	SECRET_PUBLIC_KEY_SUFFIX  = ".pub"
+	SECRET_CERTIFICATE_SUFFIX = ".crt"
+	SECRET_PASSWORD_SUFFIX    = ".password"
+
+	KEY_FORMAT_PEM = "pem"
+	KEY_FORMAT_SSH = "ssh"
+
+	errInvalidComplexity       = "complexity is not valid for the secret type"
+	errInvalidKeyFormat        = "key format is not supported"
+	errInvalidTLSValidity      = "tls validity must be a positive duration"
+	errInvalidSAN              = "tls sans must be DNS names or IP addresses"
+	errHtpasswdUserNotFound    = "htpasswd user annotation not found in secret"
+	errInvalidHtpasswdUser     = "htpasswd user must not be empty or contain a colon"
+	errCharsetNotFound         = "charset annotation not found in secret"
+	errInvalidCharset          = "charset must have at least two distinct characters"
+	errUnsupportedSSHKeyFormat = "key type can't be marshaled in the ssh format"
+)
+
+// randomString returns a random string of length characters of charset.
+func randomString(charset string, length int) (string, error) {
+	runes := []rune(charset)
+	randomValue := make([]rune, length)
+	for i := 0; i < length; i++ {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(runes))))
+		if err != nil {
+			return "", err
+		}
+		randomValue[i] = runes[num.Int64()]
+	}
+	return string(randomValue), nil
+}
+
+// generateHex sets the value of secret to the hex encoding of complexity random bytes.
+func generateHex(secret *Secret) error {
+	randomValue := make([]byte, secret.Complexity)
+	if _, err := rand.Read(randomValue); err != nil {
+		return err
+	}
+	secret.Value = hex.EncodeToString(randomValue)
+	return nil
+}
+
+// generateUUID sets the value of secret to a random UUID.
+func generateUUID(secret *Secret) error {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+	secret.Value = id.String()
+	return nil
+}
+
+// generateCharset sets the value of secret to complexity random characters of the charset annotation.
+func generateCharset(secret *Secret, annotations map[string]string) error {
+	charset, found := annotations[SECRET_CHARSET_ANNOTATION]
+	if !found {
+		return errors.New(errCharsetNotFound)
+	}
+	distinct := map[rune]bool{}
+	for _, r := range charset {
+		distinct[r] = true
+	}
+	if len(distinct) < 2 {
+		return errors.New(errInvalidCharset)
+	}
+	value, err := randomString(charset, secret.Complexity)
+	if err != nil {
+		return err
+	}
+	secret.Value = value
+	return nil
+}
+
+// generateHtpasswd sets the value of secret to the htpasswd entry, with a bcrypt hash, of a random
+// password of complexity characters, and the <name>.password key to the password.
+func generateHtpasswd(secret *Secret, annotations map[string]string) error {
+	user, found := annotations[SECRET_HTPASSWD_USER_ANNOTATION]
+	if !found {
+		return errors.New(errHtpasswdUserNotFound)
+	}
+	if user == "" || strings.Contains(user, ":") {
+		return errors.New(errInvalidHtpasswdUser)
+	}
+	password, err := randomString(letterRunes, secret.Complexity)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	secret.Value = user + ":" + string(hash)
+	secret.Data = map[string]string{secret.Name + SECRET_PASSWORD_SUFFIX: password}
+	return nil
+}
+
+// rsaKeySizes are the supported sizes of RSA keys.
+var rsaKeySizes = map[int]bool{2048: true, 3072: true, 4096: true}
+
+// ecdsaCurves are the curves of ECDSA keys by size.
+var ecdsaCurves = map[int]elliptic.Curve{256: elliptic.P256(), 384: elliptic.P384(), 521: elliptic.P521()}
+
+// validateComplexity checks the complexity of secret is valid for its type: a size of RSA keys or ECDSA
+// curves, or between 1 and SECRET_MAX_COMPLEXITY for the types of random values.
+func validateComplexity(secret *Secret) error {
+	switch secret.Type {
+	case "rsa":
+		// larger keys would block the reconciles while they are generated
+		if !rsaKeySizes[secret.Complexity] {
+			return errors.New(errInvalidComplexity)
+		}
+	case "ecdsa":
+		if _, ok := ecdsaCurves[secret.Complexity]; !ok {
+			return errors.New(errInvalidComplexity)
+		}
+	case "random", "oauth", "hex", "charset", "htpasswd":
+		if secret.Complexity <= 0 || secret.Complexity > SECRET_MAX_COMPLEXITY {
+			return errors.New(errInvalidComplexity)
+		}
+	}
+	return nil
+}
+
+// generateKeyPair sets the value of secret to the private key of a new key pair, and the <name>.pub
+// key to its public key, in the format of the key format annotation. The complexity is the size of
+// RSA keys, 2048, 3072 or 4096, and of the curve of ECDSA keys.
+func generateKeyPair(secret *Secret, annotations map[string]string) error {
+	format := KEY_FORMAT_PEM
+	if keyFormat, found := annotations[SECRET_KEY_FORMAT_ANNOTATION]; found {
+		format = keyFormat
+	}
+	if format != KEY_FORMAT_PEM && format != KEY_FORMAT_SSH {
+		return errors.New(errInvalidKeyFormat)
+	}
+
+	var privateKey crypto.Signer
+	var err error
+	switch secret.Type {
+	case "rsa":
+		privateKey, err = rsa.GenerateKey(rand.Reader, secret.Complexity)
+	case "ecdsa":
+		curve, ok := ecdsaCurves[secret.Complexity]
+		if !ok {
+			return errors.New(errInvalidComplexity)
+		}
+		privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+	case "ed25519":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return errors.New(errUnsupportedType)
+	}
+	if err != nil {
+		return err
+	}
+
+	var private, public []byte
+	if format == KEY_FORMAT_SSH {
+		private, public, err = marshalSSHKeyPair(privateKey)
+	} else {
+		private, public, err = marshalPEMKeyPair(privateKey)
+	}
+	if err != nil {
+		return err
+	}
+	secret.Value = string(private)
+	secret.Data = map[string]string{secret.Name + SECRET_PUBLIC_KEY_SUFFIX: string(public)}
+	return nil
+}
+
+// marshalPEMKeyPair returns the PKCS #8 private key and the PKIX public key of privateKey, PEM encoded.
+func marshalPEMKeyPair(privateKey crypto.Signer) ([]byte, []byte, error) {
+	private, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	public, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), nil
+}
+
+// marshalSSHKeyPair returns the private key of privateKey in a PEM format read by OpenSSH, and its
+// public key in the authorized_keys format.
+func marshalSSHKeyPair(privateKey crypto.Signer) ([]byte, []byte, error) {
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, nil, err
+	}
+	var block *pem.Block
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		block = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		block = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	case ed25519.PrivateKey:
+		block, err = marshalOpenSSHEd25519PrivateKey(key, publicKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, errors.New(errUnsupportedSSHKeyFormat)
+	}
+	return pem.EncodeToMemory(block), ssh.MarshalAuthorizedKey(publicKey), nil
+}
+
+// marshalOpenSSHEd25519PrivateKey returns an unencrypted ed25519 private key in the openssh-key-v1
+// format, the only one OpenSSH reads ed25519 keys from.
+func marshalOpenSSHEd25519PrivateKey(key ed25519.PrivateKey, publicKey ssh.PublicKey) (*pem.Block, error) {
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return nil, err
+	}
+	var private []byte
+	private = append(private, check...)
+	private = append(private, check...)
+	private = appendSSHString(private, []byte(ssh.KeyAlgoED25519))
+	private = appendSSHString(private, key.Public().(ed25519.PublicKey))
+	private = appendSSHString(private, key)
+	private = appendSSHString(private, nil) // comment
+	for i := byte(1); len(private)%8 != 0; i++ {
+		private = append(private, i)
+	}
+
+	data := []byte("openssh-key-v1\x00")
+	data = appendSSHString(data, []byte("none")) // cipher
+	data = appendSSHString(data, []byte("none")) // kdf
+	data = appendSSHString(data, nil)            // kdf options
+	data = appendUint32(data, 1)
+	data = appendSSHString(data, publicKey.Marshal())
+	data = appendSSHString(data, private)
+	return &pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}, nil
+}
+
+// appendSSHString appends s in the string encoding of the SSH wire format to b.
+func appendSSHString(b []byte, s []byte) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	n := make([]byte, 4)
+	binary.BigEndian.PutUint32(n, v)
+	return append(b, n...)
+}
+
+// generateTLS sets the value of secret to the PEM encoded private key of a self-signed TLS certificate,
+// and the <name>.crt key to the certificate, valid for the DNS names and IP addresses of the tls sans
+// annotation.
+func generateTLS(secret *Secret, annotations map[string]string) error {
+	validity := SECRET_DEFAULT_TLS_VALIDITY
+	if tlsValidity, found := annotations[SECRET_TLS_VALIDITY_ANNOTATION]; found {
+		d, err := time.ParseDuration(tlsValidity)
+		if err != nil || d <= 0 {
+			return errors.New(errInvalidTLSValidity)
+		}
+		validity = d
+	}
+
+	var dnsNames []string
+	var ipAddresses []net.IP
+	if sans, found := annotations[SECRET_TLS_SANS_ANNOTATION]; found {
+		for _, san := range strings.Split(sans, ",") {
+			san = strings.TrimSpace(san)
+			if san == "" {
+				continue
+			}
+			if ip := net.ParseIP(san); ip != nil {
+				ipAddresses = append(ipAddresses, ip)
+			} else if strings.ContainsAny(san, " /:@") {
+				return errors.New(errInvalidSAN)
+			} else {
+				dnsNames = append(dnsNames, san)
+			}
+		}
+	}
+	commonName := secret.Name
+	if len(dnsNames) > 0 {
+		commonName = dnsNames[0]
+	}
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-5 * time.Minute),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              dnsNames,
+		IPAddresses:           ipAddresses,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, privateKey.Public(), privateKey)
+	if err != nil {
+		return err
+	}
+	private, _, err := marshalPEMKeyPair(privateKey)
+	if err != nil {
+		return err
+	}
+	secret.Value = string(private)
+	secret.Data = map[string]string{
+		secret.Name + SECRET_CERTIFICATE_SUFFIX: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+	}
+	return nil
+}
@@ -3,30 +3,31 @@ package secretgenerator
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"time"
 
 	ocv1 "github.com/openshift/api/oauth/v1"
 	routev1 "github.com/openshift/api/route/v1"
 	v1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
 	resourceRetryInterval = 10 * time.Second
-	resourceRetryTimeout  = 1 * time.Minute
 )
 
 var secGenLog = log.Log.WithName("secret-generator")
@@ -35,43 +36,70 @@ var secGenLog = log.Log.WithName("secret-generator")
 type SecretGeneratorReconciler struct {
 	Client client.Client
 	Scheme *runtime.Scheme
+	// Recorder to generate events on the source secrets, none when nil
+	Recorder record.EventRecorder
+
+	// clock returns the current time for the rotations, time.Now when nil
+	clock func() time.Time
+	// routeAPI and oauthClientAPI are true when the cluster serves the OpenShift routes and OAuthClients
+	routeAPI       bool
+	oauthClientAPI bool
 }
 
 func (r *SecretGeneratorReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	secGenLog.Info("Adding controller for Secret Generation.")
 
-	// Watch only new secrets with the corresponding annotation
+	// Watch only secrets with the corresponding annotation, and their updates changing the annotations or labels
 	predicates := predicate.Funcs{
 		CreateFunc: func(e event.CreateEvent) bool {
-			if _, found := e.Object.GetAnnotations()[SECRET_NAME_ANNOTATION]; found {
-				return true
-			}
-			return false
+			return isGeneratorSecret(e.Object.GetAnnotations())
 		},
 		GenericFunc: func(e event.GenericEvent) bool {
 			return false
 		},
 		DeleteFunc: func(e event.DeleteEvent) bool {
-			if _, found := e.Object.GetAnnotations()[SECRET_NAME_ANNOTATION]; found {
-				return true
-			}
-			return false
+			return isGeneratorSecret(e.Object.GetAnnotations())
 		},
 		UpdateFunc: func(e event.UpdateEvent) bool {
-			return false
+			if !isGeneratorSecret(e.ObjectNew.GetAnnotations()) {
+				return false
+			}
+			return !reflect.DeepEqual(withoutStatus(e.ObjectOld.GetAnnotations()),
+				withoutStatus(e.ObjectNew.GetAnnotations())) ||
+				!reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels())
 		},
 	}
 
-	secretBuilder := ctrl.NewControllerManagedBy(mgr).Named("secret-generator-controller")
-	err := secretBuilder.For(&v1.Secret{}).
-		Watches(&source.Kind{Type: &v1.Secret{}}, handler.EnqueueRequestsFromMapFunc(
-			func(a client.Object) []reconcile.Request {
-				namespacedName := types.NamespacedName{Name: a.GetName(), Namespace: a.GetNamespace()}
-				return []reconcile.Request{{NamespacedName: namespacedName}}
-			}), builder.WithPredicates(predicates)).WithEventFilter(predicates).
-		Complete(r)
+	// Detect the OpenShift APIs, the OAuth clients of plain Kubernetes clusters only redirect to ingresses
+	dc, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	if r.routeAPI, err = apiAvailable(dc, routev1.SchemeGroupVersion, "routes"); err != nil {
+		return err
+	}
+	if r.oauthClientAPI, err = apiAvailable(dc, ocv1.SchemeGroupVersion, "oauthclients"); err != nil {
+		return err
+	}
+	secGenLog.Info("Detected the OpenShift APIs", "routes", r.routeAPI, "oauthclients", r.oauthClientAPI)
+
+	// Watch the routes, ingresses and OAuthClients of the source secrets, to keep the OAuthClients in sync
+	secretBuilder := ctrl.NewControllerManagedBy(mgr).Named("secret-generator-controller").
+		For(&v1.Secret{}, builder.WithPredicates(predicates)).
+		Watches(&source.Kind{Type: &netv1.Ingress{}},
+			handler.EnqueueRequestsFromMapFunc(r.secretsReferencing(SECRET_OAUTH_CLIENT_INGRESS_ANNOTATION)),
+			builder.WithPredicates(ingressPredicates))
+	if r.routeAPI {
+		secretBuilder = secretBuilder.Watches(&source.Kind{Type: &routev1.Route{}},
+			handler.EnqueueRequestsFromMapFunc(r.secretsReferencing(SECRET_OAUTH_CLIENT_ANNOTATION)),
+			builder.WithPredicates(routePredicates))
+	}
+	if r.oauthClientAPI {
+		secretBuilder = secretBuilder.Watches(&source.Kind{Type: &ocv1.OAuthClient{}},
+			handler.EnqueueRequestsFromMapFunc(secretForOAuthClient), builder.WithPredicates(oauthClientPredicates))
+	}
 
-	return err
+	return secretBuilder.Complete(r)
 }
 
 // Reconcile will generate new secret with random data for the annotated secret
@@ -84,11 +112,27 @@ func (r *SecretGeneratorReconciler) Reconcile(ctx context.Context, request ctrl.
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			// If Secret is deleted, delete OAuthClient if exists
-			err = r.deleteOAuthClient(request.Name)
+			err = r.deleteOAuthClient(request.NamespacedName)
 		}
 		return ctrl.Result{}, err
 	}
 
+	// Record the outcome on the source secret, waiting for the host of the OAuth client redirect isn't
+	// an error
+	result, err := r.reconcileSecret(foundSecret)
+	if statusErr := r.setStatus(foundSecret, err); statusErr != nil && err == nil {
+		err = statusErr
+	}
+	if isPending(err) {
+		secGenLog.Info("Waiting for the host of the oauth client redirect", "secret-name", foundSecret.Name)
+		return earliestResult(result, ctrl.Result{RequeueAfter: resourceRetryInterval}), nil
+	}
+	return result, err
+}
+
+// reconcileSecret generates the generated secret of the source secret if it does not previously exist,
+// updates or rotates it otherwise, and reconciles its OAuth client.
+func (r *SecretGeneratorReconciler) reconcileSecret(foundSecret *v1.Secret) (ctrl.Result, error) {
 	owner := []metav1.OwnerReference{
 		*metav1.NewControllerRef(foundSecret, foundSecret.GroupVersionKind()),
 	}
@@ -97,116 +141,100 @@ func (r *SecretGeneratorReconciler) Reconcile(ctx context.Context, request ctrl.
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            foundSecret.Name + "-generated",
 			Namespace:       foundSecret.Namespace,
-			Labels:          foundSecret.Labels,
 			OwnerReferences: owner,
 		},
 	}
 
 	generatedSecretKey := types.NamespacedName{
 		Name: generatedSecret.Name, Namespace: generatedSecret.Namespace}
-	err = r.Client.Get(context.TODO(), generatedSecretKey, generatedSecret)
+	result := ctrl.Result{}
+	var secret *Secret
+	var oauthClientSecret string
+	var additionalSecrets []string
+	err := r.Client.Get(context.TODO(), generatedSecretKey, generatedSecret)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			// Generate secret random value
 			secGenLog.Info("Generating a random value for a secret in a namespace",
 				"secret", generatedSecret.Name, "namespace", generatedSecret.Namespace)
 
-			secret, err := newSecret(foundSecret.GetAnnotations())
+			secret, err = newSecret(foundSecret.GetAnnotations())
 			if err != nil {
 				secGenLog.Error(err, "error creating secret")
-				return ctrl.Result{}, err
+				return ctrl.Result{}, withReason(reasonInvalidAnnotations, err)
 			}
 
-			generatedSecret.StringData = map[string]string{
-				secret.Name: secret.Value,
+			generatedSecret.Labels = foundSecret.Labels
+			generatedSecret.StringData = secret.values()
+			generatedSecret.Annotations = map[string]string{
+				SECRET_SPEC_HASH_ANNOTATION: specHash(foundSecret.GetAnnotations()),
+			}
+			if secret.RotationInterval > 0 {
+				generatedSecret.Annotations[SECRET_LAST_ROTATION_ANNOTATION] = r.now().UTC().Format(time.RFC3339)
+				result.RequeueAfter = secret.RotationInterval
 			}
 
 			err = r.Client.Create(context.TODO(), generatedSecret)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
-			if secret.OAuthClientRoute != "" {
-				// Get OauthClient Route
-				oauthClientRoute, err := r.getRoute(secret.OAuthClientRoute, request.Namespace)
-				if err != nil {
-					secGenLog.Error(err, "Unable to retrieve route", "route-name", secret.OAuthClientRoute)
-					return ctrl.Result{}, err
-				}
-				// Generate OAuthClient for the generated secret
-				secGenLog.Info("Generating an oauth client resource for route", "route-name", oauthClientRoute.Name)
-				err = r.createOAuthClient(foundSecret.Name, secret.Value, oauthClientRoute.Spec.Host)
-				if err != nil {
-					secGenLog.Error(err, "error creating oauth client resource. Recreate the Secret", "secret-name",
-						foundSecret.Name)
-					return ctrl.Result{}, err
-				}
-			}
+			r.recordGenerated(foundSecret, secret, reasonGenerated, "Generated the secret "+generatedSecret.Name)
+			oauthClientSecret = secret.Value
 		} else {
 			return ctrl.Result{}, err
 		}
-	}
-
-	// Don't requeue if secret is created successfully
-	return ctrl.Result{}, err
-}
-
-// getRoute returns an OpenShift route object. It waits until the .spec.host value exists to avoid possible race conditions, fails otherwise.
-func (r *SecretGeneratorReconciler) getRoute(name string, namespace string) (*routev1.Route, error) {
-	route := &routev1.Route{}
-	// Get spec.host from route
-	err := wait.PollImmediate(resourceRetryInterval, resourceRetryTimeout, func() (done bool, err error) {
-		err = r.Client.Get(context.TODO(), types.NamespacedName{Name: name,
-			Namespace: namespace}, route)
+	} else {
+		// The values are only generated when they are regenerated or rotated
+		secret, err = parseSecret(foundSecret.GetAnnotations())
 		if err != nil {
-			if k8serrors.IsNotFound(err) {
-				return false, nil
+			secGenLog.Error(err, "error parsing secret")
+			return ctrl.Result{}, withReason(reasonInvalidAnnotations, err)
+		}
+		regenerated, err := r.updateGeneratedSecret(foundSecret, generatedSecret, secret)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if regenerated {
+			r.recordGenerated(foundSecret, secret, reasonRegenerated, "Regenerated the secret "+generatedSecret.Name)
+		}
+		if secret.RotationInterval > 0 {
+			result, err = r.rotateSecret(foundSecret, generatedSecret, secret)
+			if err != nil {
+				return ctrl.Result{}, err
 			}
-			return false, err
-		} else if route.Spec.Host == "" {
-			return false, nil
-		} else {
-			return true, nil
 		}
-	})
-
-	if err != nil {
-		return nil, err
-	}
-	return route, err
-}
-
-func (r *SecretGeneratorReconciler) createOAuthClient(name string, secret string, uri string) error {
-	// Create OAuthClient resource
-	oauthClient := &ocv1.OAuthClient{
-		TypeMeta: metav1.TypeMeta{},
-		ObjectMeta: metav1.ObjectMeta{
-			Name: name,
-		},
-		Secret:       secret,
-		RedirectURIs: []string{"https://" + uri},
-		GrantMethod:  ocv1.GrantHandlerAuto,
+		oauthClientSecret, additionalSecrets = oauthClientSecrets(generatedSecret, secret)
 	}
 
-	err := r.Client.Create(context.TODO(), oauthClient)
-	if err != nil {
-		if k8serrors.IsAlreadyExists(err) {
-			secGenLog.Info("OAuth client resource already exists", "name", oauthClient.Name)
-			return nil
+	if secret.OAuthClientRoute != "" || secret.OAuthClientIngress != "" {
+		// Generate or update the OAuth client of the generated secret, once its route or ingress has a host
+		if err := r.reconcileOAuth(foundSecret, secret, oauthClientSecret, additionalSecrets); err != nil {
+			return result, err
 		}
 	}
-	return err
+
+	// Don't requeue if secret is created successfully, unless it's rotated
+	return result, nil
 }
 
-func (r *SecretGeneratorReconciler) deleteOAuthClient(secretName string) error {
+// deleteOAuthClient deletes the OAuthClient generated for the deleted source secret, if it exists.
+func (r *SecretGeneratorReconciler) deleteOAuthClient(secretName types.NamespacedName) error {
+	// Without the OpenShift OAuth API there is no OAuthClient to delete
+	if !r.oauthClientAPI {
+		return nil
+	}
 	oauthClient := &ocv1.OAuthClient{}
 
-	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: secretName}, oauthClient)
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: secretName.Name}, oauthClient)
 	if err != nil {
 		if k8serrors.IsNotFound(err) {
 			return nil
 		}
 		return err
 	}
+	if oauthClient.Annotations[SECRET_OAUTH_CLIENT_SOURCE_ANNOTATION] != secretName.String() {
+		return nil
+	}
 
 	err = r.Client.Delete(context.TODO(), oauthClient)
 	if err != nil {
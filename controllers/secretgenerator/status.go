@@ -0,0 +1,143 @@
+package secretgenerator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// SECRET_STATUS_ANNOTATION records on the source secret the outcome of its last reconcile: Generated,
+	// Pending while waiting for the host of the OAuth client redirect, or Failed
+	SECRET_STATUS_ANNOTATION = "secret-generator.opendatahub.io/status"
+	// SECRET_LAST_ERROR_ANNOTATION records on the source secret the error of its last reconcile, and is
+	// removed once it succeeds
+	SECRET_LAST_ERROR_ANNOTATION = "secret-generator.opendatahub.io/last-error"
+
+	statusGenerated = "Generated"
+	statusPending   = "Pending"
+	statusFailed    = "Failed"
+
+	// The reasons of the events and failures of the reconciles
+	reasonGenerated            = "SecretGenerated"
+	reasonRegenerated          = "SecretRegenerated"
+	reasonRotated              = "SecretRotated"
+	reasonInvalidAnnotations   = "InvalidAnnotations"
+	reasonOAuthRedirectPending = "OAuthRedirectPending"
+	reasonOAuthClientConflict  = "OAuthClientConflict"
+	reasonReconcileFailed      = "ReconcileFailed"
+)
+
+var (
+	secretsGenerated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_generator_generated_total",
+		Help: "Number of secret values generated, by secret type and reason",
+	}, []string{"type", "reason"})
+	secretGenerationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "secret_generator_failures_total",
+		Help: "Number of failed reconciles of source secrets, by reason",
+	}, []string{"reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(secretsGenerated, secretGenerationFailures)
+}
+
+// statusAnnotations are the annotations of the source secret set by the controller, which don't trigger
+// a reconcile.
+var statusAnnotations = []string{SECRET_STATUS_ANNOTATION, SECRET_LAST_ERROR_ANNOTATION}
+
+// reconcileError is an error of a reconcile with the reason of its event and failure metric.
+type reconcileError struct {
+	reason string
+	err    error
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// withReason returns err with the reason of its event and failure metric.
+func withReason(reason string, err error) error {
+	return &reconcileError{reason: reason, err: err}
+}
+
+// isPending returns true if err is the wait for the host of the OAuth client redirect.
+func isPending(err error) bool {
+	var reconcileErr *reconcileError
+	return errors.As(err, &reconcileErr) && reconcileErr.reason == reasonOAuthRedirectPending
+}
+
+// withoutStatus returns the annotations without the status annotations.
+func withoutStatus(annotations map[string]string) map[string]string {
+	filtered := map[string]string{}
+	for key, value := range annotations {
+		filtered[key] = value
+	}
+	for _, key := range statusAnnotations {
+		delete(filtered, key)
+	}
+	return filtered
+}
+
+// recordGenerated emits the event and increments the metric of a generation of the values of secret.
+func (r *SecretGeneratorReconciler) recordGenerated(foundSecret *v1.Secret, secret *Secret, reason string,
+	message string) {
+	secretsGenerated.WithLabelValues(secret.Type, reason).Inc()
+	if r.Recorder != nil {
+		r.Recorder.Event(foundSecret, v1.EventTypeNormal, reason, message)
+	}
+}
+
+// setStatus records the outcome of the reconcile of the source secret: its status and last error
+// annotations, a warning event and the failure metric when err isn't nil. The Pending status isn't an
+// error of the reconcile, which is requeued: it isn't counted as a failure, and its event is only emitted
+// when the secret becomes pending.
+func (r *SecretGeneratorReconciler) setStatus(foundSecret *v1.Secret, err error) error {
+	status := statusGenerated
+	if isPending(err) {
+		status = statusPending
+		if foundSecret.GetAnnotations()[SECRET_STATUS_ANNOTATION] != statusPending && r.Recorder != nil {
+			r.Recorder.Event(foundSecret, v1.EventTypeNormal, reasonOAuthRedirectPending, err.Error())
+		}
+	} else if err != nil {
+		reason := reasonReconcileFailed
+		var reconcileErr *reconcileError
+		if errors.As(err, &reconcileErr) {
+			reason = reconcileErr.reason
+		}
+		status = statusFailed
+		secretGenerationFailures.WithLabelValues(reason).Inc()
+		if r.Recorder != nil {
+			r.Recorder.Event(foundSecret, v1.EventTypeWarning, reason, err.Error())
+		}
+	}
+
+	annotations := map[string]string{}
+	for key, value := range foundSecret.GetAnnotations() {
+		annotations[key] = value
+	}
+	annotations[SECRET_STATUS_ANNOTATION] = status
+	if err != nil {
+		annotations[SECRET_LAST_ERROR_ANNOTATION] = err.Error()
+	} else {
+		delete(annotations, SECRET_LAST_ERROR_ANNOTATION)
+	}
+	if reflect.DeepEqual(annotations, foundSecret.GetAnnotations()) {
+		return nil
+	}
+	foundSecret.SetAnnotations(annotations)
+	if updateErr := r.Client.Update(context.TODO(), foundSecret); updateErr != nil {
+		return fmt.Errorf("error updating the status of the secret: %v", updateErr)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package secretgenerator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	ocv1 "github.com/openshift/api/oauth/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcileStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	clientgoscheme.AddToScheme(scheme)
+	ocv1.AddToScheme(scheme)
+	routev1.AddToScheme(scheme)
+
+	cases := map[string]struct {
+		annotations   map[string]string
+		status        string
+		lastError     string
+		event         string
+		noEvent       string
+		failureReason string
+		noFailure     string
+		requeue       bool
+	}{
+		"Secret is generated": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION:       "password",
+				SECRET_TYPE_ANNOTATION:       "random",
+				SECRET_LAST_ERROR_ANNOTATION: errUnsupportedType,
+			},
+			status: statusGenerated,
+			event:  "Normal " + reasonGenerated,
+		},
+		"Secret type is not supported": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION: "password",
+				SECRET_TYPE_ANNOTATION: "unknown",
+			},
+			status:        statusFailed,
+			lastError:     errUnsupportedType,
+			event:         "Warning " + reasonInvalidAnnotations,
+			failureReason: reasonInvalidAnnotations,
+		},
+		"Route has no host": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION:         "password",
+				SECRET_TYPE_ANNOTATION:         "oauth",
+				SECRET_OAUTH_CLIENT_ANNOTATION: "dashboard",
+			},
+			status:    statusPending,
+			lastError: "route dashboard has no host yet",
+			event:     "Normal " + reasonOAuthRedirectPending,
+			noFailure: reasonOAuthRedirectPending,
+			requeue:   true,
+		},
+		"Route still has no host": {
+			annotations: map[string]string{
+				SECRET_NAME_ANNOTATION:         "password",
+				SECRET_TYPE_ANNOTATION:         "oauth",
+				SECRET_OAUTH_CLIENT_ANNOTATION: "dashboard",
+				SECRET_STATUS_ANNOTATION:       statusPending,
+				SECRET_LAST_ERROR_ANNOTATION:   "route dashboard has no host yet",
+			},
+			status:    statusPending,
+			lastError: "route dashboard has no host yet",
+			event:     "Normal " + reasonGenerated,
+			noEvent:   reasonOAuthRedirectPending,
+			noFailure: reasonOAuthRedirectPending,
+			requeue:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			source := &v1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "example",
+					Namespace:   "opendatahub",
+					Annotations: tc.annotations,
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &SecretGeneratorReconciler{
+				Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(source).Build(),
+				Scheme:         scheme,
+				Recorder:       recorder,
+				routeAPI:       true,
+				oauthClientAPI: true,
+			}
+			var failures, noFailures float64
+			if tc.failureReason != "" {
+				failures = testutil.ToFloat64(secretGenerationFailures.WithLabelValues(tc.failureReason))
+			}
+			if tc.noFailure != "" {
+				noFailures = testutil.ToFloat64(secretGenerationFailures.WithLabelValues(tc.noFailure))
+			}
+
+			result, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: types.NamespacedName{
+				Name: "example", Namespace: "opendatahub"}})
+			if tc.status == statusFailed {
+				if err == nil {
+					t.Errorf("Expected the reconcile to fail\n")
+				}
+			} else if err != nil {
+				t.Fatalf("Failed to reconcile: %v", err)
+			}
+			if requeue := result.RequeueAfter == resourceRetryInterval; requeue != tc.requeue {
+				t.Errorf("Expected requeue: %v, got: %v\n", tc.requeue, result)
+			}
+
+			source = &v1.Secret{}
+			r.Client.Get(context.TODO(), types.NamespacedName{Name: "example", Namespace: "opendatahub"}, source)
+			if status := source.Annotations[SECRET_STATUS_ANNOTATION]; status != tc.status {
+				t.Errorf("Expected status: %v, got: %v\n", tc.status, status)
+			}
+			if lastError := source.Annotations[SECRET_LAST_ERROR_ANNOTATION]; lastError != tc.lastError {
+				t.Errorf("Expected last error: %v, got: %v\n", tc.lastError, lastError)
+			}
+			close(recorder.Events)
+			found := false
+			for event := range recorder.Events {
+				found = found || strings.HasPrefix(event, tc.event+" ")
+				if tc.noEvent != "" && strings.Contains(event, " "+tc.noEvent+" ") {
+					t.Errorf("Expected no %v event, got: %v\n", tc.noEvent, event)
+				}
+			}
+			if !found {
+				t.Errorf("Expected event: %v\n", tc.event)
+			}
+			if tc.failureReason != "" {
+				count := testutil.ToFloat64(secretGenerationFailures.WithLabelValues(tc.failureReason))
+				if count != failures+1 {
+					t.Errorf("Expected the failure to be counted, got: %v\n", count)
+				}
+			}
+			if tc.noFailure != "" {
+				count := testutil.ToFloat64(secretGenerationFailures.WithLabelValues(tc.noFailure))
+				if count != noFailures {
+					t.Errorf("Expected no %v failure to be counted, got: %v\n", tc.noFailure, count)
+				}
+			}
+		})
+	}
+}
+
+func TestWithoutStatus(t *testing.T) {
+	annotations := map[string]string{
+		SECRET_NAME_ANNOTATION:       "password",
+		SECRET_STATUS_ANNOTATION:     statusFailed,
+		SECRET_LAST_ERROR_ANNOTATION: errUnsupportedType,
+	}
+	filtered := withoutStatus(annotations)
+	if len(filtered) != 1 || filtered[SECRET_NAME_ANNOTATION] != "password" {
+		t.Errorf("Expected the status annotations to be removed, got: %v\n", filtered)
+	}
+	if len(annotations) != 3 {
+		t.Errorf("Expected the annotations to be unchanged, got: %v\n", annotations)
+	}
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"github.com/opendatahub-io/opendatahub-operator/controllers/secretgenerator"
 	ocv1 "github.com/openshift/api/oauth/v1"
@@ -24,6 +25,8 @@ import (
 	//operatorsv1alpha1 "github.com/operator-framework/operator-lifecycle-manager/pkg/api/o"
 	apiserv1 "k8s.io/kube-aggregator/pkg/apis/apiregistration/v1"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -40,11 +43,14 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	awspluginskubefloworgv1alpha1 "github.com/opendatahub-io/opendatahub-operator/apis/aws.plugins.kubeflow.org/v1alpha1"
 	gcppluginskubefloworgv1alpha1 "github.com/opendatahub-io/opendatahub-operator/apis/gcp.plugins.kubeflow.org/v1alpha1"
@@ -52,6 +58,7 @@ import (
 	kfdefappskubefloworgv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
 	kfupdateappskubefloworgv1alpha1 "github.com/opendatahub-io/opendatahub-operator/apis/kfupdate.apps.kubeflow.org/v1alpha1"
 	kfdefappskubefloworg "github.com/opendatahub-io/opendatahub-operator/controllers/kfdef.apps.kubeflow.org"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -90,11 +97,50 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var repoCacheOpts kfconfig.RepoCacheOptions
+	var channelPollInterval time.Duration
+	var bundleDirs string
+	var userFieldManagers string
+	var secretsDir string
+	var vaultProvider kfconfig.VaultSecretProvider
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&repoCacheOpts.Dir, "repo-cache-dir", "/tmp/repo-cache",
+		"The directory of the repository cache shared by the KfDefs. The cache is disabled when empty.")
+	flag.DurationVar(&repoCacheOpts.TTL, "repo-cache-ttl", time.Hour,
+		"How long a cached repository no KfDef uses is kept.")
+	flag.Int64Var(&repoCacheOpts.MaxSize, "repo-cache-max-size", 2<<30,
+		"The size in bytes above which cached repositories no KfDef uses are evicted.")
+	flag.DurationVar(&repoCacheOpts.RefreshInterval, "repo-cache-refresh-interval", 5*time.Minute,
+		"How long a cached repository that isn't pinned to a sha256 or commit is used before it's fetched again.")
+	flag.DurationVar(&kfconfig.RepoFetchTimeout, "repo-fetch-timeout", 10*time.Minute,
+		"How long the download or git fetch of a repository can take. There is no limit when 0.")
+	flag.DurationVar(&channelPollInterval, "channel-poll-interval", time.Hour,
+		"How often the release channels of the repositories are polled for new versions.")
+	flag.StringVar(&bundleDirs, "bundle-dirs", kfconfig.DefaultBundleDir,
+		"Comma-separated directories searched in order for the bundled:// repositories.")
+	flag.StringVar(&userFieldManagers, "user-field-managers", strings.Join(kfconfig.DefaultUserFieldManagers, ","),
+		"Comma-separated field managers whose changes of the configurable resources are preserved. "+
+			"A field manager matches a name, or a name followed by - and a command, e.g. kubectl-edit.")
+	flag.StringVar(&kfconfig.ExportRoot, "export-root", "",
+		"The directory the path and archive exports of the KfDefs are written under, in a subdirectory per namespace. "+
+			"These exports are disabled when empty.")
+	flag.StringVar(&kfconfig.ChartRoot, "chart-root", "",
+		"The directory the Helm charts of the chartPath of the KfDefs are read from. These charts are disabled when empty.")
+	flag.StringVar(&secretsDir, "secrets-dir", "",
+		"The directory the file secret provider reads the external secrets from, in a subdirectory per namespace. "+
+			"The provider is disabled when empty.")
+	flag.StringVar(&vaultProvider.Address, "vault-address", "",
+		"The address of the Vault-compatible server of the vault secret provider. The provider is disabled when empty.")
+	flag.StringVar(&vaultProvider.Mount, "vault-mount", "secret",
+		"The mount of the KV engine of the vault secret provider, holding the secrets under a path per namespace.")
+	flag.IntVar(&vaultProvider.KVVersion, "vault-kv-version", 2, "The version of the KV engine of the vault secret provider, 1 or 2.")
+	flag.StringVar(&vaultProvider.TokenFile, "vault-token-file", "",
+		"The file holding the token of the vault secret provider, read on every request.")
+	flag.StringVar(&vaultProvider.Namespace, "vault-namespace", "", "The namespace of the secrets of the vault secret provider.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -103,6 +149,35 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if secretsDir != "" {
+		kfconfig.SecretProviders[kfconfig.FileSecretProviderName] = &kfconfig.FileSecretProvider{Dir: secretsDir}
+	}
+	if vaultProvider.Address != "" {
+		kfconfig.SecretProviders[kfconfig.VaultSecretProviderName] = &vaultProvider
+	}
+
+	kfconfig.BundleDirs = nil
+	for _, dir := range strings.Split(bundleDirs, ",") {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			kfconfig.BundleDirs = append(kfconfig.BundleDirs, dir)
+		}
+	}
+	kfconfig.UserFieldManagers = nil
+	for _, manager := range strings.Split(userFieldManagers, ",") {
+		if manager = strings.TrimSpace(manager); manager != "" {
+			kfconfig.UserFieldManagers = append(kfconfig.UserFieldManagers, manager)
+		}
+	}
+
+	if repoCacheOpts.Dir != "" {
+		repoCache, err := kfconfig.NewRepoCache(repoCacheOpts)
+		if err != nil {
+			setupLog.Error(err, "unable to create the repository cache")
+			os.Exit(1)
+		}
+		kfconfig.SharedRepoCache = repoCache
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -127,20 +202,43 @@ func main() {
 		os.Exit(1)
 	}
 
+	// The Secrets referenced by the KfDefs are read without the cache, which only holds the watched objects
+	kfconfig.SecretReader = func(namespace string, name string) (map[string][]byte, error) {
+		secret := &v1.Secret{}
+		if err := mgr.GetAPIReader().Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, secret); err != nil {
+			return nil, err
+		}
+		return secret.Data, nil
+	}
+
+	// The cached repositories the KfDefs no longer use expire even when no KfDef is reconciled
+	if kfconfig.SharedRepoCache != nil && repoCacheOpts.TTL > 0 {
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			wait.UntilWithContext(ctx, func(context.Context) { kfconfig.SharedRepoCache.Evict() }, repoCacheOpts.TTL)
+			return nil
+		})); err != nil {
+			setupLog.Error(err, "unable to set up the repository cache eviction")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&kfdefappskubefloworg.KfDefReconciler{
 		Client:     mgr.GetClient(),
 		Scheme:     mgr.GetScheme(),
 		RestConfig: mgr.GetConfig(),
 		Recorder:   mgr.GetEventRecorderFor("kfdef-controller"),
 		Log:        ctrl.Log.WithName("controllers").WithName("KfDef"),
+
+		ChannelPollInterval: channelPollInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KfDef")
 		os.Exit(1)
 	}
 
 	if err = (&secretgenerator.SecretGeneratorReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("secret-generator-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SecretGenerator")
 		os.Exit(1)
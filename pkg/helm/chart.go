@@ -0,0 +1,37 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package helm renders Helm charts in-process so they can be applied like any other KfDef application.
+// Charts are loaded from a directory or a packaged .tgz and rendered with the Helm template engine, against
+// the capabilities of the cluster. Hooks are rendered as plain manifests; tests and release storage are not
+// supported.
+package helm
+
+import (
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+)
+
+// LoadChart loads the chart at chartPath, which can either be a chart directory or a packaged chart.
+func LoadChart(chartPath string) (*chart.Chart, error) {
+	c, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load chart %v: %v", chartPath, err)
+	}
+	return c, nil
+}
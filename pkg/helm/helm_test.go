@@ -0,0 +1,238 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestRender(t *testing.T) {
+	type testCase struct {
+		name        string
+		values      map[string]interface{}
+		caps        *chartutil.Capabilities
+		contains    []string
+		notContains []string
+	}
+
+	testCases := []testCase{
+		{
+			name: "defaults",
+			contains: []string{
+				"# Source: mychart/templates/deployment.yaml",
+				"name: rel-mychart",
+				"replicas: 1",
+				`image: "quay.io/example/app:1.2.3"`,
+				"    app.kubernetes.io/instance: rel",
+				"# Source: mychart/charts/sub/templates/service.yaml",
+				"- port: 8080",
+				"team: odh",
+				"checksum/config: 3b6a5e83064c150d750ab23cda5897779da4dd38c898c280b0a4145ba17484dd",
+				"    greeting=hello",
+				`kubeVersion: "v1.20.0"`,
+			},
+			notContains: []string{
+				"kind: ServiceAccount",
+				"Thank you",
+				"<no value>",
+				"routes:",
+			},
+		},
+		{
+			name: "cluster capabilities",
+			caps: &chartutil.Capabilities{
+				KubeVersion: chartutil.KubeVersion{Version: "v1.21.0", Major: "1", Minor: "21"},
+				APIVersions: chartutil.VersionSet{"v1", "route.openshift.io/v1", "route.openshift.io/v1/Route"},
+			},
+			contains: []string{
+				`kubeVersion: "v1.21.0"`,
+				`routes: "true"`,
+			},
+		},
+		{
+			name: "overrides",
+			values: map[string]interface{}{
+				"replicas":       float64(3),
+				"image":          map[string]interface{}{"tag": "v2"},
+				"serviceAccount": map[string]interface{}{"create": true},
+				"global":         map[string]interface{}{"team": "data"},
+			},
+			contains: []string{
+				"replicas: 3",
+				`image: "quay.io/example/app:v2"`,
+				"kind: ServiceAccount",
+				"team: data",
+			},
+			notContains: []string{
+				"team: odh",
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		c, err := LoadChart("testdata/mychart")
+		if err != nil {
+			t.Fatalf("Error loading chart: %v", err)
+		}
+		out, err := Render(c, ReleaseOptions{Name: "rel", Namespace: "ns"}, test.values, test.caps)
+		if err != nil {
+			t.Errorf("%v: error rendering chart: %v", test.name, err)
+			continue
+		}
+		for _, s := range test.contains {
+			if !strings.Contains(string(out), s) {
+				t.Errorf("%v: expected output to contain %q; got:\n%v", test.name, s, string(out))
+			}
+		}
+		for _, s := range test.notContains {
+			if strings.Contains(string(out), s) {
+				t.Errorf("%v: expected output not to contain %q; got:\n%v", test.name, s, string(out))
+			}
+		}
+	}
+}
+
+func TestLoadChartArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-test-")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "mychart-0.1.0.tgz")
+	if err := packageChart("testdata/mychart", archive); err != nil {
+		t.Fatalf("Error packaging chart: %v", err)
+	}
+
+	fromDir, err := LoadChart("testdata/mychart")
+	if err != nil {
+		t.Fatalf("Error loading chart directory: %v", err)
+	}
+	fromArchive, err := LoadChart(archive)
+	if err != nil {
+		t.Fatalf("Error loading chart archive: %v", err)
+	}
+	if !reflect.DeepEqual(fromDir.Metadata, fromArchive.Metadata) || !reflect.DeepEqual(fromDir.Values, fromArchive.Values) ||
+		!reflect.DeepEqual(fromDir.Templates, fromArchive.Templates) || !reflect.DeepEqual(fromDir.Files, fromArchive.Files) ||
+		len(fromDir.Dependencies()) != len(fromArchive.Dependencies()) {
+		t.Errorf("chart loaded from archive differs from chart directory")
+	}
+}
+
+func TestRenderIncompatibleKubeVersion(t *testing.T) {
+	c, err := LoadChart("testdata/mychart")
+	if err != nil {
+		t.Fatalf("Error loading chart: %v", err)
+	}
+	c.Metadata.KubeVersion = ">= 1.22.0"
+	caps := &chartutil.Capabilities{KubeVersion: chartutil.KubeVersion{Version: "v1.21.0", Major: "1", Minor: "21"}}
+	if _, err := Render(c, ReleaseOptions{Name: "rel", Namespace: "ns"}, nil, caps); err == nil {
+		t.Errorf("expected an error rendering a chart requiring a newer Kubernetes version")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	dc.FakedServerVersion = &version.Info{GitVersion: "v1.21.3", Major: "1", Minor: "21"}
+	dc.Resources = []*metav1.APIResourceList{
+		{
+			GroupVersion: "route.openshift.io/v1",
+			APIResources: []metav1.APIResource{{Name: "routes", Kind: "Route"}},
+		},
+	}
+
+	caps, err := Capabilities(dc)
+	if err != nil {
+		t.Fatalf("Error getting capabilities: %v", err)
+	}
+	if caps.KubeVersion.Version != "v1.21.3" || caps.KubeVersion.Minor != "21" {
+		t.Errorf("expected the cluster version, got %v", caps.KubeVersion)
+	}
+	for _, v := range []string{"route.openshift.io/v1", "route.openshift.io/v1/Route"} {
+		if !caps.APIVersions.Has(v) {
+			t.Errorf("expected API version %v, got %v", v, caps.APIVersions)
+		}
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	type testCase struct {
+		dst      map[string]interface{}
+		src      map[string]interface{}
+		expected map[string]interface{}
+	}
+
+	testCases := []testCase{
+		{
+			dst:      map[string]interface{}{"a": map[string]interface{}{"b": "1", "c": "2"}},
+			src:      map[string]interface{}{"a": map[string]interface{}{"b": "3"}},
+			expected: map[string]interface{}{"a": map[string]interface{}{"b": "3", "c": "2"}},
+		},
+		{
+			dst:      map[string]interface{}{"a": map[string]interface{}{"b": "1"}},
+			src:      map[string]interface{}{"a": "scalar"},
+			expected: map[string]interface{}{"a": "scalar"},
+		},
+		{
+			dst:      map[string]interface{}{"a": "1", "b": "2"},
+			src:      map[string]interface{}{"a": nil},
+			expected: map[string]interface{}{"b": "2"},
+		},
+	}
+
+	for _, test := range testCases {
+		actual := MergeValues(test.dst, test.src)
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("MergeValues; expect %v, got %v", test.expected, actual)
+		}
+	}
+}
+
+// packageChart writes chartDir as a packaged chart, the way `helm package` lays it out.
+func packageChart(chartDir string, target string) error {
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	return filepath.Walk(chartDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(chartDir, p)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:     filepath.ToSlash(filepath.Join("mychart", rel)),
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	})
+}
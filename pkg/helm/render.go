@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/client-go/discovery"
+)
+
+// notesFile is rendered by helm for display only, it never contains manifests.
+const notesFile = "NOTES.txt"
+
+// ReleaseOptions describe the release exposed to templates as .Release.
+type ReleaseOptions struct {
+	Name      string
+	Namespace string
+	Revision  int
+	IsUpgrade bool
+}
+
+// Capabilities returns the capabilities of the cluster served by dc, exposed to templates as .Capabilities:
+// its Kubernetes version and the group versions and kinds of its APIs.
+func Capabilities(dc discovery.DiscoveryInterface) (*chartutil.Capabilities, error) {
+	version, err := dc.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get the Kubernetes version: %v", err)
+	}
+	groups, resources, err := dc.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("couldn't get the API versions: %v", err)
+	}
+	versions := map[string]bool{}
+	for _, g := range groups {
+		for _, gv := range g.Versions {
+			versions[gv.GroupVersion] = true
+		}
+	}
+	for _, r := range resources {
+		for _, resource := range r.APIResources {
+			versions[path.Join(r.GroupVersion, resource.Kind)] = true
+		}
+	}
+	apiVersions := make([]string, 0, len(versions))
+	for v := range versions {
+		apiVersions = append(apiVersions, v)
+	}
+	sort.Strings(apiVersions)
+
+	caps := chartutil.DefaultCapabilities.Copy()
+	caps.KubeVersion = chartutil.KubeVersion{Version: version.GitVersion, Major: version.Major, Minor: version.Minor}
+	caps.APIVersions = chartutil.VersionSet(apiVersions)
+	return caps, nil
+}
+
+// Render executes the templates of chart c and its dependencies with values coalesced over the chart defaults,
+// against the capabilities caps, or the default capabilities of helm when nil.
+// It returns the manifests as a multi-document YAML stream ordered by template name. Empty documents are dropped.
+func Render(c *chart.Chart, opts ReleaseOptions, values map[string]interface{}, caps *chartutil.Capabilities) ([]byte, error) {
+	if opts.Revision == 0 {
+		opts.Revision = 1
+	}
+	if caps == nil {
+		caps = chartutil.DefaultCapabilities
+	}
+	if c.Metadata.KubeVersion != "" && !chartutil.IsCompatibleRange(c.Metadata.KubeVersion, caps.KubeVersion.String()) {
+		return nil, fmt.Errorf("chart %v requires kubeVersion %v which is incompatible with Kubernetes %v",
+			c.Name(), c.Metadata.KubeVersion, caps.KubeVersion.String())
+	}
+	if err := chartutil.ProcessDependencies(c, values); err != nil {
+		return nil, fmt.Errorf("couldn't process the dependencies of chart %v: %v", c.Name(), err)
+	}
+	renderValues, err := chartutil.ToRenderValues(c, values, chartutil.ReleaseOptions{
+		Name:      opts.Name,
+		Namespace: opts.Namespace,
+		Revision:  opts.Revision,
+		IsInstall: !opts.IsUpgrade,
+		IsUpgrade: opts.IsUpgrade,
+	}, caps)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't compute the values of chart %v: %v", c.Name(), err)
+	}
+	rendered, err := engine.Render(c, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't render chart %v: %v", c.Name(), err)
+	}
+
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var out bytes.Buffer
+	for _, name := range names {
+		if path.Base(name) == notesFile {
+			continue
+		}
+		manifests := releaseutil.SplitManifests(rendered[name])
+		keys := make([]string, 0, len(manifests))
+		for k := range manifests {
+			keys = append(keys, k)
+		}
+		sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+		for _, k := range keys {
+			doc := manifests[k]
+			empty, err := isEmptyDocument(doc)
+			if err != nil {
+				return nil, fmt.Errorf("template %v produced invalid yaml: %v", name, err)
+			}
+			if empty {
+				continue
+			}
+			out.WriteString("---\n# Source: " + name + "\n")
+			out.WriteString(strings.TrimSpace(doc))
+			out.WriteString("\n")
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// isEmptyDocument returns true if doc contains nothing but whitespace and comments.
+func isEmptyDocument(doc string) (bool, error) {
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+		return false, err
+	}
+	return len(obj) == 0, nil
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package coordinator
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -233,7 +234,7 @@ func NewLoadKfAppFromURI(configFile string) (kftypesv3.KfApp, error) {
 	}
 	generateErr := c.Generate(kftypesv3.ALL)
 	if generateErr != nil {
-		return nil, fmt.Errorf("couldn't generate KfApp: %v", generateErr)
+		return nil, fmt.Errorf("couldn't generate KfApp: %w", generateErr)
 	}
 
 	return c, nil
@@ -538,6 +539,11 @@ func (kfapp *coordinator) Generate(resources kftypesv3.ResourceEnum) error {
 		for packageManagerName, packageManager := range kfapp.PackageManagers {
 			packageManagerErr := packageManager.Generate(kftypesv3.K8S)
 			if packageManagerErr != nil {
+				// Keep parameter errors intact so callers can report the individual issues.
+				var paramErr *kustomize.ParameterError
+				if errors.As(packageManagerErr, &paramErr) {
+					return paramErr
+				}
 				return &kfapis.KfError{
 					Code: int(kfapis.INTERNAL_ERROR),
 					Message: fmt.Sprintf("kfApp Generate failed for %v: %v",
@@ -552,6 +558,11 @@ func (kfapp *coordinator) Generate(resources kftypesv3.ResourceEnum) error {
 	usageReportWarn(kfapp.KfDef.Spec.Applications)
 
 	if err := kfapp.KfDef.SyncCache(); err != nil {
+		// Keep verification errors intact so callers can report the failed repository.
+		var verifyErr *kfconfig.RepoVerificationError
+		if errors.As(err, &verifyErr) {
+			return verifyErr
+		}
 		return &kfapis.KfError{
 			Code:    int(kfapis.INTERNAL_ERROR),
 			Message: fmt.Sprintf("could not sync cache. Error: %v", err),
@@ -0,0 +1,91 @@
+package kustomize
+
+import (
+	"fmt"
+
+	kfapisv3 "github.com/opendatahub-io/opendatahub-operator/apis"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// ClusterClients are used to read the live objects while rendering manifests: configurable resources
+// already deployed, existing namespaces and the KfDef instance, and the ConfigMaps and Secrets
+// referenced by the KfDef.
+// A nil *ClusterClients renders offline, as if none of the objects existed in the cluster.
+type ClusterClients struct {
+	Dynamic   dynamic.Interface
+	Mapper    meta.RESTMapper
+	Discovery discovery.DiscoveryInterface
+	Core      corev1.CoreV1Interface
+}
+
+// NewClusterClients returns ClusterClients for the cluster at config.
+func NewClusterClients(config *rest.Config) (*ClusterClients, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting discovery client config %v", err)
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error getting dynamic config %v", err)
+	}
+	core, err := corev1.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get core/v1 client: %v", err)
+	}
+	cached := memory.NewMemCacheClient(dc)
+	return &ClusterClients{
+		Dynamic:   dyn,
+		Mapper:    restmapper.NewDeferredDiscoveryRESTMapper(cached),
+		Discovery: cached,
+		Core:      core,
+	}, nil
+}
+
+// clusterClients returns the clients of the cluster at restConfig, or nil when rendering offline.
+// Rendering is offline only when requested with SetOffline: without a cluster the configurable
+// resources customized by the users would be overwritten, so a missing cluster configuration is an error.
+func (kustomize *kustomize) clusterClients() (*ClusterClients, error) {
+	if kustomize.offline {
+		return nil, nil
+	}
+	if kustomize.clients != nil {
+		return kustomize.clients, nil
+	}
+	if err := kustomize.initK8sClients(); err != nil {
+		return nil, err
+	}
+	if kustomize.restConfig == nil {
+		return nil, &kfapisv3.KfError{
+			Code:    int(kfapisv3.INTERNAL_ERROR),
+			Message: "couldn't load the cluster configuration to read the live objects",
+		}
+	}
+	clients, err := NewClusterClients(kustomize.restConfig)
+	if err != nil {
+		return nil, err
+	}
+	kustomize.clients = clients
+	return clients, nil
+}
+
+// coreClient returns the core/v1 client of the cluster, used to read the ConfigMaps and Secrets
+// referenced for what. They can't be read offline or without a cluster configuration.
+func (kustomize *kustomize) coreClient(what string) (corev1.CoreV1Interface, error) {
+	clients, err := kustomize.clusterClients()
+	if err != nil {
+		return nil, err
+	}
+	if clients == nil || clients.Core == nil {
+		return nil, &kfapisv3.KfError{
+			Code:    int(kfapisv3.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("can not read %v when rendering offline", what),
+		}
+	}
+	return clients.Core, nil
+}
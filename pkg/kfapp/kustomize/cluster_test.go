@@ -0,0 +1,197 @@
+package kustomize
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/kunstruct"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/transformer"
+	"sigs.k8s.io/kustomize/v3/pkg/resmap"
+	"sigs.k8s.io/kustomize/v3/pkg/resource"
+)
+
+const pluginManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: user-config
+  namespace: odh
+  labels:
+    opendatahub.io/configurable: "true"
+data:
+  key: upstream
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: forced-config
+  namespace: odh
+  labels:
+    opendatahub.io/configurable: "true"
+data:
+  key: upstream
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: field-config
+  namespace: odh
+  labels:
+    opendatahub.io/configurable: fields
+  annotations:
+    opendatahub.io/user-owned-fields: /data/key
+data:
+  key: upstream
+  other: upstream
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new-config
+  namespace: odh
+  labels:
+    opendatahub.io/configurable: "true"
+data:
+  key: upstream
+`
+
+func newLiveConfigMap(name string, labels map[string]string, data map[string]interface{}) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"data": data}}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	u.SetName(name)
+	u.SetNamespace("odh")
+	u.SetLabels(labels)
+	return u
+}
+
+func TestUpdateResourcesPlugin(t *testing.T) {
+	fieldConfig := newLiveConfigMap("field-config", nil, map[string]interface{}{"key": "user", "other": "old"})
+	fieldConfig.SetManagedFields([]metav1.ManagedFieldsEntry{{
+		Manager:    "kubectl-edit",
+		Operation:  metav1.ManagedFieldsOperationUpdate,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:key":{}}}`)},
+	}})
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	dyn := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(),
+		newLiveConfigMap("user-config", nil, map[string]interface{}{"key": "user"}),
+		newLiveConfigMap("forced-config", map[string]string{forceUpdateResourcesLabel: "true"}, map[string]interface{}{"key": "user"}),
+		fieldConfig,
+	)
+
+	type testCase struct {
+		name     string
+		clients  *ClusterClients
+		expected map[string]map[string]interface{}
+	}
+	testCases := []testCase{
+		{
+			name:    "offline",
+			clients: nil,
+			expected: map[string]map[string]interface{}{
+				"user-config":   {"key": "upstream"},
+				"forced-config": {"key": "upstream"},
+				"field-config":  {"key": "upstream", "other": "upstream"},
+				"new-config":    {"key": "upstream"},
+			},
+		},
+		{
+			name:    "cluster",
+			clients: &ClusterClients{Dynamic: dyn, Mapper: mapper},
+			expected: map[string]map[string]interface{}{
+				"forced-config": {"key": "upstream"},
+				"field-config":  {"key": nil, "other": "upstream"},
+				"new-config":    {"key": "upstream"},
+			},
+		},
+	}
+
+	rf := resmap.NewFactory(resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl()), transformer.NewFactoryImpl())
+	for _, test := range testCases {
+		m, err := rf.NewResMapFromBytes([]byte(pluginManifests))
+		if err != nil {
+			t.Fatalf("Error parsing manifests: %v", err)
+		}
+		p := &UpdateResourcesPlugin{rmf: rf, clients: test.clients}
+		if err := p.Transform(m); err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		actual := map[string]map[string]interface{}{}
+		for _, r := range m.Resources() {
+			data, _ := r.Map()["data"].(map[string]interface{})
+			actual[r.GetName()] = data
+		}
+		if len(actual) != len(test.expected) {
+			t.Errorf("%v: expect resources %v, got %v", test.name, test.expected, actual)
+			continue
+		}
+		for name, data := range test.expected {
+			for k, v := range data {
+				if actual[name][k] != v {
+					t.Errorf("%v: expect %v data %v=%v, got %v", test.name, name, k, v, actual[name][k])
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateYamlWithOperatorAnnotationExistingNamespace(t *testing.T) {
+	rf := resmap.NewFactory(resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl()), transformer.NewFactoryImpl())
+	m, err := rf.NewResMapFromBytes([]byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: existing\n"))
+	if err != nil {
+		t.Fatalf("Error parsing manifests: %v", err)
+	}
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("v1")
+	existing.SetKind("Namespace")
+	existing.SetName("existing")
+	clients := &ClusterClients{Dynamic: fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), existing)}
+
+	instance := &unstructured.Unstructured{}
+	instance.SetName("operator")
+	instance.SetNamespace("kubeflow")
+	offline, err := GenerateYamlWithOperatorAnnotation(m, instance, nil)
+	if err != nil {
+		t.Fatalf("Error generating yaml offline: %v", err)
+	}
+	if !strings.Contains(string(offline), "kfctl.kubeflow.io/kfdef-instance: operator.kubeflow") {
+		t.Errorf("expected a new namespace to be annotated; got:\n%v", string(offline))
+	}
+	online, err := GenerateYamlWithOperatorAnnotation(m, instance, clients)
+	if err != nil {
+		t.Fatalf("Error generating yaml: %v", err)
+	}
+	if strings.Contains(string(online), "kfctl.kubeflow.io/kfdef-instance") {
+		t.Errorf("expected an existing namespace not to be annotated; got:\n%v", string(online))
+	}
+}
+
+func TestClusterClientsWithoutConfig(t *testing.T) {
+	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	k := &kustomize{}
+	if clients, err := k.clusterClients(); err == nil {
+		t.Errorf("expect an error without a cluster configuration, got %v", clients)
+	}
+	if _, err := k.coreClient("the parameter sources"); err == nil {
+		t.Errorf("expect an error reading ConfigMaps without a cluster configuration")
+	}
+	k.SetOffline(true)
+	if clients, err := k.clusterClients(); err != nil || clients != nil {
+		t.Errorf("expect no clients offline, got %v (%v)", clients, err)
+	}
+	if _, err := k.coreClient("the parameter sources"); err == nil {
+		t.Errorf("expect an error reading ConfigMaps offline")
+	}
+}
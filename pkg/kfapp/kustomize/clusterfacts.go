@@ -0,0 +1,187 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+)
+
+// Names of the discovered cluster variables. They are referenced as $(cluster.<name>) from
+// parameter values, overlay names and the params.env files of kustomize packages.
+const (
+	// ClusterPlatform is "openshift" when the OpenShift config API is served, "kubernetes" otherwise.
+	ClusterPlatform = "platform"
+	// ClusterKubernetesVersion is the git version of the API server, e.g. v1.21.0.
+	ClusterKubernetesVersion = "kubernetesVersion"
+	// ClusterAppsDomain is the default domain of OpenShift routes. It is empty on other platforms.
+	ClusterAppsDomain = "appsDomain"
+	// ClusterOperatorNamespace is the namespace the operator runs in, read from OPERATOR_NAMESPACE.
+	ClusterOperatorNamespace = "operatorNamespace"
+)
+
+const (
+	openshiftPlatform  = "openshift"
+	kubernetesPlatform = "kubernetes"
+)
+
+// clusterFactRef matches a reference to a cluster variable.
+var clusterFactRef = regexp.MustCompile(`\$\(cluster\.([A-Za-z0-9_]+)\)`)
+
+// openshiftIngressGVR is the cluster scoped config holding the OpenShift apps domain.
+var openshiftIngressGVR = schema.GroupVersionResource{
+	Group:    "config.openshift.io",
+	Version:  "v1",
+	Resource: "ingresses",
+}
+
+// ClusterFacts holds variables discovered from the cluster. Discovery runs on the first substitution
+// of a value referencing a variable, so manifests that don't use them don't need a cluster.
+// A nil *ClusterFacts leaves values unchanged.
+type ClusterFacts struct {
+	once     sync.Once
+	discover func() (map[string]string, error)
+	values   map[string]string
+	err      error
+}
+
+// NewClusterFacts returns ClusterFacts with the given values.
+func NewClusterFacts(values map[string]string) *ClusterFacts {
+	return &ClusterFacts{
+		discover: func() (map[string]string, error) {
+			return values, nil
+		},
+	}
+}
+
+// NewDiscoveredClusterFacts returns ClusterFacts discovered with the given clients when first needed.
+func NewDiscoveredClusterFacts(newClients func() (discovery.DiscoveryInterface, dynamic.Interface, error)) *ClusterFacts {
+	return &ClusterFacts{
+		discover: func() (map[string]string, error) {
+			disc, dyn, err := newClients()
+			if err != nil {
+				return nil, err
+			}
+			return DiscoverClusterFacts(disc, dyn)
+		},
+	}
+}
+
+// DiscoverClusterFacts reads the cluster variables using the discovery and OpenShift config APIs.
+func DiscoverClusterFacts(disc discovery.DiscoveryInterface, dyn dynamic.Interface) (map[string]string, error) {
+	values := map[string]string{
+		ClusterPlatform:          kubernetesPlatform,
+		ClusterAppsDomain:        "",
+		ClusterOperatorNamespace: os.Getenv("OPERATOR_NAMESPACE"),
+	}
+
+	version, err := disc.ServerVersion()
+	if err != nil {
+		return nil, errors.WithStack(fmt.Errorf("couldn't get server version: %v", err))
+	}
+	values[ClusterKubernetesVersion] = version.GitVersion
+
+	groups, err := disc.ServerGroups()
+	if err != nil {
+		return nil, errors.WithStack(fmt.Errorf("couldn't get server groups: %v", err))
+	}
+	for _, group := range groups.Groups {
+		if group.Name == openshiftIngressGVR.Group {
+			values[ClusterPlatform] = openshiftPlatform
+		}
+	}
+	if values[ClusterPlatform] != openshiftPlatform {
+		return values, nil
+	}
+
+	ingress, err := dyn.Resource(openshiftIngressGVR).Get(context.TODO(), "cluster", metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return values, nil
+		}
+		return nil, errors.WithStack(fmt.Errorf("couldn't get ingress config: %v", err))
+	}
+	domain, _, err := unstructured.NestedString(ingress.Object, "spec", "domain")
+	if err != nil {
+		return nil, errors.WithStack(fmt.Errorf("invalid ingress config: %v", err))
+	}
+	values[ClusterAppsDomain] = domain
+	return values, nil
+}
+
+// HasClusterFactRefs returns true if value references a cluster variable.
+func HasClusterFactRefs(value string) bool {
+	return clusterFactRef.MatchString(value)
+}
+
+// Substitute replaces the cluster variable references in value with their values.
+// It returns an error if a referenced variable doesn't exist or discovery failed.
+func (f *ClusterFacts) Substitute(value string) (string, error) {
+	if f == nil || !HasClusterFactRefs(value) {
+		return value, nil
+	}
+	f.once.Do(func() {
+		f.values, f.err = f.discover()
+	})
+	if f.err != nil {
+		return "", fmt.Errorf("couldn't discover cluster variables: %v", f.err)
+	}
+
+	unknown := []string{}
+	result := clusterFactRef.ReplaceAllStringFunc(value, func(ref string) string {
+		name := clusterFactRef.FindStringSubmatch(ref)[1]
+		v, ok := f.values[name]
+		if !ok {
+			unknown = append(unknown, name)
+			return ref
+		}
+		return v
+	})
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("unknown cluster variables %v in %q", strings.Join(unknown, ", "), value)
+	}
+	return result, nil
+}
+
+// SubstituteAll returns a copy of values with the cluster variable references replaced.
+func (f *ClusterFacts) SubstituteAll(values []string) ([]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		s, err := f.Substitute(v)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// substituteClusterFacts returns a copy of params with the cluster variable references in values replaced.
+func substituteClusterFacts(facts *ClusterFacts, params []kfconfig.NameValue) ([]kfconfig.NameValue, error) {
+	result := make([]kfconfig.NameValue, 0, len(params))
+	for _, nv := range params {
+		value, err := facts.Substitute(nv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %v: %v", nv.Name, err)
+		}
+		nv.Value = value
+		result = append(result, nv)
+	}
+	return result, nil
+}
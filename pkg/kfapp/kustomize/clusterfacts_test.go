@@ -0,0 +1,113 @@
+package kustomize
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestDiscoverClusterFacts(t *testing.T) {
+	type testCase struct {
+		name      string
+		resources []*metav1.APIResourceList
+		objects   []runtime.Object
+		expected  map[string]string
+	}
+
+	ingress := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "config.openshift.io/v1",
+		"kind":       "Ingress",
+		"metadata":   map[string]interface{}{"name": "cluster"},
+		"spec":       map[string]interface{}{"domain": "apps.example.com"},
+	}}
+
+	testCases := []testCase{
+		{
+			name:      "kubernetes",
+			resources: []*metav1.APIResourceList{{GroupVersion: "apps/v1"}},
+			expected: map[string]string{
+				ClusterPlatform:          "kubernetes",
+				ClusterKubernetesVersion: "v1.21.0",
+				ClusterAppsDomain:        "",
+				ClusterOperatorNamespace: "odh-operator",
+			},
+		},
+		{
+			name:      "openshift",
+			resources: []*metav1.APIResourceList{{GroupVersion: "apps/v1"}, {GroupVersion: "config.openshift.io/v1"}},
+			objects:   []runtime.Object{ingress},
+			expected: map[string]string{
+				ClusterPlatform:          "openshift",
+				ClusterKubernetesVersion: "v1.21.0",
+				ClusterAppsDomain:        "apps.example.com",
+				ClusterOperatorNamespace: "odh-operator",
+			},
+		},
+	}
+
+	t.Setenv("OPERATOR_NAMESPACE", "odh-operator")
+	for _, test := range testCases {
+		disc := &fakediscovery.FakeDiscovery{
+			Fake:               &k8stesting.Fake{Resources: test.resources},
+			FakedServerVersion: &version.Info{GitVersion: "v1.21.0"},
+		}
+		dyn := fakedynamic.NewSimpleDynamicClient(runtime.NewScheme(), test.objects...)
+		actual, err := DiscoverClusterFacts(disc, dyn)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: expect %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestClusterFactsSubstitute(t *testing.T) {
+	type testCase struct {
+		value     string
+		expected  string
+		expectErr bool
+	}
+
+	testCases := []testCase{
+		{value: "plain", expected: "plain"},
+		{value: "dashboard.$(cluster.appsDomain)", expected: "dashboard.apps.example.com"},
+		{value: "$(cluster.platform)-$(cluster.kubernetesVersion)", expected: "openshift-v1.21.0"},
+		{value: "$(cluster.unknown)", expectErr: true},
+	}
+
+	facts := NewClusterFacts(map[string]string{
+		ClusterPlatform:          "openshift",
+		ClusterKubernetesVersion: "v1.21.0",
+		ClusterAppsDomain:        "apps.example.com",
+	})
+	for _, test := range testCases {
+		actual, err := facts.Substitute(test.value)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.value, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("%v: expect %v, got %v", test.value, test.expected, actual)
+		}
+	}
+
+	var noFacts *ClusterFacts
+	if actual, err := noFacts.Substitute("$(cluster.platform)"); err != nil || actual != "$(cluster.platform)" {
+		t.Errorf("nil ClusterFacts should leave values unchanged; got %v, %v", actual, err)
+	}
+}
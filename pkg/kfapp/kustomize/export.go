@@ -0,0 +1,574 @@
+package kustomize
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	kfapisv3 "github.com/opendatahub-io/opendatahub-operator/apis"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/kustomize/v3/pkg/resmap"
+)
+
+const (
+	// exportIndexFile lists the exported applications and their files.
+	exportIndexFile = "index.yaml"
+	// exportConfigMapMaxSize is the maximum size of the data of an export ConfigMap.
+	exportConfigMapMaxSize = 1 << 20
+	// exportConfigMapKeySeparator replaces "/" in the ConfigMap keys of exported files.
+	exportConfigMapKeySeparator = "__"
+	// exportConfigMapOwnerLabel is set to the name of the KfDef on the export ConfigMaps created by the operator.
+	exportConfigMapOwnerLabel = utils.KfDefAnnotation + "/export-of"
+
+	ociImageLayoutVersion  = "1.0.0"
+	ociImageIndexMediaType = "application/vnd.oci.image.index.v1+json"
+	ociManifestMediaType   = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigMediaType     = "application/vnd.oci.image.config.v1+json"
+	ociLayerMediaType      = "application/vnd.oci.image.layer.v1.tar+gzip"
+	ociRefNameAnnotation   = "org.opencontainers.image.ref.name"
+	ociTitleAnnotation     = "org.opencontainers.image.title"
+)
+
+// exportFileNameChars are the characters kept in the names of exported files.
+var exportFileNameChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// ManifestIndex is the content of the index.yaml file of an export.
+type ManifestIndex struct {
+	Name         string                 `json:"name"`
+	Namespace    string                 `json:"namespace"`
+	Applications []ApplicationManifests `json:"applications"`
+}
+
+// ApplicationManifests lists the files of an exported application.
+type ApplicationManifests struct {
+	Name  string         `json:"name"`
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is an exported file and its digest.
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+}
+
+// exportFile is a file of an export, path is relative to the export root.
+type exportFile struct {
+	path string
+	data []byte
+}
+
+// Export renders the applications and writes them to the targets of KfDef.Spec.Export.
+func (kustomize *kustomize) Export() error {
+	spec := kustomize.kfDef.Spec.Export
+	if spec == nil || (spec.Path == "" && spec.ConfigMap == "" && spec.Archive == "") {
+		return &kfapisv3.KfError{
+			Code:    int(kfapisv3.INVALID_ARGUMENT),
+			Message: "export requires one of path, configMap or archive",
+		}
+	}
+	dir, err := exportTarget(spec.Path, kustomize.kfDef.Namespace)
+	if err != nil {
+		return err
+	}
+	archive, err := exportTarget(spec.Archive, kustomize.kfDef.Namespace)
+	if err != nil {
+		return err
+	}
+	files, index, err := kustomize.exportFiles(spec)
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		if err := writeExportDir(dir, files, index); err != nil {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("couldn't export manifests to %v: %v", spec.Path, err),
+			}
+		}
+		log.Infof("Exported manifests to %v", dir)
+	}
+	if archive != "" {
+		if err := writeExportArchive(archive, kustomize.kfDef.Name, files); err != nil {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("couldn't export manifests to %v: %v", spec.Archive, err),
+			}
+		}
+		log.Infof("Exported manifests to %v", archive)
+	}
+	if spec.ConfigMap != "" {
+		client, err := kustomize.coreClient(fmt.Sprintf("export ConfigMap %v", spec.ConfigMap))
+		if err != nil {
+			return err
+		}
+		if err := writeExportConfigMap(client, kustomize.kfDef.Namespace, spec.ConfigMap, kustomize.kfDef.Name, files); err != nil {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("couldn't export manifests to ConfigMap %v: %v", spec.ConfigMap, err),
+			}
+		}
+		log.Infof("Exported manifests to ConfigMap %v/%v", kustomize.kfDef.Namespace, spec.ConfigMap)
+	}
+	return nil
+}
+
+// deleteExport removes the targets of KfDef.Spec.Export. The resources of an exported KfDef are
+// applied by a GitOps tool, which owns them, so they are left alone.
+func (kustomize *kustomize) deleteExport() error {
+	spec := kustomize.kfDef.Spec.Export
+	dir, err := exportTarget(spec.Path, kustomize.kfDef.Namespace)
+	if err != nil {
+		return err
+	}
+	archive, err := exportTarget(spec.Archive, kustomize.kfDef.Namespace)
+	if err != nil {
+		return err
+	}
+	if dir != "" {
+		if err := removeExportDir(dir); err != nil {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("couldn't delete the export of %v: %v", spec.Path, err),
+			}
+		}
+	}
+	if archive != "" {
+		if err := os.Remove(archive); err != nil && !os.IsNotExist(err) {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("couldn't delete the export of %v: %v", spec.Archive, err),
+			}
+		}
+	}
+	if spec.ConfigMap != "" {
+		client, err := kustomize.coreClient(fmt.Sprintf("export ConfigMap %v", spec.ConfigMap))
+		if err != nil {
+			return err
+		}
+		if err := deleteExportConfigMap(client, kustomize.kfDef.Namespace, spec.ConfigMap, kustomize.kfDef.Name); err != nil {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("couldn't delete the export ConfigMap %v: %v", spec.ConfigMap, err),
+			}
+		}
+	}
+	return nil
+}
+
+// exportTarget returns the path of the operator filesystem of an export to p by a KfDef of namespace, or ""
+// when p is empty. p is relative to the directory of the namespace under kfconfig.ExportRoot, or an absolute
+// path under it, so a KfDef can't overwrite or delete the exports of other namespaces.
+func exportTarget(p string, namespace string) (string, error) {
+	if p == "" {
+		return "", nil
+	}
+	if kfconfig.ExportRoot == "" {
+		return "", &kfapisv3.KfError{
+			Code:    int(kfapisv3.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("can not export to %v: exports to the operator filesystem are disabled", p),
+		}
+	}
+	if namespace == "" || namespace == "." || namespace == ".." || strings.ContainsAny(namespace, `/\`) {
+		return "", &kfapisv3.KfError{
+			Code:    int(kfapisv3.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("can not export to %v: invalid namespace %q", p, namespace),
+		}
+	}
+	root := filepath.Join(filepath.Clean(kfconfig.ExportRoot), namespace)
+	target := filepath.Clean(p)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	if target == root || !isUnder(root, target) {
+		return "", &kfapisv3.KfError{
+			Code:    int(kfapisv3.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("can not export to %v: the path isn't under the export root %v", p, root),
+		}
+	}
+	return target, nil
+}
+
+// isUnder returns true if the path p is root or a path under it.
+func isUnder(root string, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// exportFiles renders the applications and returns the files of the export, sorted by path, the index last.
+func (kustomize *kustomize) exportFiles(spec *kfconfig.ExportSpec) ([]exportFile, *ManifestIndex, error) {
+	index := &ManifestIndex{
+		Name:      kustomize.kfDef.Name,
+		Namespace: kustomize.kfDef.Namespace,
+	}
+	appFiles := map[string][]exportFile{}
+	for _, app := range kustomize.kfDef.Spec.Applications {
+		if _, ok := appFiles[app.Name]; ok {
+			continue
+		}
+		resMap, err := kustomize.evaluate(app)
+		if err != nil {
+			return nil, nil, &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("error evaluating kustomization manifest for %v: %v", app.Name, err),
+			}
+		}
+		objects, err := kustomize.exportObjects(resMap, spec.OperatorAnnotations)
+		if err != nil {
+			return nil, nil, &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("can not encode component %v as yaml: %v", app.Name, err),
+			}
+		}
+		manifests, files, err := applicationManifests(app.Name, objects)
+		if err != nil {
+			return nil, nil, &kfapisv3.KfError{
+				Code:    int(kfapisv3.INTERNAL_ERROR),
+				Message: fmt.Sprintf("can not encode component %v as yaml: %v", app.Name, err),
+			}
+		}
+		index.Applications = append(index.Applications, manifests)
+		appFiles[app.Name] = files
+	}
+	sort.Slice(index.Applications, func(i, j int) bool {
+		return index.Applications[i].Name < index.Applications[j].Name
+	})
+
+	files := []exportFile{}
+	for _, app := range index.Applications {
+		files = append(files, appFiles[app.Name]...)
+	}
+	data, err := yaml.Marshal(index)
+	if err != nil {
+		return nil, nil, err
+	}
+	files = append(files, exportFile{path: exportIndexFile, data: data})
+	return files, index, nil
+}
+
+// exportObjects returns the resources of resMap, with the operator annotations if requested.
+func (kustomize *kustomize) exportObjects(resMap resmap.ResMap, operatorAnnotations bool) ([]*unstructured.Unstructured, error) {
+	var annotator *operatorAnnotator
+	if operatorAnnotations {
+		clients, err := kustomize.clusterClients()
+		if err != nil {
+			return nil, err
+		}
+		instance := &unstructured.Unstructured{}
+		instance.SetName(kustomize.kfDef.Name)
+		instance.SetNamespace(kustomize.kfDef.Namespace)
+		annotator = newOperatorAnnotator(instance, clients)
+	}
+	objects := []*unstructured.Unstructured{}
+	err := visitResources(resMap, annotator, func(obj *unstructured.Unstructured) error {
+		objects = append(objects, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// applicationManifests returns the index entry and the files of an application, one file per object
+// named after the kind, group, namespace and name of the object, sorted by name.
+func applicationManifests(appName string, objects []*unstructured.Unstructured) (ApplicationManifests, []exportFile, error) {
+	manifests := ApplicationManifests{Name: appName, Files: []ManifestFile{}}
+	files := []exportFile{}
+	seen := map[string]bool{}
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		parts := []string{strings.ToLower(gvk.Kind)}
+		if gvk.Group != "" {
+			parts[0] += "." + gvk.Group
+		}
+		if obj.GetNamespace() != "" {
+			parts = append(parts, obj.GetNamespace())
+		}
+		parts = append(parts, obj.GetName())
+		name := exportFileNameChars.ReplaceAllString(strings.Join(parts, "_"), "-") + ".yaml"
+		p := path.Join(appName, name)
+		if seen[p] {
+			return manifests, nil, fmt.Errorf("more than one resource is exported to %v", p)
+		}
+		seen[p] = true
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return manifests, nil, err
+		}
+		files = append(files, exportFile{path: p, data: data})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+	for _, f := range files {
+		sum := sha256.Sum256(f.data)
+		manifests.Files = append(manifests.Files, ManifestFile{Path: f.path, Sha256: hex.EncodeToString(sum[:])})
+	}
+	return manifests, files, nil
+}
+
+// removePreviousExport removes the application directories of the export in dir, as listed by its index.
+func removePreviousExport(dir string) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, exportIndexFile))
+	if err != nil {
+		return nil
+	}
+	previous := &ManifestIndex{}
+	if err := yaml.Unmarshal(data, previous); err != nil {
+		return nil
+	}
+	for _, app := range previous.Applications {
+		if app.Name == "" || strings.Contains(app.Name, "..") || strings.ContainsRune(app.Name, '/') {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, app.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeExportDir removes the application directories and the index of the export in dir.
+func removeExportDir(dir string) error {
+	if err := removePreviousExport(dir); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, exportIndexFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// writeExportDir writes files under dir. The application directories of a previous export,
+// as listed by its index, are removed first so deleted resources don't remain.
+func writeExportDir(dir string, files []exportFile, index *ManifestIndex) error {
+	if err := removePreviousExport(dir); err != nil {
+		return err
+	}
+	for _, app := range index.Applications {
+		if err := os.RemoveAll(filepath.Join(dir, app.Name)); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		target := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(target, f.data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportConfigMapData returns the ConfigMap data holding files.
+func exportConfigMapData(files []exportFile) (map[string]string, error) {
+	data := map[string]string{}
+	size := 0
+	for _, f := range files {
+		data[strings.ReplaceAll(f.path, "/", exportConfigMapKeySeparator)] = string(f.data)
+		size += len(f.path) + len(f.data)
+	}
+	if size > exportConfigMapMaxSize {
+		return nil, fmt.Errorf("manifests size %v exceeds the ConfigMap limit of %v bytes", size, exportConfigMapMaxSize)
+	}
+	return data, nil
+}
+
+// writeExportConfigMap creates or updates the ConfigMap name in namespace to hold files, exported by the
+// KfDef owner. A ConfigMap the operator didn't create for owner isn't overwritten.
+// The ConfigMap isn't updated when its data is unchanged.
+func writeExportConfigMap(client corev1.CoreV1Interface, namespace string, name string, owner string, files []exportFile) error {
+	data, err := exportConfigMapData(files)
+	if err != nil {
+		return err
+	}
+	cm, err := client.ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    map[string]string{exportConfigMapOwnerLabel: owner},
+			},
+			Data: data,
+		}
+		_, err = client.ConfigMaps(namespace).Create(context.TODO(), cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Labels[exportConfigMapOwnerLabel] != owner {
+		return fmt.Errorf("ConfigMap %v already exists and isn't maintained by the operator for KfDef %v", name, owner)
+	}
+	if reflect.DeepEqual(cm.Data, data) {
+		return nil
+	}
+	cm.Data = data
+	_, err = client.ConfigMaps(namespace).Update(context.TODO(), cm, metav1.UpdateOptions{})
+	return err
+}
+
+// deleteExportConfigMap deletes the ConfigMap name in namespace if the operator created it for the KfDef owner.
+func deleteExportConfigMap(client corev1.CoreV1Interface, namespace string, name string, owner string) error {
+	cm, err := client.ConfigMaps(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cm.Labels[exportConfigMapOwnerLabel] != owner {
+		log.Infof("ConfigMap %v/%v isn't maintained by the operator for KfDef %v, not deleting it", namespace, name, owner)
+		return nil
+	}
+	err = client.ConfigMaps(namespace).Delete(context.TODO(), name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &cm.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// ociDescriptor describes a blob of an OCI image layout.
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociManifest is an OCI image manifest.
+type ociManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        ociDescriptor     `json:"config"`
+	Layers        []ociDescriptor   `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// ociIndex is the index.json of an OCI image layout.
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// writeTar writes files to a tar stream with fixed modes and times, so the same files give the same bytes.
+func writeTar(w *tar.Writer, files []exportFile) error {
+	for _, f := range files {
+		header := &tar.Header{
+			Name:     f.path,
+			Mode:     0644,
+			Size:     int64(len(f.data)),
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatPAX,
+		}
+		if err := w.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// blob returns the blob file and the descriptor of data.
+func blob(mediaType string, data []byte, annotations map[string]string) (exportFile, ociDescriptor) {
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	return exportFile{path: "blobs/sha256/" + digest, data: data}, ociDescriptor{
+		MediaType:   mediaType,
+		Digest:      "sha256:" + digest,
+		Size:        int64(len(data)),
+		Annotations: annotations,
+	}
+}
+
+// ociImageLayout returns the files of an OCI image layout with a single image, tagged latest, whose
+// only layer holds files.
+func ociImageLayout(name string, files []exportFile) ([]exportFile, error) {
+	layer := &bytes.Buffer{}
+	gz := gzip.NewWriter(layer)
+	if err := writeTar(tar.NewWriter(gz), files); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	layerBlob, layerDesc := blob(ociLayerMediaType, layer.Bytes(), map[string]string{ociTitleAnnotation: name + ".tar.gz"})
+	configBlob, configDesc := blob(ociConfigMediaType, []byte("{}"), nil)
+
+	manifest, err := json.Marshal(ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        configDesc,
+		Layers:        []ociDescriptor{layerDesc},
+		Annotations:   map[string]string{ociTitleAnnotation: name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifestBlob, manifestDesc := blob(ociManifestMediaType, manifest, map[string]string{ociRefNameAnnotation: "latest"})
+	index, err := json.Marshal(ociIndex{
+		SchemaVersion: 2,
+		MediaType:     ociImageIndexMediaType,
+		Manifests:     []ociDescriptor{manifestDesc},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []exportFile{
+		{path: "oci-layout", data: []byte(fmt.Sprintf(`{"imageLayoutVersion":"%v"}`, ociImageLayoutVersion))},
+		{path: "index.json", data: index},
+		configBlob,
+		layerBlob,
+		manifestBlob,
+	}, nil
+}
+
+// writeExportArchive writes files to target as a tarball of an OCI image layout.
+func writeExportArchive(target string, name string, files []exportFile) error {
+	layout, err := ociImageLayout(name, files)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	out := &bytes.Buffer{}
+	if err := writeTar(tar.NewWriter(out), layout); err != nil {
+		return err
+	}
+	// write a temporary file first so readers never see a partial archive
+	tmp := target + ".tmp"
+	if err := ioutil.WriteFile(tmp, out.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
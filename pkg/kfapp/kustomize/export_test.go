@@ -0,0 +1,235 @@
+package kustomize
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	kftypesv3 "github.com/opendatahub-io/opendatahub-operator/apis/apps"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	"github.com/otiai10/copy"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExport(t *testing.T) {
+	appDir, err := ioutil.TempDir("", "export-test-")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(appDir)
+	defer func(root string) { kfconfig.ExportRoot = root }(kfconfig.ExportRoot)
+	kfconfig.ExportRoot = appDir
+	for _, app := range []string{"operator", "dashboard"} {
+		if err := copy.Copy("testdata/operator", path.Join(appDir, outputDir, app)); err != nil {
+			t.Fatalf("Error copying application: %v", err)
+		}
+	}
+	exportDir := filepath.Join(appDir, "kubeflow", "export")
+	// a stale application of a previous export is removed
+	if err := os.MkdirAll(filepath.Join(exportDir, "removed"), 0755); err != nil {
+		t.Fatalf("Error creating stale export: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(exportDir, exportIndexFile),
+		[]byte("applications:\n- name: removed\n"), 0644); err != nil {
+		t.Fatalf("Error creating stale export: %v", err)
+	}
+
+	kfDef := &kfconfig.KfConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "operator", Namespace: "kubeflow"},
+		Spec: kfconfig.KfConfigSpec{
+			AppDir: appDir,
+			Applications: []kfconfig.Application{
+				{Name: "operator", KustomizeConfig: &kfconfig.KustomizeConfig{}},
+				{Name: "dashboard", KustomizeConfig: &kfconfig.KustomizeConfig{}},
+			},
+			Export: &kfconfig.ExportSpec{
+				Path:                "export",
+				Archive:             filepath.Join(appDir, "kubeflow", "archive", "manifests.tar"),
+				OperatorAnnotations: true,
+			},
+		},
+	}
+	k := &kustomize{kfDef: kfDef, offline: true}
+	if err := k.Export(); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(exportDir, exportIndexFile))
+	if err != nil {
+		t.Fatalf("Error reading index: %v", err)
+	}
+	index := &ManifestIndex{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		t.Fatalf("Error parsing index: %v", err)
+	}
+	actualFiles := []string{}
+	for _, app := range index.Applications {
+		for _, f := range app.Files {
+			actualFiles = append(actualFiles, f.Path)
+		}
+	}
+	expectedFiles := []string{"dashboard/service_kubeflow_fake-service.yaml", "operator/service_kubeflow_fake-service.yaml"}
+	if !reflect.DeepEqual(actualFiles, expectedFiles) {
+		t.Errorf("index files; expect %v, got %v", expectedFiles, actualFiles)
+	}
+	expected, err := ioutil.ReadFile("testdata/operator/expected/service.yaml")
+	if err != nil {
+		t.Fatalf("Error reading expected file: %v", err)
+	}
+	actual, err := ioutil.ReadFile(filepath.Join(exportDir, "operator", "service_kubeflow_fake-service.yaml"))
+	if err != nil {
+		t.Fatalf("Error reading exported file: %v", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("exported file; expect:\n%v\ngot:\n%v", string(expected), string(actual))
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, "removed")); !os.IsNotExist(err) {
+		t.Errorf("expected the stale application to be removed")
+	}
+
+	// exporting again gives the same archive
+	archive, err := ioutil.ReadFile(kfDef.Spec.Export.Archive)
+	if err != nil {
+		t.Fatalf("Error reading archive: %v", err)
+	}
+	if err := k.Export(); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+	again, err := ioutil.ReadFile(kfDef.Spec.Export.Archive)
+	if err != nil {
+		t.Fatalf("Error reading archive: %v", err)
+	}
+	if !bytes.Equal(archive, again) {
+		t.Errorf("expected exports of the same manifests to give the same archive")
+	}
+	names := []string{}
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, filepath.Dir(header.Name))
+	}
+	expectedNames := []string{".", ".", "blobs/sha256", "blobs/sha256", "blobs/sha256"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("archive layout; expect %v, got %v", expectedNames, names)
+	}
+
+	// deleting only removes the export
+	if err := k.Delete(kftypesv3.K8S); err != nil {
+		t.Fatalf("Error deleting: %v", err)
+	}
+	for _, p := range []string{filepath.Join(exportDir, "operator"), filepath.Join(exportDir, exportIndexFile),
+		kfDef.Spec.Export.Archive} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("expected %v to be deleted", p)
+		}
+	}
+}
+
+func TestExportTarget(t *testing.T) {
+	defer func(root string) { kfconfig.ExportRoot = root }(kfconfig.ExportRoot)
+
+	cases := map[string]struct {
+		root        string
+		path        string
+		expected    string
+		expectError bool
+	}{
+		"Relative path":                               {root: "/exports", path: "manifests", expected: "/exports/odh/manifests"},
+		"Absolute path under the namespace":           {root: "/exports/", path: "/exports/odh/manifests", expected: "/exports/odh/manifests"},
+		"No path":                                     {root: "/exports", path: "", expected: ""},
+		"Absolute path outside the root":              {root: "/exports", path: "/etc", expectError: true},
+		"Relative path escaping the root":             {root: "/exports", path: "manifests/../../../etc", expectError: true},
+		"Absolute path of another namespace":          {root: "/exports", path: "/exports/other/manifests", expectError: true},
+		"Relative path escaping to another namespace": {root: "/exports", path: "../other/manifests", expectError: true},
+		"Directory of the namespace":                  {root: "/exports", path: "/exports/odh", expectError: true},
+		"Root":                                        {root: "/exports", path: "/exports", expectError: true},
+		"Exports are disabled":                        {root: "", path: "manifests", expectError: true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			kfconfig.ExportRoot = tc.root
+			actual, err := exportTarget(tc.path, "odh")
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expect an error, got %v", actual)
+				}
+				return
+			}
+			if err != nil || actual != tc.expected {
+				t.Errorf("expect %v, got %v (%v)", tc.expected, actual, err)
+			}
+		})
+	}
+}
+
+func TestWriteExportConfigMap(t *testing.T) {
+	userConfigMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "user", Namespace: "odh"},
+		Data:       map[string]string{"key": "value"},
+	}
+	client := fake.NewSimpleClientset(userConfigMap)
+	files := []exportFile{
+		{path: "app/service_odh_svc.yaml", data: []byte("kind: Service\n")},
+		{path: exportIndexFile, data: []byte("name: odh\n")},
+	}
+	for i := 0; i < 2; i++ {
+		if err := writeExportConfigMap(client.CoreV1(), "odh", "manifests", "odh", files); err != nil {
+			t.Fatalf("Error writing ConfigMap: %v", err)
+		}
+	}
+	cm, err := client.CoreV1().ConfigMaps("odh").Get(context.TODO(), "manifests", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting ConfigMap: %v", err)
+	}
+	expected := map[string]string{"app__service_odh_svc.yaml": "kind: Service\n", "index.yaml": "name: odh\n"}
+	if !reflect.DeepEqual(cm.Data, expected) {
+		t.Errorf("ConfigMap data; expect %v, got %v", expected, cm.Data)
+	}
+	updates := 0
+	for _, action := range client.Actions() {
+		if action.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if updates != 0 {
+		t.Errorf("expected an unchanged ConfigMap not to be updated, got %v updates", updates)
+	}
+
+	// the ConfigMaps of the users and of other KfDefs are left alone
+	if err := writeExportConfigMap(client.CoreV1(), "odh", "user", "odh", files); err == nil {
+		t.Errorf("expected an error exporting to a ConfigMap the operator didn't create")
+	}
+	if err := writeExportConfigMap(client.CoreV1(), "odh", "manifests", "other", files); err == nil {
+		t.Errorf("expected an error exporting to the ConfigMap of another KfDef")
+	}
+	for _, name := range []string{"user", "manifests"} {
+		if err := deleteExportConfigMap(client.CoreV1(), "odh", name, "other"); err != nil {
+			t.Fatalf("Error deleting ConfigMap %v: %v", name, err)
+		}
+	}
+	cm, err = client.CoreV1().ConfigMaps("odh").Get(context.TODO(), "user", metav1.GetOptions{})
+	if err != nil || !reflect.DeepEqual(cm.Data, userConfigMap.Data) {
+		t.Errorf("expected the user ConfigMap to be unchanged, got %v (%v)", cm, err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("odh").Get(context.TODO(), "manifests", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the ConfigMap of another KfDef not to be deleted: %v", err)
+	}
+	if err := deleteExportConfigMap(client.CoreV1(), "odh", "manifests", "odh"); err != nil {
+		t.Fatalf("Error deleting ConfigMap: %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("odh").Get(context.TODO(), "manifests", metav1.GetOptions{}); err == nil {
+		t.Errorf("expected the export ConfigMap to be deleted")
+	}
+}
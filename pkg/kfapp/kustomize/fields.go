@@ -0,0 +1,322 @@
+package kustomize
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// configurableFieldsMode is the value of configurableResourcesLabel selecting field-level preservation:
+	// the fields owned by the user are kept and upstream changes are applied to all other fields.
+	configurableFieldsMode = "fields"
+	// userOwnedFieldsAnnotation lists, as comma separated JSON pointers, fields of a configurable resource
+	// whose changes by any field manager other than the operator are preserved.
+	userOwnedFieldsAnnotation = "opendatahub.io/user-owned-fields"
+)
+
+// fieldPathElement selects a map field, a list item by index, or a list item by the value of its key fields.
+type fieldPathElement struct {
+	Field string
+	Index int
+	Key   map[string]interface{}
+}
+
+type fieldPath []fieldPathElement
+
+func (p fieldPath) String() string {
+	parts := make([]string, 0, len(p))
+	for _, e := range p {
+		switch {
+		case e.Key != nil:
+			key, _ := json.Marshal(e.Key)
+			parts = append(parts, string(key))
+		case e.Field != "":
+			parts = append(parts, strings.ReplaceAll(strings.ReplaceAll(e.Field, "~", "~0"), "/", "~1"))
+		default:
+			parts = append(parts, strconv.Itoa(e.Index))
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// parseJSONPointer parses a RFC 6901 JSON pointer. Numeric tokens select list items by index.
+func parseJSONPointer(pointer string) (fieldPath, error) {
+	if !strings.HasPrefix(pointer, "/") || pointer == "/" {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	path := fieldPath{}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 {
+			path = append(path, fieldPathElement{Index: i})
+			continue
+		}
+		if token == "" {
+			return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+		}
+		path = append(path, fieldPathElement{Field: token})
+	}
+	return path, nil
+}
+
+// declaredUserOwnedFields returns the fields listed in the userOwnedFieldsAnnotation of obj.
+func declaredUserOwnedFields(annotations map[string]string) ([]fieldPath, error) {
+	value, ok := annotations[userOwnedFieldsAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	paths := []fieldPath{}
+	for _, pointer := range strings.Split(value, ",") {
+		pointer = strings.TrimSpace(pointer)
+		if pointer == "" {
+			continue
+		}
+		path, err := parseJSONPointer(pointer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v annotation: %v", userOwnedFieldsAnnotation, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// isUserFieldManager returns true if manager is one of the kfconfig.UserFieldManagers of the changes made by
+// users, the operator preserves the fields they manage. The fields managed by controllers, like the replicas set by
+// an autoscaler, are only preserved when declared with userOwnedFieldsAnnotation.
+func isUserFieldManager(manager string) bool {
+	for _, name := range kfconfig.UserFieldManagers {
+		if manager == name || strings.HasPrefix(manager, name+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// managedUserOwnedFields returns the fields of live owned by the user, according to the server-side apply
+// managed fields: the fields managed by the user field managers, and the fields under the declared paths managed by
+// any other field manager than the operator. Fields under status and the metadata maintained by the API server
+// are ignored.
+func managedUserOwnedFields(live *unstructured.Unstructured, declared []fieldPath) ([]fieldPath, error) {
+	declaredLocations := []fieldPath{}
+	for _, path := range declared {
+		if location, ok := locateField(live.Object, path); ok {
+			declaredLocations = append(declaredLocations, location)
+		}
+	}
+	result := []fieldPath{}
+	for _, entry := range live.GetManagedFields() {
+		if entry.Manager == utils.ApplyFieldManager || entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			return nil, fmt.Errorf("invalid managed fields of %v: %v", entry.Manager, err)
+		}
+		for _, path := range collectFieldPaths(fields, fieldPath{}, nil) {
+			if len(path) == 0 || path[0].Field == "status" || path[0].Field == "apiVersion" || path[0].Field == "kind" {
+				continue
+			}
+			if path[0].Field == "metadata" && (len(path) < 3 ||
+				(path[1].Field != "labels" && path[1].Field != "annotations")) {
+				continue
+			}
+			if isUserFieldManager(entry.Manager) || underAny(live.Object, path, declaredLocations) {
+				result = append(result, path)
+			}
+		}
+	}
+	return result, nil
+}
+
+// underAny returns true if path selects a field of obj under one of the locations.
+func underAny(obj map[string]interface{}, path fieldPath, locations []fieldPath) bool {
+	if len(locations) == 0 {
+		return false
+	}
+	location, ok := locateField(obj, path)
+	if !ok {
+		return false
+	}
+	for _, l := range locations {
+		if len(l) <= len(location) && reflect.DeepEqual(l, location[:len(l)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectFieldPaths appends the leaf paths of a FieldsV1 set to paths. The set itself (".") is a leaf only when it
+// has no members, its members are managed separately. Items of sets of scalars ("v:") and of lists identified by position ("i:") aren't supported and are skipped.
+func collectFieldPaths(fields map[string]interface{}, prefix fieldPath, paths []fieldPath) []fieldPath {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var element fieldPathElement
+		switch {
+		case k == ".":
+			if len(fields) == 1 {
+				paths = append(paths, append(fieldPath{}, prefix...))
+			}
+			continue
+		case strings.HasPrefix(k, "f:"):
+			element = fieldPathElement{Field: strings.TrimPrefix(k, "f:")}
+		case strings.HasPrefix(k, "k:"):
+			key := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(k, "k:")), &key); err != nil {
+				continue
+			}
+			element = fieldPathElement{Key: key}
+		default:
+			continue
+		}
+		path := append(append(fieldPath{}, prefix...), element)
+		children, _ := fields[k].(map[string]interface{})
+		if len(children) == 0 {
+			paths = append(paths, path)
+			continue
+		}
+		paths = collectFieldPaths(children, path, paths)
+	}
+	return paths
+}
+
+// matchesKey returns true if item has the values of all the fields of key.
+func matchesKey(item interface{}, key map[string]interface{}) bool {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for k, v := range key {
+		// compare the JSON encodings, numbers of the key are float64 while the object may hold int64
+		expected, err := json.Marshal(v)
+		if err != nil {
+			return false
+		}
+		actual, err := json.Marshal(m[k])
+		if err != nil || string(actual) != string(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// child returns the value selected by e in parent.
+func child(parent interface{}, e fieldPathElement) (interface{}, int, bool) {
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if e.Field == "" {
+			return nil, -1, false
+		}
+		v, ok := p[e.Field]
+		return v, -1, ok
+	case []interface{}:
+		if e.Key != nil {
+			for i, item := range p {
+				if matchesKey(item, e.Key) {
+					return item, i, true
+				}
+			}
+			return nil, -1, false
+		}
+		if e.Field == "" && e.Index < len(p) {
+			return p[e.Index], e.Index, true
+		}
+	}
+	return nil, -1, false
+}
+
+// locateField returns path with the list items selected by index, as they are found in obj.
+func locateField(obj map[string]interface{}, path fieldPath) (fieldPath, bool) {
+	location := fieldPath{}
+	var current interface{} = obj
+	for _, e := range path {
+		v, index, ok := child(current, e)
+		if !ok {
+			return nil, false
+		}
+		if index >= 0 {
+			location = append(location, fieldPathElement{Index: index})
+		} else {
+			location = append(location, fieldPathElement{Field: e.Field})
+		}
+		current = v
+	}
+	return location, true
+}
+
+// removeField removes the value at path from obj. It returns false if obj has no value at path.
+func removeField(obj map[string]interface{}, path fieldPath) bool {
+	if len(path) == 0 {
+		return false
+	}
+	var remove func(parent interface{}, path fieldPath) (interface{}, bool)
+	remove = func(parent interface{}, path fieldPath) (interface{}, bool) {
+		current, index, found := child(parent, path[0])
+		if !found {
+			return nil, false
+		}
+		if len(path) > 1 {
+			updated, ok := remove(current, path[1:])
+			if !ok {
+				return nil, false
+			}
+			switch p := parent.(type) {
+			case map[string]interface{}:
+				p[path[0].Field] = updated
+			case []interface{}:
+				p[index] = updated
+			}
+			return parent, true
+		}
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			delete(p, path[0].Field)
+			return p, true
+		case []interface{}:
+			return append(p[:index:index], p[index+1:]...), true
+		}
+		return nil, false
+	}
+	_, ok := remove(obj, path)
+	return ok
+}
+
+// preserveUserOwnedFields removes the fields owned by the user from desired, so applying desired neither reverts
+// them nor makes the operator a co-owner of them. The user-owned fields are those of managedUserOwnedFields.
+// It returns the removed paths.
+func preserveUserOwnedFields(desired map[string]interface{}, live *unstructured.Unstructured) ([]string, error) {
+	desiredObj := &unstructured.Unstructured{Object: desired}
+	declared, err := declaredUserOwnedFields(desiredObj.GetAnnotations())
+	if err != nil {
+		return nil, err
+	}
+	managed, err := managedUserOwnedFields(live, declared)
+	if err != nil {
+		return nil, err
+	}
+
+	preserved := []string{}
+	for _, path := range managed {
+		if n := len(path); n > 1 && path[n-2].Key != nil {
+			// the key fields identify the list item, the item is kept if the user owns other fields of it
+			if _, ok := path[n-2].Key[path[n-1].Field]; ok {
+				continue
+			}
+		}
+		if removeField(desired, path) {
+			preserved = append(preserved, path.String())
+		}
+	}
+	return preserved, nil
+}
@@ -0,0 +1,191 @@
+package kustomize
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const fieldsDesired = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dashboard
+  labels:
+    opendatahub.io/configurable: fields
+  annotations:
+    opendatahub.io/user-owned-fields: /spec/template/spec/containers/0/resources
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: dashboard
+        image: quay.io/opendatahub/dashboard:v2
+        env:
+        - name: DEBUG
+          value: "false"
+        resources:
+          limits:
+            cpu: 500m
+`
+
+const fieldsLive = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dashboard
+  labels:
+    team: data-science
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: dashboard
+        image: quay.io/opendatahub/dashboard:v1
+        env:
+        - name: DEBUG
+          value: "true"
+        resources:
+          limits:
+            cpu: "2"
+status:
+  replicas: 3
+`
+
+func TestPreserveUserOwnedFields(t *testing.T) {
+	desired := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(fieldsDesired), &desired); err != nil {
+		t.Fatalf("Error parsing desired object: %v", err)
+	}
+	live := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(fieldsLive), &live.Object); err != nil {
+		t.Fatalf("Error parsing live object: %v", err)
+	}
+	live.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager:    utils.ApplyFieldManager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{` +
+				`"k:{\"name\":\"dashboard\"}":{".":{},"f:image":{},"f:name":{},"f:resources":{"f:limits":{"f:cpu":{}}}}}}}}}}`)},
+		},
+		{
+			Manager:    "kubectl-edit",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:team":{}}},"f:spec":{` +
+				`"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"dashboard\"}":{"f:env":{".":{},` +
+				`"k:{\"name\":\"DEBUG\"}":{".":{},"f:name":{},"f:value":{}}}}}}}}}}`)},
+		},
+		{
+			Manager:    "kube-controller-manager",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}},"f:status":{"f:replicas":{}}}`)},
+		},
+		{
+			Manager:    "Mozilla",
+			Operation:  metav1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{` +
+				`"k:{\"name\":\"dashboard\"}":{"f:resources":{"f:limits":{"f:cpu":{}}}}}}}}}`)},
+		},
+	})
+
+	preserved, err := preserveUserOwnedFields(desired, live)
+	if err != nil {
+		t.Fatalf("Error preserving fields: %v", err)
+	}
+
+	expected := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dashboard
+  labels:
+    opendatahub.io/configurable: fields
+  annotations:
+    opendatahub.io/user-owned-fields: /spec/template/spec/containers/0/resources
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: dashboard
+        image: quay.io/opendatahub/dashboard:v2
+        env:
+        - name: DEBUG
+        resources:
+          limits: {}
+`), &expected); err != nil {
+		t.Fatalf("Error parsing expected object: %v", err)
+	}
+	expectedYaml, _ := yaml.Marshal(expected)
+	actualYaml, _ := yaml.Marshal(desired)
+	if string(expectedYaml) != string(actualYaml) {
+		t.Errorf("preserveUserOwnedFields; expect:\n%v\ngot:\n%v", string(expectedYaml), string(actualYaml))
+	}
+	expectedPaths := []string{
+		`/spec/template/spec/containers/{"name":"dashboard"}/env/{"name":"DEBUG"}/value`,
+		`/spec/template/spec/containers/{"name":"dashboard"}/resources/limits/cpu`,
+	}
+	if !reflect.DeepEqual(preserved, expectedPaths) {
+		t.Errorf("preserved paths; expect %v, got %v", expectedPaths, preserved)
+	}
+}
+
+func TestParseJSONPointer(t *testing.T) {
+	path, err := parseJSONPointer("/metadata/annotations/opendatahub.io~1owner/0")
+	if err != nil {
+		t.Fatalf("Error parsing pointer: %v", err)
+	}
+	expected := fieldPath{{Field: "metadata"}, {Field: "annotations"}, {Field: "opendatahub.io/owner"}, {Index: 0}}
+	if !reflect.DeepEqual(path, expected) {
+		t.Errorf("parseJSONPointer; expect %v, got %v", expected, path)
+	}
+	if path.String() != "/metadata/annotations/opendatahub.io~1owner/0" {
+		t.Errorf("fieldPath.String; got %v", path.String())
+	}
+	for _, invalid := range []string{"", "/", "spec", "/spec//replicas"} {
+		if _, err := parseJSONPointer(invalid); err == nil {
+			t.Errorf("parseJSONPointer(%q); expected an error", invalid)
+		}
+	}
+}
+
+func TestIsUserFieldManager(t *testing.T) {
+	defer func(managers []string) { kfconfig.UserFieldManagers = managers }(kfconfig.UserFieldManagers)
+
+	type testCase struct {
+		managers []string
+		manager  string
+		expected bool
+	}
+	testCases := []testCase{
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "kubectl-edit", expected: true},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "kubectl-client-side-apply", expected: true},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "oc", expected: true},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "oc-edit", expected: true},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "Mozilla", expected: true},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "kube-controller-manager", expected: false},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: "octopus", expected: false},
+		{managers: kfconfig.DefaultUserFieldManagers, manager: utils.ApplyFieldManager, expected: false},
+		{managers: []string{"argocd-controller"}, manager: "argocd-controller", expected: true},
+		{managers: []string{"argocd-controller"}, manager: "kubectl-edit", expected: false},
+		{managers: nil, manager: "kubectl-edit", expected: false},
+	}
+	for _, c := range testCases {
+		kfconfig.UserFieldManagers = c.managers
+		if actual := isUserFieldManager(c.manager); actual != c.expected {
+			t.Errorf("isUserFieldManager(%q) with %v; expect %v, got %v", c.manager, c.managers, c.expected, actual)
+		}
+	}
+}
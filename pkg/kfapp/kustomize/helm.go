@@ -0,0 +1,209 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/helm"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	"github.com/otiai10/copy"
+	log "github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/kunstruct"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/transformer"
+	"sigs.k8s.io/kustomize/v3/pkg/resmap"
+	"sigs.k8s.io/kustomize/v3/pkg/resource"
+	"sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+const (
+	// helmChartArchive is the name a packaged chart is copied to in the application directory.
+	helmChartArchive = "chart.tgz"
+	// defaultHelmValuesKey is the ConfigMap key read when a HelmValuesReference doesn't set one.
+	defaultHelmValuesKey = "values.yaml"
+)
+
+// evaluate returns the resources of app, rendering its Helm chart or its generated kustomize package.
+func (kustomize *kustomize) evaluate(app kfconfig.Application) (resmap.ResMap, error) {
+	appDir := path.Join(kustomize.kfDef.Spec.AppDir, outputDir, app.Name)
+	clients, err := kustomize.clusterClients()
+	if err != nil {
+		return nil, err
+	}
+	if app.HelmConfig != nil {
+		return kustomize.evaluateHelmChart(app, appDir, clients)
+	}
+	return EvaluateKustomizeManifest(appDir, clients)
+}
+
+// helmChartSource returns the location of the chart referenced by helmConfig. A chartPath is read under
+// kfconfig.ChartRoot and the path of a repoRef under the cache of the repository, so a KfDef can't read the other
+// files of the operator filesystem.
+func (kustomize *kustomize) helmChartSource(appName string, helmConfig *kfconfig.HelmConfig) (string, error) {
+	if helmConfig.RepoRef != nil && helmConfig.ChartPath != "" {
+		return "", fmt.Errorf("application %v sets both helmConfig.repoRef and helmConfig.chartPath", appName)
+	}
+	if helmConfig.ChartPath != "" {
+		if kfconfig.ChartRoot == "" {
+			return "", fmt.Errorf("application %v can not use chart %v: charts of the operator filesystem are disabled",
+				appName, helmConfig.ChartPath)
+		}
+		chartPath := filepath.Clean(helmConfig.ChartPath)
+		if !filepath.IsAbs(chartPath) {
+			chartPath = filepath.Join(kfconfig.ChartRoot, chartPath)
+		}
+		return containedChartPath(appName, kfconfig.ChartRoot, chartPath)
+	}
+	if helmConfig.RepoRef == nil {
+		return "", fmt.Errorf("application %v must set one of helmConfig.repoRef or helmConfig.chartPath", appName)
+	}
+	repoCache, ok := kustomize.kfDef.GetRepoCache(helmConfig.RepoRef.Name)
+	if !ok {
+		return "", fmt.Errorf("application %v refers to repo %v which wasn't found in KfDef.Status.ReposCache",
+			appName, helmConfig.RepoRef.Name)
+	}
+	return containedChartPath(appName, repoCache.LocalPath, filepath.Join(repoCache.LocalPath, helmConfig.RepoRef.Path))
+}
+
+// containedChartPath returns chartPath with its symlinks resolved, or an error if it isn't under root.
+func containedChartPath(appName string, root string, chartPath string) (string, error) {
+	resolvedRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("couldn't find the charts directory %v of application %v: %v", root, appName, err)
+	}
+	resolved, err := filepath.EvalSymlinks(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("couldn't find chart for application %v: %v", appName, err)
+	}
+	if !isUnder(resolvedRoot, resolved) {
+		return "", fmt.Errorf("chart %v of application %v isn't under %v", chartPath, appName, root)
+	}
+	return resolved, nil
+}
+
+// generateHelmApp validates the chart of app and copies it to appDir, so it can be rendered again on apply and delete.
+func (kustomize *kustomize) generateHelmApp(app kfconfig.Application, appDir string) error {
+	chartPath, err := kustomize.helmChartSource(app.Name, app.HelmConfig)
+	if err != nil {
+		return err
+	}
+	if _, err := helm.LoadChart(chartPath); err != nil {
+		return fmt.Errorf("couldn't load chart for application %v: %v", app.Name, err)
+	}
+	fi, err := os.Stat(chartPath)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return copy.Copy(chartPath, appDir)
+	}
+	if err := os.MkdirAll(appDir, os.ModePerm); err != nil {
+		return err
+	}
+	return copy.Copy(chartPath, filepath.Join(appDir, helmChartArchive))
+}
+
+// evaluateHelmChart renders the chart copied to appDir and returns the resources.
+// Namespaced resources without a namespace are placed in the release namespace, like helm does.
+func (kustomize *kustomize) evaluateHelmChart(app kfconfig.Application, appDir string, clients *ClusterClients) (resmap.ResMap, error) {
+	chartPath := appDir
+	if _, err := os.Stat(filepath.Join(appDir, helmChartArchive)); err == nil {
+		chartPath = filepath.Join(appDir, helmChartArchive)
+	}
+	chart, err := helm.LoadChart(chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := kustomize.helmValues(app)
+	if err != nil {
+		return nil, err
+	}
+	opts := helm.ReleaseOptions{
+		Name:      app.HelmConfig.ReleaseName,
+		Namespace: app.HelmConfig.Namespace,
+	}
+	if opts.Name == "" {
+		opts.Name = app.Name
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = kustomize.kfDef.Namespace
+	}
+	// offline, the chart is rendered against the default capabilities of helm, like helm template does
+	var caps *chartutil.Capabilities
+	if clients != nil && clients.Discovery != nil {
+		if caps, err = helm.Capabilities(clients.Discovery); err != nil {
+			return nil, err
+		}
+	}
+	data, err := helm.Render(chart, opts, values, caps)
+	if err != nil {
+		return nil, err
+	}
+
+	rf := resmap.NewFactory(resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl()), transformer.NewFactoryImpl())
+	resMap, err := rf.NewResMapFromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse rendered chart: %v", err)
+	}
+	for _, r := range resMap.Resources() {
+		if r.GetNamespace() == "" && r.GetGvk().IsNamespaceableKind() {
+			r.SetNamespace(opts.Namespace)
+		}
+	}
+
+	customPlugin := &UpdateResourcesPlugin{
+		rmf:        rf,
+		clients:    clients,
+		ObjectMeta: types.ObjectMeta{},
+		Spec:       Spec{},
+	}
+	if err := customPlugin.Transform(resMap); err != nil {
+		log.Warn("Error during custom transform", err)
+		return nil, err
+	}
+	return resMap, nil
+}
+
+// helmValues merges the values of every HelmValuesReference in order, then the inline values.
+func (kustomize *kustomize) helmValues(app kfconfig.Application) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if len(app.HelmConfig.ValuesFrom) > 0 {
+		corev1client, err := kustomize.coreClient(fmt.Sprintf("the values ConfigMaps of application %v", app.Name))
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range app.HelmConfig.ValuesFrom {
+			key := ref.Key
+			if key == "" {
+				key = defaultHelmValuesKey
+			}
+			cm, err := corev1client.ConfigMaps(kustomize.kfDef.Namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get values ConfigMap %v for application %v: %v", ref.Name, app.Name, err)
+			}
+			data, ok := cm.Data[key]
+			if !ok {
+				return nil, fmt.Errorf("values ConfigMap %v for application %v has no key %v", ref.Name, app.Name, key)
+			}
+			refValues, err := helm.ParseValues([]byte(data))
+			if err != nil {
+				return nil, fmt.Errorf("invalid values in ConfigMap %v key %v: %v", ref.Name, key, err)
+			}
+			values = helm.MergeValues(values, refValues)
+		}
+	}
+	if app.HelmConfig.Values != nil && len(app.HelmConfig.Values.Raw) > 0 {
+		inline := map[string]interface{}{}
+		if err := yaml.Unmarshal(app.HelmConfig.Values.Raw, &inline); err != nil {
+			return nil, fmt.Errorf("invalid helmConfig.values for application %v: %v", app.Name, err)
+		}
+		values = helm.MergeValues(values, inline)
+	}
+	return values, nil
+}
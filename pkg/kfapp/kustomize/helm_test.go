@@ -0,0 +1,78 @@
+package kustomize
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+)
+
+func TestHelmChartSource(t *testing.T) {
+	defer func(root string) { kfconfig.ChartRoot = root }(kfconfig.ChartRoot)
+
+	dir := t.TempDir()
+	chartRoot := filepath.Join(dir, "charts")
+	repoDir := filepath.Join(dir, "repos", "manifests")
+	for _, d := range []string{filepath.Join(chartRoot, "dashboard"), filepath.Join(repoDir, "charts", "dashboard")} {
+		if err := os.MkdirAll(d, os.ModePerm); err != nil {
+			t.Fatalf("Error creating %v: %v", d, err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "secret.tgz"), []byte("secret"), 0600); err != nil {
+		t.Fatalf("Error writing file: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.tgz"), filepath.Join(repoDir, "charts", "link.tgz")); err != nil {
+		t.Fatalf("Error creating symlink: %v", err)
+	}
+	k := &kustomize{kfDef: &kfconfig.KfConfig{Status: kfconfig.Status{
+		Caches: []kfconfig.Cache{{Name: "manifests", LocalPath: repoDir}},
+	}}}
+
+	type testCase struct {
+		name      string
+		root      string
+		config    kfconfig.HelmConfig
+		expected  string
+		expectErr bool
+	}
+	testCases := []testCase{
+		{name: "relative chartPath", root: chartRoot, config: kfconfig.HelmConfig{ChartPath: "dashboard"},
+			expected: filepath.Join(chartRoot, "dashboard")},
+		{name: "absolute chartPath", root: chartRoot, config: kfconfig.HelmConfig{ChartPath: filepath.Join(chartRoot, "dashboard")},
+			expected: filepath.Join(chartRoot, "dashboard")},
+		{name: "chartPath outside the root", root: chartRoot, config: kfconfig.HelmConfig{ChartPath: "../secret.tgz"}, expectErr: true},
+		{name: "absolute chartPath outside the root", root: chartRoot, config: kfconfig.HelmConfig{ChartPath: filepath.Join(dir, "secret.tgz")},
+			expectErr: true},
+		{name: "chartPath disabled", config: kfconfig.HelmConfig{ChartPath: "dashboard"}, expectErr: true},
+		{name: "repoRef", config: kfconfig.HelmConfig{RepoRef: &kfconfig.RepoRef{Name: "manifests", Path: "charts/dashboard"}},
+			expected: filepath.Join(repoDir, "charts", "dashboard")},
+		{name: "repoRef root", config: kfconfig.HelmConfig{RepoRef: &kfconfig.RepoRef{Name: "manifests"}}, expected: repoDir},
+		{name: "repoRef outside the repo", config: kfconfig.HelmConfig{RepoRef: &kfconfig.RepoRef{Name: "manifests", Path: "../../secret.tgz"}},
+			expectErr: true},
+		{name: "repoRef symlink outside the repo", config: kfconfig.HelmConfig{RepoRef: &kfconfig.RepoRef{Name: "manifests", Path: "charts/link.tgz"}},
+			expectErr: true},
+		{name: "unknown repo", config: kfconfig.HelmConfig{RepoRef: &kfconfig.RepoRef{Name: "other"}}, expectErr: true},
+		{name: "both", root: chartRoot, config: kfconfig.HelmConfig{RepoRef: &kfconfig.RepoRef{Name: "manifests"}, ChartPath: "dashboard"},
+			expectErr: true},
+		{name: "none", config: kfconfig.HelmConfig{}, expectErr: true},
+	}
+	for _, c := range testCases {
+		kfconfig.ChartRoot = c.root
+		actual, err := k.helmChartSource("dashboard", &c.config)
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("%v: expected an error, got %v", c.name, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.name, err)
+			continue
+		}
+		if expected, _ := filepath.EvalSymlinks(c.expected); actual != expected {
+			t.Errorf("%v: expected %v, got %v", c.name, expected, actual)
+		}
+	}
+}
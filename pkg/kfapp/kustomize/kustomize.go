@@ -24,7 +24,6 @@ import (
 	"fmt"
 	"io/ioutil"
 	"k8s.io/client-go/discovery"
-	"k8s.io/client-go/restmapper"
 	"math/rand"
 	"os"
 	"path"
@@ -49,10 +48,10 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	crdclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	rbacv1 "k8s.io/client-go/kubernetes/typed/rbac/v1"
@@ -102,6 +101,7 @@ const (
 	patchesJson6902Map       MapType = 11
 	OverlayParamName                 = "overlay"
 	// configurableResourcesLabel is a label added by odh dev to specify which objects can be updated.
+	// With "true" the whole object is left to the user, with "fields" only the fields owned by the user are kept.
 	configurableResourcesLabel = "opendatahub.io/configurable"
 	// forceUpdateResourcesLabel is a label added by end user to specify that an object needs to be patched with latest
 	// changes irrespective of the modified label.
@@ -118,6 +118,9 @@ type kustomize struct {
 	restConfig       *rest.Config
 	// when set to true, apply() will skip local kube config, directly build config from restConfig
 	configOverwrite bool
+	// when set to true, manifests are rendered without reading live objects from the cluster
+	offline bool
+	clients *ClusterClients
 }
 
 const (
@@ -128,6 +131,7 @@ const (
 // Setter defines an interface for modifying the plugin.
 type Setter interface {
 	SetK8sRestConfig(r *rest.Config)
+	SetOffline(offline bool)
 }
 
 // GetKfApp is the common entry point for all implementations of the KfApp interface
@@ -159,19 +163,27 @@ func (kustomize *kustomize) initK8sClients() error {
 	return nil
 }
 
-func (kustomize *kustomize) render(app kfconfig.Application) ([]byte, error) {
-	kustomizeDir := path.Join(kustomize.kfDef.Spec.AppDir, outputDir)
-	resMap, err := EvaluateKustomizeManifest(path.Join(kustomizeDir, app.Name))
-	if err != nil {
-		log.Errorf("Error evaluating kustomization manifest for %v: %v", app.Name, err)
-		return nil, &kfapisv3.KfError{
-			Code:    int(kfapisv3.INTERNAL_ERROR),
-			Message: fmt.Sprintf("error evaluating kustomization manifest for %v: %v", app.Name, err),
+// clusterFacts returns the cluster variables of the cluster at restConfig, discovered when first referenced.
+func (kustomize *kustomize) clusterFacts() *ClusterFacts {
+	return NewDiscoveredClusterFacts(func() (discovery.DiscoveryInterface, dynamic.Interface, error) {
+		if err := kustomize.initK8sClients(); err != nil {
+			return nil, nil, err
 		}
-	}
-
-	sortResourceByKind(resMap, utils.InstallOrder)
+		disc, err := discovery.NewDiscoveryClientForConfig(kustomize.restConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		dyn, err := dynamic.NewForConfig(kustomize.restConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		return disc, dyn, nil
+	})
+}
 
+// operatorAnnotator returns the annotator of the resources installed through the operator,
+// or nil if the KfDef doesn't request the operator annotation.
+func (kustomize *kustomize) operatorAnnotator() (*operatorAnnotator, error) {
 	// check to set owner references for resources if installed through kubeflow operator
 	annotations := kustomize.kfDef.GetAnnotations()
 	setOperatorAnnotation := false
@@ -180,48 +192,69 @@ func (kustomize *kustomize) render(app kfconfig.Application) ([]byte, error) {
 			setOperatorAnnotation = setOperatorBool
 		}
 	}
+	if !setOperatorAnnotation {
+		return nil, nil
+	}
 
-	//TODO this should be streamed
-	var data []byte
-	if setOperatorAnnotation {
-		// retrieve the UID of the KfDef resource using dynamic client
-		config, _ := rest.InClusterConfig()
-		dyn, err := dynamic.NewForConfig(config)
-		if err != nil {
-			return nil, &kfapisv3.KfError{
-				Code:    int(kfapisv3.INTERNAL_ERROR),
-				Message: fmt.Sprintf("failed to create dynamic client: %v", err),
-			}
+	clients, err := kustomize.clusterClients()
+	if err != nil {
+		return nil, &kfapisv3.KfError{
+			Code:    int(kfapisv3.INTERNAL_ERROR),
+			Message: fmt.Sprintf("failed to create cluster clients: %v", err),
 		}
+	}
+	instance := &unstructured.Unstructured{}
+	instance.SetName(kustomize.kfDef.GetName())
+	instance.SetNamespace(kustomize.kfDef.GetNamespace())
+	if clients != nil {
+		// retrieve the KfDef resource using dynamic client
 		kfDefRes := schema.GroupVersionResource{Group: "kfdef.apps.kubeflow.org", Version: "v1", Resource: "kfdefs"}
-		instance, err := dyn.Resource(kfDefRes).Namespace(kustomize.kfDef.GetNamespace()).Get(context.TODO(), kustomize.kfDef.GetName(), metav1.GetOptions{})
+		instance, err = clients.Dynamic.Resource(kfDefRes).Namespace(kustomize.kfDef.GetNamespace()).Get(context.TODO(), kustomize.kfDef.GetName(), metav1.GetOptions{})
 		if err != nil {
 			return nil, &kfapisv3.KfError{
 				Code:    int(kfapisv3.INTERNAL_ERROR),
 				Message: fmt.Sprintf("failed to get the KfDef object: %v", err),
 			}
 		}
-		data, err = GenerateYamlWithOperatorAnnotation(resMap, instance)
-		if err != nil {
-			return nil, &kfapisv3.KfError{
-				Code:    int(kfapisv3.INTERNAL_ERROR),
-				Message: fmt.Sprintf("can not encode component %v as yaml: %v", app.Name, err),
-			}
+	}
+	return newOperatorAnnotator(instance, clients), nil
+}
+
+// render evaluates app and calls visit with each of its resources, in install order.
+// The resources are streamed one object at a time and never encoded as a single document.
+func (kustomize *kustomize) render(app kfconfig.Application, visit ObjectVisitor) error {
+	resMap, err := kustomize.evaluate(app)
+	if err != nil {
+		log.Errorf("Error evaluating kustomization manifest for %v: %v", app.Name, err)
+		return &kfapisv3.KfError{
+			Code:    int(kfapisv3.INTERNAL_ERROR),
+			Message: fmt.Sprintf("error evaluating kustomization manifest for %v: %v", app.Name, err),
 		}
-	} else {
-		data, err = resMap.AsYaml()
-		if err != nil {
-			return nil, &kfapisv3.KfError{
-				Code:    int(kfapisv3.INTERNAL_ERROR),
-				Message: fmt.Sprintf("can not encode component %v as yaml: %v", app.Name, err),
-			}
+	}
+
+	sortResourceByKind(resMap, utils.InstallOrder)
+
+	annotator, err := kustomize.operatorAnnotator()
+	if err != nil {
+		return err
+	}
+	if err = visitResources(resMap, annotator, visit); err != nil {
+		if _, ok := err.(*kfapisv3.KfError); ok {
+			return err
+		}
+		return &kfapisv3.KfError{
+			Code:    int(kfapisv3.INTERNAL_ERROR),
+			Message: fmt.Sprintf("can not encode component %v as yaml: %v", app.Name, err),
 		}
 	}
-	return data, nil
+	return nil
 }
 
-// Dump prints the kustomize generated resources to stdout
+// Dump prints the kustomize generated resources to stdout, or exports them when KfDef.Spec.Export is set
 func (kustomize *kustomize) Dump(resources kftypesv3.ResourceEnum) error {
+	if kustomize.kfDef.Spec.Export != nil {
+		return kustomize.Export()
+	}
 
 	applications := make(map[string]bool)
 	for _, app := range kustomize.kfDef.Spec.Applications {
@@ -231,18 +264,20 @@ func (kustomize *kustomize) Dump(resources kftypesv3.ResourceEnum) error {
 		}
 		applications[app.Name] = true
 
-		data, err := kustomize.render(app)
-		if err != nil {
+		if err := kustomize.render(app, yamlWriter(os.Stdout)); err != nil {
 			return err
 		}
-		fmt.Println(string(data))
 		fmt.Println("---")
 	}
 	return nil
 }
 
-// Apply deploys kustomize generated resources to the kubenetes api server
+// Apply deploys kustomize generated resources to the kubenetes api server.
+// When KfDef.Spec.Export is set the resources are exported for a GitOps tool instead.
 func (kustomize *kustomize) Apply(resources kftypesv3.ResourceEnum) error {
+	if kustomize.kfDef.Spec.Export != nil {
+		return kustomize.Export()
+	}
 	var restConfig *rest.Config = nil
 	if kustomize.configOverwrite && kustomize.restConfig != nil {
 		restConfig = kustomize.restConfig
@@ -275,26 +310,49 @@ func (kustomize *kustomize) Apply(resources kftypesv3.ResourceEnum) error {
 		applications[app.Name] = true
 
 		log.Infof("Deploying application %v", app.Name)
-		data, err := kustomize.render(app)
-		if err != nil {
-			return err
-		}
 
 		// TODO(https://github.com/kubeflow/manifests/issues/806): Bump the timeout because cert-manager takes
 		// a long time to start. Any application that needs to create a certificate will fail because it won't
 		// be able to create certificates if cert-manager is unavailable. We should try to identify Permanent Errors
 		// and return a PermanentError to avoid retrying and taking 10 minutes to fail.
-		b := utils.NewDefaultBackoff()
-		b.MaxElapsedTime = 10 * time.Minute
-		err = backoff.RetryNotify(
-			func() error {
-				return apply.Apply(data)
-			},
-			b,
-			func(e error, duration time.Duration) {
-				log.Warnf("Encountered error applying application %v: %v", app.Name, e)
-				log.Warnf("Will retry in %.0f seconds.", duration.Seconds())
-			})
+		// Objects are applied as they are rendered. The objects failing to apply, e.g. because they depend on
+		// another object of the application, are retried after the others, with one backoff per application.
+		var failed []*unstructured.Unstructured
+		err = kustomize.render(app, func(obj *unstructured.Unstructured) error {
+			if err := apply.ApplyObject(obj); err != nil {
+				log.Warnf("Encountered error applying application %v: %v", app.Name, err)
+				failed = append(failed, obj)
+			}
+			return nil
+		})
+		if err == nil && len(failed) > 0 {
+			b := utils.NewDefaultBackoff()
+			b.MaxElapsedTime = 10 * time.Minute
+			err = backoff.RetryNotify(
+				func() error {
+					var errs []string
+					remaining := failed[:0]
+					for _, obj := range failed {
+						if err := apply.ApplyObject(obj); err != nil {
+							errs = append(errs, err.Error())
+							remaining = append(remaining, obj)
+						}
+					}
+					failed = remaining
+					if len(errs) > 0 {
+						return &kfapisv3.KfError{
+							Code:    int(kfapisv3.INTERNAL_ERROR),
+							Message: fmt.Sprintf("couldn't apply %v objects: %v", len(errs), strings.Join(errs, "; ")),
+						}
+					}
+					return nil
+				},
+				b,
+				func(e error, duration time.Duration) {
+					log.Warnf("Encountered error applying application %v: %v", app.Name, e)
+					log.Warnf("Will retry in %.0f seconds.", duration.Seconds())
+				})
+		}
 		if err != nil {
 			log.Errorf("Permanently failed applying application %v: %v", app.Name, err)
 			return err
@@ -374,8 +432,12 @@ func (kustomize *kustomize) deleteGlobalResources() error {
 	return nil
 }
 
-// Delete is called from 'kfctl delete ...'. Will delete all resources deployed from the Apply method
+// Delete is called from 'kfctl delete ...'. Will delete all resources deployed from the Apply method,
+// or only the export when KfDef.Spec.Export is set.
 func (kustomize *kustomize) Delete(resources kftypesv3.ResourceEnum) error {
+	if kustomize.kfDef.Spec.Export != nil {
+		return kustomize.deleteExport()
+	}
 	annotations := kustomize.kfDef.GetAnnotations()
 	forceDelete := false
 	if forceDel, ok := annotations[strings.Join([]string{utils.KfDefAnnotation, utils.ForceDelete}, "/")]; ok {
@@ -432,12 +494,11 @@ func (kustomize *kustomize) Delete(resources kftypesv3.ResourceEnum) error {
 	}
 
 	// Delete in reverse application order
-	kustomizeDir := path.Join(kustomize.kfDef.Spec.AppDir, outputDir)
 	errList := []error{}
 	for idx := range kustomize.kfDef.Spec.Applications {
 		app := &kustomize.kfDef.Spec.Applications[len(kustomize.kfDef.Spec.Applications)-1-idx]
 		log.Infof("Deleting application %v", app.Name)
-		resMap, err := EvaluateKustomizeManifest(path.Join(kustomizeDir, app.Name))
+		resMap, err := kustomize.evaluate(*app)
 		if err != nil {
 			log.Errorf("Error evaluating kustomization manifest for %v: %v", app.Name, err)
 			return &kfapisv3.KfError{
@@ -537,6 +598,7 @@ func sortResourceByKind(resMap resmap.ResMap, order utils.SortOrder) {
 func (kustomize *kustomize) Generate(resources kftypesv3.ResourceEnum) error {
 	generate := func() error {
 		kustomizeDir := path.Join(kustomize.kfDef.Spec.AppDir, outputDir)
+		facts := kustomize.clusterFacts()
 
 		if _, err := os.Stat(kustomizeDir); err == nil {
 			// When using the new stacks code the directory might already exist because it could have
@@ -577,11 +639,30 @@ func (kustomize *kustomize) Generate(resources kftypesv3.ResourceEnum) error {
 
 		// determine whether we are using the new pattern of using kustomize to build stacks.
 		// hasStack := kustomize.kfDef.UsingStacks()
+		parameterIssues := []ParameterIssue{}
 		for _, app := range kustomize.kfDef.Spec.Applications {
 			log.Infof("Processing application: %v", app.Name)
 
+			if app.HelmConfig != nil {
+				if app.KustomizeConfig != nil {
+					err := fmt.Errorf("application %v sets both KustomizeConfig and HelmConfig", app.Name)
+					log.Errorf("%v", err)
+					return &kfapisv3.KfError{
+						Code:    int(kfapisv3.INVALID_ARGUMENT),
+						Message: err.Error(),
+					}
+				}
+				if err := kustomize.generateHelmApp(app, path.Join(kustomizeDir, app.Name)); err != nil {
+					return &kfapisv3.KfError{
+						Code:    int(kfapisv3.INTERNAL_ERROR),
+						Message: fmt.Sprintf("couldn't generate helm application %s: %v", app.Name, err),
+					}
+				}
+				continue
+			}
+
 			if app.KustomizeConfig == nil {
-				err := fmt.Errorf("application %v is missing KustomizeConfig", app.Name)
+				err := fmt.Errorf("application %v is missing KustomizeConfig or HelmConfig", app.Name)
 				log.Errorf("%v", err)
 				return &kfapisv3.KfError{
 					Code:    int(kfapisv3.INTERNAL_ERROR),
@@ -637,8 +718,48 @@ func (kustomize *kustomize) Generate(resources kftypesv3.ResourceEnum) error {
 						Message: fmt.Sprintf("couldn't copy application %s: %v", app.Name, err),
 					}
 				}
+				overlays, err := facts.SubstituteAll(app.KustomizeConfig.Overlays)
+				if err != nil {
+					return &kfapisv3.KfError{
+						Code:    int(kfapisv3.INVALID_ARGUMENT),
+						Message: fmt.Sprintf("couldn't resolve overlays for component %s: %v", app.Name, err),
+					}
+				}
+				schema, err := LoadParameterSchema(path.Join(kustomizeDir, app.Name), overlays)
+				if err != nil {
+					return &kfapisv3.KfError{
+						Code:    int(kfapisv3.INVALID_ARGUMENT),
+						Message: fmt.Sprintf("couldn't load parameter schema for component %s: %v", app.Name, err),
+					}
+				}
+				params := app.KustomizeConfig.Parameters
+				if hasParameterSources(params) {
+					corev1client, err := kustomize.coreClient(fmt.Sprintf("the parameter sources of component %s", app.Name))
+					if err != nil {
+						return err
+					}
+					params, err = ResolveParameters(corev1client, kustomize.kfDef.Namespace, params)
+					if err != nil {
+						return &kfapisv3.KfError{
+							Code:    int(kfapisv3.INVALID_ARGUMENT),
+							Message: fmt.Sprintf("couldn't resolve parameters for component %s: %v", app.Name, err),
+						}
+					}
+				}
+				params, err = substituteClusterFacts(facts, params)
+				if err != nil {
+					return &kfapisv3.KfError{
+						Code:    int(kfapisv3.INVALID_ARGUMENT),
+						Message: fmt.Sprintf("couldn't resolve parameters for component %s: %v", app.Name, err),
+					}
+				}
+				params, issues := ValidateParameters(app.Name, schema, params)
+				if len(issues) > 0 {
+					parameterIssues = append(parameterIssues, issues...)
+					continue
+				}
 				if err := GenerateKustomizationFile(kustomize.kfDef, kustomizeDir, app.Name,
-					app.KustomizeConfig.Overlays, app.KustomizeConfig.Parameters); err != nil {
+					overlays, params, app.KustomizeConfig, facts); err != nil {
 					return &kfapisv3.KfError{
 						Code:    int(kfapisv3.INTERNAL_ERROR),
 						Message: fmt.Sprintf("couldn't generate kustomization file for component %s: %v", app.Name, err),
@@ -646,6 +767,11 @@ func (kustomize *kustomize) Generate(resources kftypesv3.ResourceEnum) error {
 				}
 			}
 		}
+		if len(parameterIssues) > 0 {
+			// Remove the partially generated applications so the parameters are validated again on the next run.
+			_ = os.RemoveAll(kustomizeDir)
+			return &ParameterError{Issues: parameterIssues}
+		}
 		return nil
 	}
 
@@ -656,7 +782,7 @@ func (kustomize *kustomize) Generate(resources kftypesv3.ResourceEnum) error {
 	case kftypesv3.K8S:
 		generateErr := generate()
 		if generateErr != nil {
-			return fmt.Errorf("Kustomize generate failed: %v", generateErr)
+			return fmt.Errorf("Kustomize generate failed: %w", generateErr)
 		}
 	}
 	return nil
@@ -790,6 +916,13 @@ func (kustomize *kustomize) mapDirs(dirPath string, root bool, depth int, leafMa
 func (kustomize *kustomize) SetK8sRestConfig(r *rest.Config) {
 	kustomize.restConfig = r
 	kustomize.configOverwrite = true
+	kustomize.clients = nil
+}
+
+// SetOffline renders the manifests without a cluster: configurable resources are always rendered
+// and namespaces are considered new.
+func (kustomize *kustomize) SetOffline(offline bool) {
+	kustomize.offline = offline
 }
 
 // GetKustomization will read a kustomization.yaml and return Kustomization type
@@ -854,11 +987,19 @@ func WriteKfDef(kfdef *kfdefsv3.KfDef, kfdefpath string) error {
 // which exclude NamePrefixes, NameSuffixes, CommonLabels, CommonAnnotations.
 // Any of these will generate an error
 func MergeKustomization(compDir string, targetDir string, kfDef *kfconfig.KfConfig, params []kfconfig.NameValue,
-	parent *types.Kustomization, child *types.Kustomization, kustomizationMaps map[MapType]map[string]bool) error {
+	parent *types.Kustomization, child *types.Kustomization, kustomizationMaps map[MapType]map[string]bool,
+	facts *ClusterFacts) error {
 
 	paramMap := make(map[string]string)
 	for _, nv := range params {
-		paramMap[nv.Name] = nv.Value
+		value, err := facts.Substitute(nv.Value)
+		if err != nil {
+			return &kfapisv3.KfError{
+				Code:    int(kfapisv3.INVALID_ARGUMENT),
+				Message: fmt.Sprintf("could not resolve parameter %v: %v", nv.Name, err),
+			}
+		}
+		paramMap[nv.Name] = value
 	}
 	updateParamFiles := func() error {
 		paramFile := filepath.Join(targetDir, kftypesv3.KustomizationParamFile)
@@ -896,6 +1037,15 @@ func MergeKustomization(compDir string, targetDir string, kfDef *kfconfig.KfConf
 						params[i] = paramName + "=" + kfDef.Spec.Project
 					}
 				}
+				// the defaults in params.env may reference cluster variables as well
+				resolved, err := facts.Substitute(params[i])
+				if err != nil {
+					return &kfapisv3.KfError{
+						Code:    int(kfapisv3.INVALID_ARGUMENT),
+						Message: fmt.Sprintf("could not resolve %v in %v: %v", paramName, paramFile, err),
+					}
+				}
+				params[i] = resolved
 			}
 			paramFileErr = writeLines(params, paramFile)
 			if paramFileErr != nil {
@@ -1093,7 +1243,8 @@ func MergeKustomization(compDir string, targetDir string, kfDef *kfconfig.KfConf
 
 // MergeKustomizations will merge base and all overlay kustomization files into
 // a single kustomization file
-func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams []string, params []kfconfig.NameValue) (*types.Kustomization, error) {
+func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams []string, params []kfconfig.NameValue,
+	facts *ClusterFacts) (*types.Kustomization, error) {
 	kustomizationMaps := CreateKustomizationMaps()
 	kustomization := &types.Kustomization{
 		TypeMeta: types.TypeMeta{
@@ -1121,7 +1272,7 @@ func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams
 			return comp, nil
 		}
 	} else {
-		err := MergeKustomization(compDir, baseDir, kfDef, params, kustomization, base, kustomizationMaps)
+		err := MergeKustomization(compDir, baseDir, kfDef, params, kustomization, base, kustomizationMaps, facts)
 		if err != nil {
 			return nil, &kfapisv3.KfError{
 				Code:    int(kfapisv3.INTERNAL_ERROR),
@@ -1138,11 +1289,18 @@ func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams
 			}
 		}
 	}
+	overlayParams, err := facts.SubstituteAll(overlayParams)
+	if err != nil {
+		return nil, &kfapisv3.KfError{
+			Code:    int(kfapisv3.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("could not resolve overlays for component at %v: %v", compDir, err),
+		}
+	}
 	for _, overlayParam := range overlayParams {
 		overlayDir := path.Join(compDir, "overlays", overlayParam)
 		if _, err := os.Stat(overlayDir); err == nil {
 			err := MergeKustomization(compDir, overlayDir, kfDef, params, kustomization,
-				GetKustomization(overlayDir), kustomizationMaps)
+				GetKustomization(overlayDir), kustomizationMaps, facts)
 			if err != nil {
 				return nil, &kfapisv3.KfError{
 					Code:    int(kfapisv3.INTERNAL_ERROR),
@@ -1207,6 +1365,48 @@ func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams
 	return kustomization, nil
 }
 
+// mergeInlineCustomizations adds the inline patches and images of kustomizeConfig to kustomization.
+// Patches are kept inline in the generated kustomization, images replace any image of the same name.
+func mergeInlineCustomizations(kustomization *types.Kustomization, kustomizeConfig *kfconfig.KustomizeConfig) {
+	if kustomizeConfig == nil {
+		return
+	}
+	for _, patch := range kustomizeConfig.PatchesStrategicMerge {
+		kustomization.PatchesStrategicMerge = append(kustomization.PatchesStrategicMerge, types.PatchStrategicMerge(patch))
+	}
+	for _, patch := range kustomizeConfig.PatchesJson6902 {
+		target := &types.PatchTarget{
+			Name:      patch.Target.Name,
+			Namespace: patch.Target.Namespace,
+		}
+		target.Group = patch.Target.Group
+		target.Version = patch.Target.Version
+		target.Kind = patch.Target.Kind
+		kustomization.PatchesJson6902 = append(kustomization.PatchesJson6902, types.PatchJson6902{
+			Target: target,
+			Patch:  patch.Patch,
+		})
+	}
+	for _, img := range kustomizeConfig.Images {
+		override := image.Image{
+			Name:    img.Name,
+			NewName: img.NewName,
+			NewTag:  img.NewTag,
+			Digest:  img.Digest,
+		}
+		replaced := false
+		for i, existing := range kustomization.Images {
+			if existing.Name == img.Name {
+				kustomization.Images[i] = override
+				replaced = true
+			}
+		}
+		if !replaced {
+			kustomization.Images = append(kustomization.Images, override)
+		}
+	}
+}
+
 // GenerateKustomizationFile will create a kustomization.yaml
 // It will parse a args structure that provides mixin or multiple overlays to be merged with the base kustomization file
 // for example
@@ -1216,6 +1416,9 @@ func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams
 //    - name: overlay
 //      value: namespaced-gangscheduled
 //
+// Cluster variable references ($(cluster.<name>)) in overlays, parameter values and params.env files
+// are replaced using facts.
+//
 // TODO(https://github.com/kubeflow/kubeflow/issues/3491): As part of fixing the discovery
 // logic we should change the KfDef spec to provide a list of applications (not a map).
 // and preserve order when applying them so we can get rid of the logic hard-coding
@@ -1227,11 +1430,14 @@ func MergeKustomizations(kfDef *kfconfig.KfConfig, compDir string, overlayParams
 // for KfDef. Presumably this is because of the code in coordinator which is using it to generate
 // KfDef from overlays. But this function is also used to generate the manifests for the individual
 // kustomize packages.
+//
+// The inline patches and images of kustomizeConfig, if set, are added after the overlays are merged.
 func GenerateKustomizationFile(kfDef *kfconfig.KfConfig, root string,
-	compPath string, overlays []string, params []kfconfig.NameValue) error {
+	compPath string, overlays []string, params []kfconfig.NameValue, kustomizeConfig *kfconfig.KustomizeConfig,
+	facts *ClusterFacts) error {
 
 	compDir := path.Join(root, compPath)
-	kustomization, kustomizationErr := MergeKustomizations(kfDef, compDir, overlays, params)
+	kustomization, kustomizationErr := MergeKustomizations(kfDef, compDir, overlays, params, facts)
 	if kustomizationErr != nil {
 		return kustomizationErr
 	}
@@ -1267,6 +1473,7 @@ func GenerateKustomizationFile(kfDef *kfconfig.KfConfig, root string,
 			kustomization.PatchesStrategicMerge = nil
 		}
 	}
+	mergeInlineCustomizations(kustomization, kustomizeConfig)
 	buf, bufErr := yaml.Marshal(kustomization)
 	if bufErr != nil {
 		return bufErr
@@ -1277,7 +1484,8 @@ func GenerateKustomizationFile(kfDef *kfconfig.KfConfig, root string,
 }
 
 // EvaluateKustomizeManifest evaluates the kustomize dir compDir, and returns the resources.
-func EvaluateKustomizeManifest(compDir string) (resmap.ResMap, error) {
+// The live objects are read with clients, which may be nil to evaluate offline.
+func EvaluateKustomizeManifest(compDir string, clients *ClusterClients) (resmap.ResMap, error) {
 	fsys := fs.MakeFsOnDisk()
 	// We don't enforce the security check because our kustomize packages are such that kustomization.yaml
 	// files may refer to patches and resources that are not in the current directory or below them.
@@ -1312,6 +1520,7 @@ func EvaluateKustomizeManifest(compDir string) (resmap.ResMap, error) {
 		rmf:        rf,
 		ldr:        ldr,
 		c:          nil,
+		clients:    clients,
 		ObjectMeta: types.ObjectMeta{},
 		Spec:       Spec{},
 	}
@@ -1402,74 +1611,11 @@ func CreateKustomizationMaps() map[MapType]map[string]bool {
 
 // GenerateYamlWithOperatorAnnotation adds operator info to the annotation to every resource
 // some code copied from ResMap.AsYaml() func
-func GenerateYamlWithOperatorAnnotation(resMap resmap.ResMap, instance *unstructured.Unstructured) ([]byte, error) {
-	addAnnotation := true
-	firstObj := true
-	var b []byte
-	buf := bytes.NewBuffer(b)
-	for _, res := range resMap.Resources() {
-		y, err := res.AsYAML()
-		if err != nil {
-			return nil, err
-		}
-		m := &unstructured.Unstructured{}
-		if err = yaml.Unmarshal(y, m); err != nil {
-			return nil, err
-		}
-
-		anns := m.GetAnnotations()
-		if anns == nil {
-			anns = map[string]string{}
-		}
-		kfdefAnn := strings.Join([]string{utils.KfDefAnnotation, utils.KfDefInstance}, "/")
-		kfdefCr := strings.Join([]string{instance.GetName(), instance.GetNamespace()}, ".")
-
-		if m.GetKind() == "Namespace" {
-			config, _ := rest.InClusterConfig()
-			corev1client, err := corev1.NewForConfig(config)
-			if err != nil {
-				return nil, &kfapisv3.KfError{
-					Code:    int(kfapisv3.INTERNAL_ERROR),
-					Message: fmt.Sprintf("failed to create corev1 client: %v", err),
-				}
-			}
-			_, err = corev1client.Namespaces().Get(context.TODO(), m.GetName(), metav1.GetOptions{})
-			if err == nil {
-				log.Infof("Namespace %v already exists.", m.GetName())
-
-				_, found := anns[kfdefAnn]
-				if !found || anns[kfdefAnn] != kfdefCr {
-					// if the namespace is not created by this operator, should not append the annotation
-					addAnnotation = false
-				}
-			}
-		} else if m.GetKind() == "CustomResourceDefinition" && m.GetName() == "profiles.kubeflow.org" {
-			// profiles will contain user info and data, should not remove during uninstall
-			addAnnotation = false
-		}
-
-		if addAnnotation {
-			anns[kfdefAnn] = kfdefCr
-			m.SetAnnotations(anns)
-		}
-		out, err := yaml.Marshal(m)
-		if err != nil {
-			return nil, err
-		}
-		if addAnnotation {
-			log.Infof("KfDef annotation added for resource %v.%v", m.GetName(), m.GetNamespace())
-		}
-
-		if firstObj {
-			firstObj = false
-		} else {
-			if _, err = buf.WriteString("---\n"); err != nil {
-				return nil, err
-			}
-		}
-		if _, err = buf.Write(out); err != nil {
-			return nil, err
-		}
+// Existing namespaces are looked up with clients. When clients is nil, every namespace is considered new.
+func GenerateYamlWithOperatorAnnotation(resMap resmap.ResMap, instance *unstructured.Unstructured, clients *ClusterClients) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := visitResources(resMap, newOperatorAnnotator(instance, clients), yamlWriter(buf)); err != nil {
+		return nil, err
 	}
 	return buf.Bytes(), nil
 }
@@ -1482,6 +1628,8 @@ type plugin struct {
 	rmf *resmap.Factory
 	ldr ifc.Loader
 	c   *resmap.Configurable
+	// clients read the live objects, the plugin doesn't change the resources when nil
+	clients *ClusterClients
 
 	types.ObjectMeta `json:"metadata,omitempty" yaml:"metadata,omitempty" protobuf:"bytes,1,opt,name=metadata"`
 	Spec             Spec `yaml:"spec"`
@@ -1499,26 +1647,12 @@ func (p *UpdateResourcesPlugin) Config(ldr ifc.Loader, rf *resmap.Factory, c []b
 
 func (p *UpdateResourcesPlugin) Transform(m resmap.ResMap) error {
 	log.Info("Inside the transform function")
-	inClusterconfig, err := rest.InClusterConfig()
-	if err != nil {
-		return fmt.Errorf("error getting incluster config %v", err)
-	}
-	dc, err := discovery.NewDiscoveryClientForConfig(inClusterconfig)
-	if err != nil {
-		return fmt.Errorf("error getting discovery client config %v", err)
-
-	}
-	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
-
-	// 2. Prepare the dynamic client
-	dyn, err := dynamic.NewForConfig(inClusterconfig)
-	if err != nil {
-
-		return fmt.Errorf("error getting dynamic config %v", err)
+	if p.clients == nil {
+		log.Info("No cluster clients, configurable resources are rendered as is")
+		return nil
 	}
-
 	for _, r := range m.Resources() {
-		err := updateResMap(r, mapper, dyn, m)
+		err := updateResMap(r, p.clients.Mapper, p.clients.Dynamic, m)
 		if err != nil {
 			return err
 		}
@@ -1526,7 +1660,7 @@ func (p *UpdateResourcesPlugin) Transform(m resmap.ResMap) error {
 	return nil
 }
 
-func updateResMap(localResource *resource.Resource, mapper *restmapper.DeferredDiscoveryRESTMapper, dyn dynamic.Interface, m resmap.ResMap) error {
+func updateResMap(localResource *resource.Resource, mapper meta.RESTMapper, dyn dynamic.Interface, m resmap.ResMap) error {
 	localObjectLabels := localResource.GetLabels()
 
 	mapping, err := mapper.RESTMapping(schema.GroupKind{
@@ -1555,6 +1689,18 @@ func updateResMap(localResource *resource.Resource, mapper *restmapper.DeferredD
 			} else {
 				log.Printf("Resource is %v removed from resource map", localResource.GetName())
 			}
+		} else if configLabelval == configurableFieldsMode {
+			needsUpdateLabelVal, ok := clusterObjectLabels[forceUpdateResourcesLabel]
+			if ok && needsUpdateLabelVal == "true" {
+				return nil
+			}
+			preserved, err := preserveUserOwnedFields(localResource.Map(), res)
+			if err != nil {
+				return fmt.Errorf("error preserving user owned fields of %v: %v", localResource.GetName(), err)
+			}
+			if len(preserved) > 0 {
+				log.Infof("Left the user owned fields of %v to the user: %v", localResource.GetName(), strings.Join(preserved, ", "))
+			}
 		}
 	}
 	return nil
@@ -25,6 +25,8 @@ func TestGenerateKustomizationFile(t *testing.T) {
 		// The directory of a (testing) kustomize package
 		packageDir string
 		overlays   []string
+		// Inline customizations, may be nil
+		kustomizeConfig *kfconfig.KustomizeConfig
 		// Expected kustomization.yaml
 		expectedFile string
 	}
@@ -57,6 +59,41 @@ func TestGenerateKustomizationFile(t *testing.T) {
 			packageDir:   "testdata/kustomizeExample/metadata",
 			expectedFile: "testdata/kustomizeExample/metadata/expected/kustomization.yaml",
 		},
+		{
+			kfDef: &kfconfig.KfConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "kubeflow",
+				},
+				Spec: kfconfig.KfConfigSpec{},
+			},
+			overlays: []string{
+				"application",
+			},
+			kustomizeConfig: &kfconfig.KustomizeConfig{
+				PatchesStrategicMerge: []string{
+					"apiVersion: extensions/v1beta1\nkind: Deployment\nmetadata:\n  name: pytorch-operator\nspec:\n  replicas: 2\n",
+				},
+				PatchesJson6902: []kfconfig.JSONPatch{
+					{
+						Target: kfconfig.PatchTarget{
+							Group:   "extensions",
+							Version: "v1beta1",
+							Kind:    "Deployment",
+							Name:    "pytorch-operator",
+						},
+						Patch: "- op: add\n  path: /spec/template/spec/priorityClassName\n  value: high\n",
+					},
+				},
+				Images: []kfconfig.Image{
+					{
+						Name:   "gcr.io/kubeflow-images-public/pytorch-operator",
+						NewTag: "v1.0.0",
+					},
+				},
+			},
+			packageDir:   "testdata/kustomizeExample/pytorch-operator",
+			expectedFile: "testdata/kustomizeExample/pytorch-operator/expected/kustomization_inline.yaml",
+		},
 	}
 	packageName := "dummy"
 	for _, c := range testCases {
@@ -69,7 +106,7 @@ func TestGenerateKustomizationFile(t *testing.T) {
 		if err != nil {
 			t.Fatalf("Failed to copy package to temp dir: %v", err)
 		}
-		err = GenerateKustomizationFile(c.kfDef, testDir, packageName, c.overlays, c.params)
+		err = GenerateKustomizationFile(c.kfDef, testDir, packageName, c.overlays, c.params, c.kustomizeConfig, nil)
 		if err != nil {
 			t.Fatalf("Failed to GenerateKustomizationFile: %v", err)
 		}
@@ -107,11 +144,11 @@ func TestGenerateYamlWithOperatorAnnotation(t *testing.T) {
 	instance.SetNamespace("kubeflow")
 
 	for _, c := range testCases {
-		resMap, err := EvaluateKustomizeManifest(c.appDir)
+		resMap, err := EvaluateKustomizeManifest(c.appDir, nil)
 		if err != nil {
 			t.Fatalf("Failed to evaluate manifest. Error: %v.", err)
 		}
-		actual, err := GenerateYamlWithOperatorAnnotation(resMap, instance)
+		actual, err := GenerateYamlWithOperatorAnnotation(resMap, instance, nil)
 		if err != nil {
 			t.Fatalf("Failed to add owner reference. Error: %v.", err)
 		}
@@ -0,0 +1,278 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	kftypesv3 "github.com/opendatahub-io/opendatahub-operator/apis/apps"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// ParameterType is the type a parameter value must parse as.
+type ParameterType string
+
+const (
+	StringParameter  ParameterType = "string"
+	IntegerParameter ParameterType = "integer"
+	NumberParameter  ParameterType = "number"
+	BooleanParameter ParameterType = "boolean"
+)
+
+// Reasons reported in a ParameterIssue.
+const (
+	UnknownParameterReason = "Unknown"
+	InvalidParameterReason = "Invalid"
+	MissingParameterReason = "Missing"
+)
+
+// ParameterSpec describes a parameter of a kustomize package, as listed in params.schema.yaml.
+type ParameterSpec struct {
+	Name        string        `json:"name"`
+	Type        ParameterType `json:"type,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Default     string        `json:"default,omitempty"`
+	Enum        []string      `json:"enum,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
+// ParameterSchema is the content of a params.schema.yaml file.
+// The schema is optional: parameters of packages without one are not validated.
+type ParameterSchema struct {
+	Parameters []ParameterSpec `json:"parameters,omitempty"`
+}
+
+// ParameterIssue describes a KfDef parameter that doesn't satisfy the schema of its application.
+type ParameterIssue struct {
+	Application string
+	Name        string
+	Reason      string
+	Message     string
+}
+
+// ParameterError is returned by Generate when application parameters don't satisfy their schema.
+type ParameterError struct {
+	Issues []ParameterIssue
+}
+
+func (e *ParameterError) Error() string {
+	msgs := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		msgs = append(msgs, fmt.Sprintf("%v/%v: %v", issue.Application, issue.Name, issue.Message))
+	}
+	return fmt.Sprintf("invalid application parameters: %v", strings.Join(msgs, "; "))
+}
+
+// builtinParameters are filled from the KfDef by MergeKustomization and are always accepted.
+var builtinParameters = map[string]bool{
+	"appName":        true,
+	"namespace":      true,
+	"project":        true,
+	OverlayParamName: true,
+}
+
+// LoadParameterSchema reads the parameter schema of the kustomize package at compDir.
+// The schemas of base and of the selected overlays are merged, later definitions replacing earlier ones.
+// It returns nil if none of them has a schema.
+func LoadParameterSchema(compDir string, overlays []string) (*ParameterSchema, error) {
+	dirs := []string{compDir, path.Join(compDir, "base")}
+	for _, overlay := range overlays {
+		dirs = append(dirs, path.Join(compDir, "overlays", overlay))
+	}
+
+	var schema *ParameterSchema
+	index := map[string]int{}
+	for _, dir := range dirs {
+		schemaFile := path.Join(dir, kftypesv3.KustomizationParamSchemaFile)
+		data, err := ioutil.ReadFile(schemaFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read %v: %v", schemaFile, err)
+		}
+		s := &ParameterSchema{}
+		if err := yaml.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("couldn't parse %v: %v", schemaFile, err)
+		}
+		if schema == nil {
+			schema = &ParameterSchema{}
+		}
+		for _, spec := range s.Parameters {
+			if spec.Name == "" {
+				return nil, fmt.Errorf("%v has a parameter without a name", schemaFile)
+			}
+			if spec.Default != "" {
+				if msg := checkParameterValue(spec, spec.Default); msg != "" {
+					return nil, fmt.Errorf("%v has an invalid default for %v: %v", schemaFile, spec.Name, msg)
+				}
+			}
+			if i, ok := index[spec.Name]; ok {
+				schema.Parameters[i] = spec
+				continue
+			}
+			index[spec.Name] = len(schema.Parameters)
+			schema.Parameters = append(schema.Parameters, spec)
+		}
+	}
+	return schema, nil
+}
+
+// ValidateParameters checks params against schema and returns the parameters with defaults added for
+// the schema parameters that aren't set. Unknown parameters, values that don't match the parameter type
+// or enum and required parameters without a value are reported as issues.
+func ValidateParameters(appName string, schema *ParameterSchema, params []kfconfig.NameValue) ([]kfconfig.NameValue, []ParameterIssue) {
+	if schema == nil {
+		return params, nil
+	}
+	specs := map[string]ParameterSpec{}
+	for _, spec := range schema.Parameters {
+		specs[spec.Name] = spec
+	}
+
+	issues := []ParameterIssue{}
+	set := map[string]bool{}
+	for _, nv := range params {
+		set[nv.Name] = true
+		spec, ok := specs[nv.Name]
+		if !ok {
+			if !builtinParameters[nv.Name] {
+				issues = append(issues, ParameterIssue{
+					Application: appName,
+					Name:        nv.Name,
+					Reason:      UnknownParameterReason,
+					Message:     "parameter is not defined by the application",
+				})
+			}
+			continue
+		}
+		if msg := checkParameterValue(spec, nv.Value); msg != "" {
+			issues = append(issues, ParameterIssue{
+				Application: appName,
+				Name:        nv.Name,
+				Reason:      InvalidParameterReason,
+				Message:     msg,
+			})
+		}
+	}
+
+	result := append([]kfconfig.NameValue{}, params...)
+	for _, spec := range schema.Parameters {
+		if set[spec.Name] || builtinParameters[spec.Name] {
+			continue
+		}
+		if spec.Default != "" {
+			result = append(result, kfconfig.NameValue{Name: spec.Name, Value: spec.Default})
+			continue
+		}
+		if spec.Required {
+			issues = append(issues, ParameterIssue{
+				Application: appName,
+				Name:        spec.Name,
+				Reason:      MissingParameterReason,
+				Message:     "required parameter is not set",
+			})
+		}
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Name < issues[j].Name
+	})
+	return result, issues
+}
+
+// checkParameterValue returns a message describing why value isn't valid for spec, or an empty string.
+func checkParameterValue(spec ParameterSpec, value string) string {
+	if value == "" {
+		if spec.Required {
+			return "required parameter is empty"
+		}
+		return ""
+	}
+	switch spec.Type {
+	case "", StringParameter:
+	case IntegerParameter:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Sprintf("%q is not an integer", value)
+		}
+	case NumberParameter:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+	case BooleanParameter:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Sprintf("%q is not a boolean", value)
+		}
+	default:
+		return fmt.Sprintf("unsupported parameter type %v", spec.Type)
+	}
+	if len(spec.Enum) > 0 {
+		for _, e := range spec.Enum {
+			if e == value {
+				return ""
+			}
+		}
+		return fmt.Sprintf("%q is not one of %v", value, strings.Join(spec.Enum, ", "))
+	}
+	return ""
+}
+
+// hasParameterSources returns true if any of params reads its value from a ConfigMap or Secret.
+func hasParameterSources(params []kfconfig.NameValue) bool {
+	for _, nv := range params {
+		if nv.ValueFrom != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveParameters returns a copy of params where the parameters with a ValueFrom hold the value read from
+// the referenced ConfigMap or Secret in namespace.
+func ResolveParameters(client corev1.CoreV1Interface, namespace string, params []kfconfig.NameValue) ([]kfconfig.NameValue, error) {
+	result := make([]kfconfig.NameValue, 0, len(params))
+	for _, nv := range params {
+		if nv.ValueFrom == nil {
+			result = append(result, nv)
+			continue
+		}
+		var value string
+		switch {
+		case nv.ValueFrom.ConfigMapKeyRef != nil && nv.ValueFrom.SecretKeyRef != nil:
+			return nil, fmt.Errorf("parameter %v sets both configMapKeyRef and secretKeyRef", nv.Name)
+		case nv.ValueFrom.ConfigMapKeyRef != nil:
+			ref := nv.ValueFrom.ConfigMapKeyRef
+			cm, err := client.ConfigMaps(namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get ConfigMap %v for parameter %v: %v", ref.Name, nv.Name, err)
+			}
+			v, ok := cm.Data[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("ConfigMap %v has no key %v for parameter %v", ref.Name, ref.Key, nv.Name)
+			}
+			value = v
+		case nv.ValueFrom.SecretKeyRef != nil:
+			ref := nv.ValueFrom.SecretKeyRef
+			secret, err := client.Secrets(namespace).Get(context.TODO(), ref.Name, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get Secret %v for parameter %v: %v", ref.Name, nv.Name, err)
+			}
+			v, ok := secret.Data[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("Secret %v has no key %v for parameter %v", ref.Name, ref.Key, nv.Name)
+			}
+			value = string(v)
+		default:
+			return nil, fmt.Errorf("parameter %v sets valueFrom without configMapKeyRef or secretKeyRef", nv.Name)
+		}
+		result = append(result, kfconfig.NameValue{Name: nv.Name, Value: value})
+	}
+	return result, nil
+}
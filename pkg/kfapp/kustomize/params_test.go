@@ -0,0 +1,176 @@
+package kustomize
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestValidateParameters(t *testing.T) {
+	type testCase struct {
+		name           string
+		overlays       []string
+		params         []kfconfig.NameValue
+		expectedParams []kfconfig.NameValue
+		expectedIssues []ParameterIssue
+	}
+
+	testCases := []testCase{
+		{
+			name: "defaults",
+			params: []kfconfig.NameValue{
+				{Name: "storageClass", Value: "gp2"},
+				{Name: "namespace", Value: "odh"},
+			},
+			expectedParams: []kfconfig.NameValue{
+				{Name: "storageClass", Value: "gp2"},
+				{Name: "namespace", Value: "odh"},
+				{Name: "replicas", Value: "1"},
+				{Name: "logLevel", Value: "info"},
+			},
+			expectedIssues: []ParameterIssue{},
+		},
+		{
+			name:     "overlay default",
+			overlays: []string{"ha"},
+			params: []kfconfig.NameValue{
+				{Name: "storageClass", Value: "gp2"},
+				{Name: "enableMetrics", Value: "true"},
+			},
+			expectedParams: []kfconfig.NameValue{
+				{Name: "storageClass", Value: "gp2"},
+				{Name: "enableMetrics", Value: "true"},
+				{Name: "replicas", Value: "3"},
+				{Name: "logLevel", Value: "info"},
+			},
+			expectedIssues: []ParameterIssue{},
+		},
+		{
+			name: "invalid",
+			params: []kfconfig.NameValue{
+				{Name: "replicas", Value: "two"},
+				{Name: "logLevel", Value: "trace"},
+				{Name: "enableMetrics", Value: "yes"},
+				{Name: "replica", Value: "2"},
+			},
+			expectedIssues: []ParameterIssue{
+				{Application: "app", Name: "enableMetrics", Reason: InvalidParameterReason, Message: `"yes" is not a boolean`},
+				{Application: "app", Name: "logLevel", Reason: InvalidParameterReason, Message: `"trace" is not one of debug, info, error`},
+				{Application: "app", Name: "replica", Reason: UnknownParameterReason, Message: "parameter is not defined by the application"},
+				{Application: "app", Name: "replicas", Reason: InvalidParameterReason, Message: `"two" is not an integer`},
+				{Application: "app", Name: "storageClass", Reason: MissingParameterReason, Message: "required parameter is not set"},
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		schema, err := LoadParameterSchema("testdata/paramsSchema", c.overlays)
+		if err != nil {
+			t.Fatalf("%v: failed to load parameter schema: %v", c.name, err)
+		}
+		params, issues := ValidateParameters("app", schema, c.params)
+		if !reflect.DeepEqual(issues, c.expectedIssues) {
+			t.Errorf("%v: issues; expect %+v, got %+v", c.name, c.expectedIssues, issues)
+		}
+		if c.expectedParams != nil && !reflect.DeepEqual(params, c.expectedParams) {
+			t.Errorf("%v: params; expect %+v, got %+v", c.name, c.expectedParams, params)
+		}
+	}
+}
+
+func TestValidateParameters_noSchema(t *testing.T) {
+	schema, err := LoadParameterSchema("testdata/kustomizeExample/pytorch-operator", []string{"application"})
+	if err != nil {
+		t.Fatalf("failed to load parameter schema: %v", err)
+	}
+	if schema != nil {
+		t.Fatalf("expected no schema, got %+v", schema)
+	}
+	params := []kfconfig.NameValue{{Name: "anything", Value: "goes"}}
+	actual, issues := ValidateParameters("app", schema, params)
+	if len(issues) != 0 || !reflect.DeepEqual(actual, params) {
+		t.Errorf("expected parameters to be accepted unchanged; got %v, issues %v", actual, issues)
+	}
+}
+
+func TestResolveParameters(t *testing.T) {
+	type testCase struct {
+		name      string
+		params    []kfconfig.NameValue
+		expected  []kfconfig.NameValue
+		expectErr bool
+	}
+
+	configMapRef := func(name, key string) *kfconfig.ParameterSource {
+		return &kfconfig.ParameterSource{ConfigMapKeyRef: &kfconfig.KeySelector{Name: name, Key: key}}
+	}
+	secretRef := func(name, key string) *kfconfig.ParameterSource {
+		return &kfconfig.ParameterSource{SecretKeyRef: &kfconfig.KeySelector{Name: name, Key: key}}
+	}
+
+	testCases := []testCase{
+		{
+			name: "resolved",
+			params: []kfconfig.NameValue{
+				{Name: "literal", Value: "1"},
+				{Name: "host", ValueFrom: configMapRef("cluster-config", "host")},
+				{Name: "token", ValueFrom: secretRef("credentials", "token")},
+			},
+			expected: []kfconfig.NameValue{
+				{Name: "literal", Value: "1"},
+				{Name: "host", Value: "apps.example.com"},
+				{Name: "token", Value: "s3cr3t"},
+			},
+		},
+		{
+			name:      "missing key",
+			params:    []kfconfig.NameValue{{Name: "port", ValueFrom: configMapRef("cluster-config", "port")}},
+			expectErr: true,
+		},
+		{
+			name:      "missing object",
+			params:    []kfconfig.NameValue{{Name: "token", ValueFrom: secretRef("other", "token")}},
+			expectErr: true,
+		},
+		{
+			name:      "empty source",
+			params:    []kfconfig.NameValue{{Name: "token", ValueFrom: &kfconfig.ParameterSource{}}},
+			expectErr: true,
+		},
+	}
+
+	client := fake.NewSimpleClientset(
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-config", Namespace: "odh"},
+			Data:       map[string]string{"host": "apps.example.com"},
+		},
+		&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "odh"},
+			Data:       map[string][]byte{"token": []byte("s3cr3t")},
+		},
+	)
+	for _, test := range testCases {
+		original := append([]kfconfig.NameValue{}, test.params...)
+		actual, err := ResolveParameters(client.CoreV1(), "odh", test.params)
+		if test.expectErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%v: expect %v, got %v", test.name, test.expected, actual)
+		}
+		if !reflect.DeepEqual(test.params, original) {
+			t.Errorf("%v: parameters were modified", test.name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package kustomize
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/v3/pkg/resmap"
+)
+
+// ObjectVisitor is called with each object of a rendered application, in the order of the ResMap.
+type ObjectVisitor func(obj *unstructured.Unstructured) error
+
+// operatorAnnotator adds the annotation of the KfDef instance to the objects installed by the operator.
+type operatorAnnotator struct {
+	instance *unstructured.Unstructured
+	clients  *ClusterClients
+	// addAnnotation is cleared by the first namespace not created by this instance, or the profiles CRD,
+	// and stays cleared for the following objects
+	addAnnotation bool
+}
+
+func newOperatorAnnotator(instance *unstructured.Unstructured, clients *ClusterClients) *operatorAnnotator {
+	return &operatorAnnotator{
+		instance:      instance,
+		clients:       clients,
+		addAnnotation: true,
+	}
+}
+
+// annotate adds the annotation of the instance to m.
+func (a *operatorAnnotator) annotate(m *unstructured.Unstructured) error {
+	anns := m.GetAnnotations()
+	if anns == nil {
+		anns = map[string]string{}
+	}
+	kfdefAnn := strings.Join([]string{utils.KfDefAnnotation, utils.KfDefInstance}, "/")
+	kfdefCr := strings.Join([]string{a.instance.GetName(), a.instance.GetNamespace()}, ".")
+
+	if m.GetKind() == "Namespace" && a.clients != nil {
+		namespaceRes := schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+		_, err := a.clients.Dynamic.Resource(namespaceRes).Get(context.TODO(), m.GetName(), metav1.GetOptions{})
+		if err == nil {
+			log.Infof("Namespace %v already exists.", m.GetName())
+
+			_, found := anns[kfdefAnn]
+			if !found || anns[kfdefAnn] != kfdefCr {
+				// if the namespace is not created by this operator, should not append the annotation
+				a.addAnnotation = false
+			}
+		}
+	} else if m.GetKind() == "CustomResourceDefinition" && m.GetName() == "profiles.kubeflow.org" {
+		// profiles will contain user info and data, should not remove during uninstall
+		a.addAnnotation = false
+	}
+
+	if a.addAnnotation {
+		anns[kfdefAnn] = kfdefCr
+		m.SetAnnotations(anns)
+		log.Infof("KfDef annotation added for resource %v.%v", m.GetName(), m.GetNamespace())
+	}
+	return nil
+}
+
+// visitResources decodes the resources of resMap one at a time, adds the operator annotation when
+// annotator isn't nil and calls visit with each of them. Only the object being visited is decoded,
+// the resources are never encoded together as a single document.
+func visitResources(resMap resmap.ResMap, annotator *operatorAnnotator, visit ObjectVisitor) error {
+	for _, res := range resMap.Resources() {
+		y, err := res.AsYAML()
+		if err != nil {
+			return err
+		}
+		m := &unstructured.Unstructured{}
+		if err = yaml.Unmarshal(y, m); err != nil {
+			return err
+		}
+		if annotator != nil {
+			if err = annotator.annotate(m); err != nil {
+				return err
+			}
+		}
+		if err = visit(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlWriter returns an ObjectVisitor writing the objects to w as a multi-document YAML stream.
+func yamlWriter(w io.Writer) ObjectVisitor {
+	first := true
+	return func(obj *unstructured.Unstructured) error {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if !first {
+			if _, err = io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		first = false
+		_, err = w.Write(out)
+		return err
+	}
+}
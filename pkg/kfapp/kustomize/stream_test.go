@@ -0,0 +1,166 @@
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/opendatahub-io/opendatahub-operator/pkg/utils"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/kunstruct"
+	"sigs.k8s.io/kustomize/v3/k8sdeps/transformer"
+	"sigs.k8s.io/kustomize/v3/pkg/resmap"
+	"sigs.k8s.io/kustomize/v3/pkg/resource"
+)
+
+const streamManifests = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+  namespace: kubeflow
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: profiles.kubeflow.org
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: last
+  namespace: kubeflow
+`
+
+func TestVisitResources(t *testing.T) {
+	rf := resmap.NewFactory(resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl()), transformer.NewFactoryImpl())
+	m, err := rf.NewResMapFromBytes([]byte(streamManifests))
+	if err != nil {
+		t.Fatalf("Error parsing manifests: %v", err)
+	}
+	instance := &unstructured.Unstructured{}
+	instance.SetName("operator")
+	instance.SetNamespace("kubeflow")
+
+	type testCase struct {
+		name      string
+		annotator *operatorAnnotator
+		expected  map[string]bool
+	}
+	testCases := []testCase{
+		{
+			name: "no-annotator",
+			expected: map[string]bool{
+				"first":                 false,
+				"profiles.kubeflow.org": false,
+				"last":                  false,
+			},
+		},
+		{
+			name:      "annotator",
+			annotator: newOperatorAnnotator(instance, nil),
+			expected: map[string]bool{
+				"first":                 true,
+				"profiles.kubeflow.org": false,
+				"last":                  false,
+			},
+		},
+	}
+	for _, c := range testCases {
+		names := []string{}
+		buf := &bytes.Buffer{}
+		write := yamlWriter(buf)
+		err := visitResources(m, c.annotator, func(obj *unstructured.Unstructured) error {
+			names = append(names, obj.GetName())
+			_, annotated := obj.GetAnnotations()["kfctl.kubeflow.io/kfdef-instance"]
+			if annotated != c.expected[obj.GetName()] {
+				t.Errorf("%v: expect %v annotated %v, got %v", c.name, obj.GetName(), c.expected[obj.GetName()], annotated)
+			}
+			return write(obj)
+		})
+		if err != nil {
+			t.Fatalf("%v: unexpected error: %v", c.name, err)
+		}
+		if strings.Join(names, ",") != "first,profiles.kubeflow.org,last" {
+			t.Errorf("%v: unexpected visit order %v", c.name, names)
+		}
+		docs, err := utils.SplitYAML(buf.Bytes())
+		if err != nil {
+			t.Fatalf("%v: written stream isn't valid YAML: %v", c.name, err)
+		}
+		if len(docs) != len(names) {
+			t.Errorf("%v: expect %v documents, got %v", c.name, len(names), len(docs))
+		}
+	}
+}
+
+// syntheticResMap returns a ResMap of n ConfigMaps holding 4KiB of data each.
+func syntheticResMap(b *testing.B, n int) resmap.ResMap {
+	rf := resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl())
+	m := resmap.New()
+	value := strings.Repeat("x", 4096)
+	for i := 0; i < n; i++ {
+		r := rf.FromMap(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":      fmt.Sprintf("config-%v", i),
+				"namespace": "kubeflow",
+			},
+			"data": map[string]interface{}{
+				"value": value,
+			},
+		})
+		if err := m.Append(r); err != nil {
+			b.Fatalf("Error appending resource: %v", err)
+		}
+	}
+	return m
+}
+
+// BenchmarkRenderDocument renders the objects as one YAML document and splits it again,
+// as the operator did before rendering was streamed.
+func BenchmarkRenderDocument(b *testing.B) {
+	m := syntheticResMap(b, 2000)
+	instance := &unstructured.Unstructured{}
+	instance.SetName("operator")
+	instance.SetNamespace("kubeflow")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, err := GenerateYamlWithOperatorAnnotation(m, instance, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		docs, err := utils.SplitYAML(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, doc := range docs {
+			obj := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal(doc, obj); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkRenderStream visits the same objects one at a time.
+func BenchmarkRenderStream(b *testing.B) {
+	m := syntheticResMap(b, 2000)
+	instance := &unstructured.Unstructured{}
+	instance.SetName("operator")
+	instance.SetNamespace("kubeflow")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := visitResources(m, newOperatorAnnotator(instance, nil), func(obj *unstructured.Unstructured) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
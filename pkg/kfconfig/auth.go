@@ -0,0 +1,280 @@
+package kfconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	kfapis "github.com/opendatahub-io/opendatahub-operator/apis"
+	kftypesv3 "github.com/opendatahub-io/opendatahub-operator/apis/apps"
+	kfdefv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// Keys of a repository credentials Secret. They match the keys of the basic-auth, ssh-auth and tls
+// Secret types, so Secrets of these types can be referenced.
+const (
+	RepoAuthUsername   = "username"
+	RepoAuthPassword   = "password"
+	RepoAuthToken      = "token"
+	RepoAuthSSHKey     = "ssh-privatekey"
+	RepoAuthKnownHosts = "known_hosts"
+	RepoAuthClientCert = "tls.crt"
+	RepoAuthClientKey  = "tls.key"
+	RepoAuthCA         = "ca.crt"
+)
+
+// RepoAuth holds the credentials used to fetch a repository.
+type RepoAuth struct {
+	// Username and Password are sent with HTTP basic authentication.
+	Username string
+	Password string
+	// Token is sent as a HTTP bearer token.
+	Token string
+	// SSHKey is the PEM encoded private key used to fetch git repositories over SSH.
+	SSHKey []byte
+	// KnownHosts are the host keys of the SSH servers, required with SSHKey.
+	KnownHosts []byte
+	// ClientCert and ClientKey are the PEM encoded client certificate and key of TLS connections.
+	ClientCert []byte
+	ClientKey  []byte
+	// CA is a PEM encoded bundle of certificate authorities trusted in addition to the system ones.
+	CA []byte
+
+	tlsConfig *tls.Config
+}
+
+// SecretReader returns the data of the Secret name of namespace. It reads the Secrets referenced by the
+// secretKeyRef sources of the secrets and by the repositories, from the cluster of kftypesv3.GetConfig
+// by default; the operator replaces it to read them with its manager.
+var SecretReader = func(namespace, name string) (map[string][]byte, error) {
+	config := kftypesv3.GetConfig()
+	if config == nil {
+		return nil, fmt.Errorf("couldn't load the cluster configuration to read Secret %v", name)
+	}
+	client, err := corev1.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Secrets(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return secret.Data, nil
+}
+
+func init() {
+	// The v1 KfDef reads its secretKeyRef sources with SecretReader too, including when it is replaced.
+	kfdefv1.SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		return SecretReader(namespace, name)
+	}
+}
+
+// NewRepoAuth returns the credentials held by the data of a Secret, checking they are consistent.
+func NewRepoAuth(data map[string][]byte) (*RepoAuth, error) {
+	a := &RepoAuth{
+		Username:   string(data[RepoAuthUsername]),
+		Password:   string(data[RepoAuthPassword]),
+		Token:      string(data[RepoAuthToken]),
+		SSHKey:     data[RepoAuthSSHKey],
+		KnownHosts: data[RepoAuthKnownHosts],
+		ClientCert: data[RepoAuthClientCert],
+		ClientKey:  data[RepoAuthClientKey],
+		CA:         data[RepoAuthCA],
+	}
+	if a.Token != "" && (a.Username != "" || a.Password != "") {
+		return nil, fmt.Errorf("only one of %v and %v/%v can be set", RepoAuthToken, RepoAuthUsername, RepoAuthPassword)
+	}
+	if len(a.SSHKey) > 0 && len(a.KnownHosts) == 0 {
+		return nil, fmt.Errorf("%v requires %v to check the host keys of the SSH servers", RepoAuthSSHKey, RepoAuthKnownHosts)
+	}
+	if (len(a.ClientCert) == 0) != (len(a.ClientKey) == 0) {
+		return nil, fmt.Errorf("%v and %v must be set together", RepoAuthClientCert, RepoAuthClientKey)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Warnf("Could not load the system certificate pool; error %v", err)
+		pool = x509.NewCertPool()
+	}
+	if len(a.CA) > 0 && !pool.AppendCertsFromPEM(a.CA) {
+		return nil, fmt.Errorf("%v holds no PEM encoded certificate", RepoAuthCA)
+	}
+	a.tlsConfig = &tls.Config{RootCAs: pool}
+	if len(a.ClientCert) > 0 {
+		cert, err := tls.X509KeyPair(a.ClientCert, a.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %v", err)
+		}
+		a.tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return a, nil
+}
+
+// LoadRepoAuth returns the credentials held by the Secret name of namespace.
+func LoadRepoAuth(namespace, name string) (*RepoAuth, error) {
+	data, err := SecretReader(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	return NewRepoAuth(data)
+}
+
+// repoAuth returns the credentials of r, or nil when it doesn't reference any.
+func (c *KfConfig) repoAuth(r Repo) (*RepoAuth, error) {
+	if r.AuthSecretRef == nil || r.AuthSecretRef.Name == "" {
+		return nil, nil
+	}
+	auth, err := LoadRepoAuth(c.Namespace, r.AuthSecretRef.Name)
+	if err != nil {
+		return nil, &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't read the credentials of repo %v from Secret %v: %v", r.Name, r.AuthSecretRef.Name, err),
+		}
+	}
+	return auth, nil
+}
+
+// RepoAuthForURI returns the credentials of the first repository of c served by the host of uri, e.g. to
+// fetch a config file of the repository, or nil when none of these repositories references credentials.
+func (c *KfConfig) RepoAuthForURI(uri string) (*RepoAuth, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return nil, nil
+	}
+	for _, r := range c.Spec.Repos {
+		if r.AuthSecretRef == nil || r.AuthSecretRef.Name == "" {
+			continue
+		}
+		if repoURL, err := url.Parse(strings.TrimPrefix(r.URI, gitURIPrefix)); err == nil &&
+			repoURL.Scheme == u.Scheme && repoURL.Host == u.Host {
+			return c.repoAuth(r)
+		}
+	}
+	return nil, nil
+}
+
+// Transport returns a HTTP transport using the proxy of the environment, and the CA bundle and client
+// certificate of a. a can be nil.
+func (a *RepoAuth) Transport() *http.Transport {
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+	if a != nil {
+		t.TLSClientConfig = a.tlsConfig.Clone()
+	}
+	return t
+}
+
+// HTTPClient returns a HTTP client using the transport of a, with the RepoFetchTimeout. a can be nil.
+func (a *RepoAuth) HTTPClient() *http.Client {
+	return &http.Client{Transport: a.Transport(), Timeout: RepoFetchTimeout}
+}
+
+// Header returns the HTTP headers authenticating requests. a can be nil.
+func (a *RepoAuth) Header() http.Header {
+	header := http.Header{}
+	if value := a.authorization(); value != "" {
+		header.Set("Authorization", value)
+	}
+	return header
+}
+
+func (a *RepoAuth) authorization() string {
+	switch {
+	case a == nil:
+		return ""
+	case a.Token != "":
+		return "Bearer " + a.Token
+	case a.Username != "" || a.Password != "":
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password))
+	}
+	return ""
+}
+
+// gitHTTPAuth authenticates the requests of git fetches over HTTP, sent with client.
+type gitHTTPAuth struct {
+	authorization string
+	client        *http.Client
+}
+
+func (a *gitHTTPAuth) Name() string {
+	return "http-repo-auth"
+}
+
+func (a *gitHTTPAuth) String() string {
+	return a.Name()
+}
+
+// SetAuth implements the go-git HTTP AuthMethod.
+func (a *gitHTTPAuth) SetAuth(r *http.Request) {
+	if a.authorization != "" {
+		r.Header.Set("Authorization", a.authorization)
+	}
+}
+
+// gitAuth returns the go-git credentials of a to fetch the git repository at ep, or nil without
+// credentials for its protocol. a can be nil.
+func (a *RepoAuth) gitAuth(ep *transport.Endpoint) (transport.AuthMethod, error) {
+	if a == nil {
+		return nil, nil
+	}
+	switch ep.Protocol {
+	case "http", "https":
+		return &gitHTTPAuth{authorization: a.authorization(), client: a.HTTPClient()}, nil
+	case "ssh":
+		if len(a.SSHKey) == 0 {
+			return nil, nil
+		}
+		user := ep.User
+		if user == "" {
+			user = "git"
+		}
+		keys, err := gitssh.NewPublicKeys(user, a.SSHKey, "")
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v: %v", RepoAuthSSHKey, err)
+		}
+		// the host key of the server is always checked
+		if len(a.KnownHosts) == 0 {
+			return nil, fmt.Errorf("%v requires %v", RepoAuthSSHKey, RepoAuthKnownHosts)
+		}
+		keys.HostKeyCallback, err = knownHostsCallback(a.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v: %v", RepoAuthKnownHosts, err)
+		}
+		return keys, nil
+	}
+	return nil, nil
+}
+
+// knownHostsCallback returns the callback checking the host keys of the SSH servers against knownHosts,
+// in the format of the known_hosts file of OpenSSH.
+func knownHostsCallback(knownHosts []byte) (ssh.HostKeyCallback, error) {
+	file, err := ioutil.TempFile("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(knownHosts)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the hosts are read when the callback is created
+	return knownhosts.New(file.Name())
+}
@@ -0,0 +1,278 @@
+package kfconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	kfdefv1 "github.com/opendatahub-io/opendatahub-operator/apis/kfdef.apps.kubeflow.org/v1"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// clientCert returns a PEM encoded self-signed certificate and its key.
+func clientCert(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "kfctl"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestNewRepoAuth(t *testing.T) {
+	cert, key := clientCert(t)
+	type testCase struct {
+		name      string
+		data      map[string][]byte
+		expectErr bool
+	}
+	testCases := []testCase{
+		{name: "empty", data: map[string][]byte{}},
+		{name: "basic", data: map[string][]byte{"username": []byte("user"), "password": []byte("secret")}},
+		{name: "token", data: map[string][]byte{"token": []byte("secret")}},
+		{name: "client-cert", data: map[string][]byte{"tls.crt": cert, "tls.key": key, "ca.crt": cert}},
+		{name: "token-and-basic", data: map[string][]byte{"username": []byte("user"), "token": []byte("secret")}, expectErr: true},
+		{name: "cert-without-key", data: map[string][]byte{"tls.crt": cert}, expectErr: true},
+		{name: "mismatched-key", data: map[string][]byte{"tls.crt": cert, "tls.key": func() []byte { _, k := clientCert(t); return k }()}, expectErr: true},
+		{name: "invalid-ca", data: map[string][]byte{"ca.crt": []byte("not a certificate")}, expectErr: true},
+		{name: "ssh", data: map[string][]byte{"ssh-privatekey": key, "known_hosts": []byte("example.com ssh-ed25519 AAAA")}},
+		{name: "ssh-without-known-hosts", data: map[string][]byte{"ssh-privatekey": key}, expectErr: true},
+	}
+	for _, c := range testCases {
+		if _, err := NewRepoAuth(c.data); (err != nil) != c.expectErr {
+			t.Errorf("%v: expect error %v, got %v", c.name, c.expectErr, err)
+		}
+	}
+}
+
+// TestV1SecretReader checks the v1 KfDef reads its secretKeyRef sources with the SecretReader in use.
+func TestV1SecretReader(t *testing.T) {
+	defer func(f func(namespace, name string) (map[string][]byte, error)) { SecretReader = f }(SecretReader)
+	SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		return map[string][]byte{"password": []byte(namespace + "/" + name)}, nil
+	}
+	d := &kfdefv1.KfDef{}
+	d.Namespace = "kubeflow"
+	d.SetSecret(kfdefv1.Secret{
+		Name:         "password",
+		SecretSource: &kfdefv1.SecretSource{SecretKeyRef: &kfdefv1.KeySelector{Name: "creds", Key: "password"}},
+	})
+	value, err := d.GetSecret("password")
+	if err != nil {
+		t.Fatalf("GetSecret failed: %v", err)
+	}
+	if value != "kubeflow/creds" {
+		t.Errorf("expected kubeflow/creds, got %v", value)
+	}
+}
+
+func TestSyncCacheAuth(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	tarball := testTarball(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if r.Header.Get("Authorization") != "Bearer token" && !(ok && user == "user" && password == "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(tarball)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	cert, key := clientCert(t)
+
+	secrets := map[string]map[string][]byte{
+		"basic":       {"username": []byte("user"), "password": []byte("secret"), "ca.crt": ca, "tls.crt": cert, "tls.key": key},
+		"token":       {"token": []byte("token"), "ca.crt": ca, "tls.crt": cert, "tls.key": key},
+		"wrong":       {"username": []byte("user"), "password": []byte("wrong"), "ca.crt": ca, "tls.crt": cert, "tls.key": key},
+		"no-cert":     {"token": []byte("token"), "ca.crt": ca},
+		"unknown-ca":  {"token": []byte("token"), "tls.crt": cert, "tls.key": key},
+		"invalid-key": {"token": []byte("token"), "tls.crt": cert},
+	}
+	defer func(f func(namespace, name string) (map[string][]byte, error)) { SecretReader = f }(SecretReader)
+	SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		data, ok := secrets[name]
+		if namespace != "kubeflow" || !ok {
+			return nil, fmt.Errorf("Secret %v/%v not found", namespace, name)
+		}
+		return data, nil
+	}
+
+	type testCase struct {
+		secret    string
+		expectErr bool
+	}
+	testCases := []testCase{
+		{secret: "basic"},
+		{secret: "token"},
+		{secret: "", expectErr: true},
+		{secret: "wrong", expectErr: true},
+		{secret: "no-cert", expectErr: true},
+		{secret: "unknown-ca", expectErr: true},
+		{secret: "invalid-key", expectErr: true},
+		{secret: "missing", expectErr: true},
+	}
+	for _, c := range testCases {
+		appDir := path.Join(testDir, "app-"+c.secret)
+		repo := Repo{Name: "manifests", URI: srv.URL + "/manifests.tar.gz"}
+		if c.secret != "" {
+			repo.AuthSecretRef = &SecretRef{Name: c.secret}
+		}
+		config := &KfConfig{Spec: KfConfigSpec{AppDir: appDir, Repos: []Repo{repo}}}
+		config.Namespace = "kubeflow"
+		err := config.SyncCache()
+		if (err != nil) != c.expectErr {
+			t.Errorf("%v: expect error %v, got %v", c.secret, c.expectErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(path.Join(appDir, DefaultCacheDir, "manifests", "manifests", "file1")); err != nil {
+			t.Errorf("%v: expect the tarball to be extracted: %v", c.secret, err)
+		}
+	}
+}
+
+func TestGitAuth(t *testing.T) {
+	cert, key := clientCert(t)
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to parse key: %v", err)
+	}
+	auth, err := NewRepoAuth(map[string][]byte{
+		"token":          []byte("secret"),
+		"ca.crt":         cert,
+		"tls.crt":        cert,
+		"tls.key":        key,
+		"ssh-privatekey": key,
+		"known_hosts":    []byte(knownhosts.Line([]string{"example.com"}, signer.PublicKey())),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create credentials: %v", err)
+	}
+	endpoint := func(uri string) *transport.Endpoint {
+		ep, err := transport.NewEndpoint(uri)
+		if err != nil {
+			t.Fatalf("Failed to parse endpoint: %v", err)
+		}
+		return ep
+	}
+
+	method, err := auth.gitAuth(endpoint("https://example.com/manifests.git"))
+	httpAuth, ok := method.(*gitHTTPAuth)
+	if err != nil || !ok {
+		t.Fatalf("expect HTTP credentials, got %v (%v)", method, err)
+	}
+	req := httptest.NewRequest("GET", "https://example.com/manifests.git/info/refs", nil)
+	httpAuth.SetAuth(req)
+	if value := req.Header.Get("Authorization"); value != "Bearer secret" {
+		t.Errorf("expect the bearer token, got %v", value)
+	}
+	if tr, ok := httpAuth.client.Transport.(*http.Transport); !ok || len(tr.TLSClientConfig.Certificates) != 1 {
+		t.Errorf("expect the client certificate to be presented")
+	}
+
+	method, err = auth.gitAuth(endpoint("git@example.com:opendatahub-io/manifests.git"))
+	keys, ok := method.(*gitssh.PublicKeys)
+	if err != nil || !ok {
+		t.Fatalf("expect SSH credentials, got %v (%v)", method, err)
+	}
+	if keys.User != "git" {
+		t.Errorf("expect the user of the URI, got %v", keys.User)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 22}
+	if err := keys.HostKeyCallback("example.com:22", addr, signer.PublicKey()); err != nil {
+		t.Errorf("expect the known host key to be accepted: %v", err)
+	}
+	if err := keys.HostKeyCallback("other.example.com:22", addr, signer.PublicKey()); err == nil {
+		t.Errorf("expect an unknown host to be rejected")
+	}
+
+	auth.KnownHosts = nil
+	if method, err := auth.gitAuth(endpoint("ssh://git@example.com/manifests.git")); err == nil {
+		t.Errorf("expect an error without known hosts, got %v", method)
+	}
+
+	if method, err := auth.gitAuth(endpoint("file:///tmp/manifests.git")); err != nil || method != nil {
+		t.Errorf("expect no credentials for local repositories, got %v (%v)", method, err)
+	}
+	if method, err := (*RepoAuth)(nil).gitAuth(endpoint("https://example.com/manifests.git")); err != nil || method != nil {
+		t.Errorf("expect no credentials, got %v (%v)", method, err)
+	}
+}
+
+func TestRepoAuthForURI(t *testing.T) {
+	defer func(f func(namespace, name string) (map[string][]byte, error)) { SecretReader = f }(SecretReader)
+	SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		return map[string][]byte{"token": []byte(name)}, nil
+	}
+	config := &KfConfig{Spec: KfConfigSpec{Repos: []Repo{
+		{Name: "public", URI: "https://github.com/opendatahub-io/odh-manifests/tarball/master"},
+		{Name: "private", URI: "git::https://git.example.com/odh/manifests.git", AuthSecretRef: &SecretRef{Name: "git-auth"}},
+	}}}
+
+	type testCase struct {
+		uri      string
+		expected string
+	}
+	testCases := []testCase{
+		{uri: "https://git.example.com/odh/manifests/raw/main/kfdef.yaml", expected: "git-auth"},
+		{uri: "https://github.com/opendatahub-io/odh-manifests/raw/master/kfdef.yaml"},
+		{uri: "http://git.example.com/odh/manifests/raw/main/kfdef.yaml"},
+		{uri: "/tmp/kfdef.yaml"},
+	}
+	for _, c := range testCases {
+		auth, err := config.RepoAuthForURI(c.uri)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.uri, err)
+			continue
+		}
+		token := ""
+		if auth != nil {
+			token = auth.Token
+		}
+		if token != c.expected {
+			t.Errorf("%v: expect the credentials of %q, got %q", c.uri, c.expected, token)
+		}
+	}
+}
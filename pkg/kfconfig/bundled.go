@@ -0,0 +1,96 @@
+package kfconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	kfapis "github.com/opendatahub-io/opendatahub-operator/apis"
+	log "github.com/sirupsen/logrus"
+)
+
+// BundledURIPrefix is the prefix of the URIs of the repositories bundled with the operator:
+// bundled://<name> or bundled://<name>@<version>.
+const BundledURIPrefix = "bundled://"
+
+// DefaultBundleDir holds the repositories bundled in the operator image.
+const DefaultBundleDir = "/opt/manifests"
+
+// BundleDirs are the directories searched in order for bundled repositories, e.g. the directory of
+// the operator image followed by ConfigMap or PersistentVolume mounts. A repository named name is
+// either the directory <dir>/<name> or the gzipped tarball <dir>/<name>.tar.gz, described by the
+// optional metadata file <dir>/<name>.bundle.yaml.
+var BundleDirs = []string{DefaultBundleDir}
+
+// BundleMetadata describes a bundled repository.
+type BundleMetadata struct {
+	// Version of the repository.
+	Version string `json:"version,omitempty"`
+	// Source is the URI the repository was bundled from.
+	Source string `json:"source,omitempty"`
+}
+
+// IsBundledRepo returns true if r is a repository bundled with the operator.
+func IsBundledRepo(r Repo) bool {
+	return strings.HasPrefix(r.URI, BundledURIPrefix)
+}
+
+// resolveBundledRepo returns the URI of the local copy of the bundled repository uri, a directory or
+// a file: URI of a tarball, and its metadata. The first bundle with the name of uri, and its version
+// when set, is used.
+func resolveBundledRepo(uri string) (string, BundleMetadata, error) {
+	name := strings.TrimPrefix(uri, BundledURIPrefix)
+	version := ""
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		name, version = name[:i], name[i+1:]
+	}
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", BundleMetadata{}, &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("invalid bundled repository %v", uri),
+		}
+	}
+
+	found := []string{}
+	for _, dir := range BundleDirs {
+		metadata := BundleMetadata{}
+		metadataPath := filepath.Join(dir, name+".bundle.yaml")
+		if data, err := ioutil.ReadFile(metadataPath); err == nil {
+			if err := yaml.Unmarshal(data, &metadata); err != nil {
+				return "", BundleMetadata{}, &kfapis.KfError{
+					Code:    int(kfapis.INVALID_ARGUMENT),
+					Message: fmt.Sprintf("invalid bundle metadata %v: %v", metadataPath, err),
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			return "", BundleMetadata{}, err
+		}
+
+		local := ""
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil && fi.IsDir() {
+			local = filepath.Join(dir, name)
+		} else if fi, err := os.Stat(filepath.Join(dir, name+".tar.gz")); err == nil && fi.Mode().IsRegular() {
+			local = "file:" + filepath.Join(dir, name+".tar.gz")
+		} else {
+			continue
+		}
+		if version != "" && metadata.Version != version {
+			found = append(found, fmt.Sprintf("%v (version %q)", local, metadata.Version))
+			continue
+		}
+		log.Infof("Resolved %v to %v, version %q", uri, local, metadata.Version)
+		return local, metadata, nil
+	}
+
+	message := fmt.Sprintf("bundled repository %v not found in %v", uri, strings.Join(BundleDirs, ", "))
+	if len(found) > 0 {
+		message += "; found " + strings.Join(found, ", ")
+	}
+	return "", BundleMetadata{}, &kfapis.KfError{
+		Code:    int(kfapis.INVALID_ARGUMENT),
+		Message: message,
+	}
+}
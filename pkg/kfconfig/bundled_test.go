@@ -0,0 +1,90 @@
+package kfconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestResolveBundledRepo(t *testing.T) {
+	testDir := t.TempDir()
+	image := path.Join(testDir, "image")
+	mounted := path.Join(testDir, "mounted")
+	os.MkdirAll(path.Join(image, "odh-manifests"), 0755)
+	ioutil.WriteFile(path.Join(image, "odh-manifests.bundle.yaml"), []byte("version: 1.0.0\n"), 0644)
+	os.MkdirAll(mounted, 0755)
+	ioutil.WriteFile(path.Join(mounted, "odh-manifests.tar.gz"), testTarball(t), 0644)
+	ioutil.WriteFile(path.Join(mounted, "odh-manifests.bundle.yaml"), []byte("version: 1.1.0\n"), 0644)
+	ioutil.WriteFile(path.Join(mounted, "other.tar.gz"), testTarball(t), 0644)
+
+	defer func(dirs []string) { BundleDirs = dirs }(BundleDirs)
+	BundleDirs = []string{image, mounted}
+
+	type testCase struct {
+		uri             string
+		expected        string
+		expectedVersion string
+		expectError     bool
+	}
+	testCases := []testCase{
+		{uri: "bundled://odh-manifests", expected: path.Join(image, "odh-manifests"), expectedVersion: "1.0.0"},
+		{uri: "bundled://odh-manifests@1.1.0", expected: "file:" + path.Join(mounted, "odh-manifests.tar.gz"),
+			expectedVersion: "1.1.0"},
+		{uri: "bundled://other", expected: "file:" + path.Join(mounted, "other.tar.gz")},
+		{uri: "bundled://odh-manifests@2.0.0", expectError: true},
+		{uri: "bundled://missing", expectError: true},
+		{uri: "bundled://", expectError: true},
+		{uri: "bundled://..", expectError: true},
+		{uri: "bundled://../image/odh-manifests", expectError: true},
+	}
+	for _, c := range testCases {
+		actual, metadata, err := resolveBundledRepo(c.uri)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("%v: expect an error, got %v", c.uri, actual)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: failed to resolve: %v", c.uri, err)
+			continue
+		}
+		if actual != c.expected || metadata.Version != c.expectedVersion {
+			t.Errorf("%v: expect %v at version %q, got %v at version %q", c.uri, c.expected, c.expectedVersion,
+				actual, metadata.Version)
+		}
+	}
+}
+
+func TestSyncCacheBundled(t *testing.T) {
+	testDir := t.TempDir()
+	bundleDir := path.Join(testDir, "bundles")
+	os.MkdirAll(bundleDir, 0755)
+	ioutil.WriteFile(path.Join(bundleDir, "odh-manifests.tar.gz"), testTarball(t), 0644)
+	ioutil.WriteFile(path.Join(bundleDir, "odh-manifests.bundle.yaml"), []byte("version: 1.0.0\n"), 0644)
+
+	defer func(dirs []string) { BundleDirs = dirs }(BundleDirs)
+	BundleDirs = []string{bundleDir}
+
+	appDir := path.Join(testDir, "app")
+	config := &KfConfig{Spec: KfConfigSpec{AppDir: appDir, Repos: []Repo{{Name: "manifests", URI: "bundled://odh-manifests"}}}}
+	if err := config.SyncCache(); err != nil {
+		t.Fatalf("Failed to sync cache: %v", err)
+	}
+	if len(config.Status.Caches) != 1 || config.Status.Caches[0].Version != "1.0.0" {
+		t.Errorf("expect the version of the bundle to be recorded, got %+v", config.Status.Caches)
+	}
+	if _, err := os.Stat(path.Join(appDir, DefaultCacheDir, "manifests", "manifests", "file1")); err != nil {
+		t.Errorf("expect the bundled tarball to be extracted: %v", err)
+	}
+
+	// a new version of the bundle is synced again
+	ioutil.WriteFile(path.Join(bundleDir, "odh-manifests.bundle.yaml"), []byte("version: 1.1.0\n"), 0644)
+	if err := config.SyncCache(); err != nil {
+		t.Fatalf("Failed to sync cache: %v", err)
+	}
+	if len(config.Status.Caches) != 1 || config.Status.Caches[0].Version != "1.1.0" {
+		t.Errorf("expect version 1.1.0 to be synced, got %+v", config.Status.Caches)
+	}
+}
@@ -0,0 +1,237 @@
+package kfconfig
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	version "github.com/hashicorp/go-version"
+	kfapis "github.com/opendatahub-io/opendatahub-operator/apis"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AutomaticApproval applies the latest version of a channel as soon as it's found.
+	AutomaticApproval = "Automatic"
+	// ManualApproval applies the version of a channel approved by the ChannelApprovalAnnotation of the repository.
+	ManualApproval = "Manual"
+	// ChannelApprovalAnnotation is the prefix of the annotations approving a version of the channel of a
+	// repository, followed by the name of the repository, e.g. kfctl.kubeflow.io/approved-version.manifests: 1.2.0
+	ChannelApprovalAnnotation = "kfctl.kubeflow.io/approved-version."
+)
+
+// ChannelIndex lists the versions of a release channel.
+type ChannelIndex struct {
+	Versions []ChannelVersion `json:"versions"`
+}
+
+// ChannelVersion is a version of a release channel and the URI of the repository at this version.
+type ChannelVersion struct {
+	Version string `json:"version"`
+	URI     string `json:"uri"`
+	Sha256  string `json:"sha256,omitempty"`
+}
+
+// GetChannelStatus returns the status of the channel of the repository name.
+func (c *KfConfig) GetChannelStatus(name string) (RepoChannelStatus, bool) {
+	for _, s := range c.Status.Channels {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return RepoChannelStatus{}, false
+}
+
+func (c *KfConfig) setChannelStatus(status RepoChannelStatus) {
+	for i := range c.Status.Channels {
+		if c.Status.Channels[i].Name == status.Name {
+			c.Status.Channels[i] = status
+			return
+		}
+	}
+	c.Status.Channels = append(c.Status.Channels, status)
+}
+
+// PollChannels polls the indexes of the release channels not polled for interval, or with a newly
+// approved version, records their latest versions and applies the versions allowed by their approval.
+// It returns true when the version applied of a channel changed. The error of a poll is recorded in
+// the status of the channel, the version applied is kept.
+func (c *KfConfig) PollChannels(interval time.Duration) (bool, error) {
+	changed := false
+	var errs []string
+	now := metav1.Now()
+	for _, r := range c.Spec.Repos {
+		if r.Channel == nil {
+			continue
+		}
+		status, ok := c.GetChannelStatus(r.Name)
+		approved := c.GetAnnotations()[ChannelApprovalAnnotation+r.Name]
+		// a newly approved version is applied without waiting for the next poll
+		due := !ok || status.Version == "" || now.Sub(status.LastPollTime.Time) >= interval ||
+			(r.Channel.Approval == ManualApproval && approved != "" && approved != status.Version)
+		if !due {
+			continue
+		}
+		applied := status.Version
+		if err := c.pollChannel(r, &status); err != nil {
+			log.Errorf("Could not poll the channel of repo %v; error %v", r.Name, err)
+			errs = append(errs, err.Error())
+		}
+		status.LastPollTime = now
+		c.setChannelStatus(status)
+		changed = changed || status.Version != applied
+	}
+	if len(errs) > 0 {
+		return changed, &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: strings.Join(errs, "; "),
+		}
+	}
+	return changed, nil
+}
+
+// pollChannel updates status with the latest version of the channel of r, and the version to apply.
+func (c *KfConfig) pollChannel(r Repo, status *RepoChannelStatus) error {
+	status.Name = r.Name
+	status.Message = ""
+	err := func() error {
+		if r.URI != "" || r.Ref != "" || r.Tag != "" || r.Commit != "" || r.Sha256 != "" {
+			return fmt.Errorf("repo %v: uri, ref, tag, commit and sha256 can't be set with a channel", r.Name)
+		}
+		var constraints version.Constraints
+		if r.Channel.Constraint != "" {
+			var err error
+			if constraints, err = version.NewConstraint(r.Channel.Constraint); err != nil {
+				return fmt.Errorf("repo %v: invalid constraint %v: %v", r.Name, r.Channel.Constraint, err)
+			}
+		}
+		approval := r.Channel.Approval
+		if approval == "" {
+			approval = AutomaticApproval
+		}
+		if approval != AutomaticApproval && approval != ManualApproval {
+			return fmt.Errorf("repo %v: unknown approval %v", r.Name, approval)
+		}
+
+		auth, err := c.repoAuth(r)
+		if err != nil {
+			return err
+		}
+		body, err := download(r.Channel.IndexURI, auth)
+		if err != nil {
+			return err
+		}
+		index := &ChannelIndex{}
+		if err := yaml.Unmarshal(body, index); err != nil {
+			return fmt.Errorf("repo %v: invalid channel index %v: %v", r.Name, r.Channel.IndexURI, err)
+		}
+
+		latest := latestChannelVersion(index, constraints)
+		if latest == nil {
+			return fmt.Errorf("repo %v: no version of %v satisfies %q", r.Name, r.Channel.IndexURI, r.Channel.Constraint)
+		}
+		status.LatestVersion = latest.Version
+
+		apply := latest
+		if status.Version != "" && approval == ManualApproval {
+			// the version applied is kept until another one is approved
+			apply = nil
+			if approved := c.GetAnnotations()[ChannelApprovalAnnotation+r.Name]; approved != "" {
+				if apply = findChannelVersion(index, constraints, approved); apply == nil {
+					return fmt.Errorf("repo %v: approved version %v isn't a version of %v satisfying %q",
+						r.Name, approved, r.Channel.IndexURI, r.Channel.Constraint)
+				}
+			}
+		}
+		if apply != nil && apply.Version != status.Version {
+			log.Infof("Applying version %v of the channel of repo %v", apply.Version, r.Name)
+			status.Version = apply.Version
+			status.URI = resolveChannelURI(r.Channel.IndexURI, apply.URI)
+			status.Sha256 = apply.Sha256
+		}
+		return nil
+	}()
+	if err != nil {
+		status.Message = err.Error()
+	}
+	return err
+}
+
+// channelRepo returns r fetching the version of its channel applied, polling the channel when no
+// version is applied yet.
+func (c *KfConfig) channelRepo(r Repo) (Repo, error) {
+	if r.Channel == nil {
+		return r, nil
+	}
+	status, ok := c.GetChannelStatus(r.Name)
+	if !ok || status.Version == "" {
+		err := c.pollChannel(r, &status)
+		status.LastPollTime = metav1.Now()
+		c.setChannelStatus(status)
+		if err != nil {
+			return r, err
+		}
+	}
+	r.URI = status.URI
+	r.Sha256 = status.Sha256
+	return r, nil
+}
+
+// latestChannelVersion returns the latest version of index satisfying constraints, or nil.
+// Pre-releases are only selected by constraints on pre-releases.
+func latestChannelVersion(index *ChannelIndex, constraints version.Constraints) *ChannelVersion {
+	var latest *ChannelVersion
+	var latestVersion *version.Version
+	for i := range index.Versions {
+		v, err := version.NewVersion(index.Versions[i].Version)
+		if err != nil {
+			log.Warnf("Skipping invalid channel version %v; error %v", index.Versions[i].Version, err)
+			continue
+		}
+		if constraints == nil && v.Prerelease() != "" {
+			continue
+		}
+		if constraints != nil && !constraints.Check(v) {
+			continue
+		}
+		if latestVersion == nil || v.GreaterThan(latestVersion) {
+			latest, latestVersion = &index.Versions[i], v
+		}
+	}
+	return latest
+}
+
+// findChannelVersion returns the version named name of index if it satisfies constraints, or nil.
+func findChannelVersion(index *ChannelIndex, constraints version.Constraints, name string) *ChannelVersion {
+	want, err := version.NewVersion(name)
+	if err != nil {
+		return nil
+	}
+	for i := range index.Versions {
+		v, err := version.NewVersion(index.Versions[i].Version)
+		if err != nil || !v.Equal(want) {
+			continue
+		}
+		if constraints != nil && !constraints.Check(v) {
+			return nil
+		}
+		return &index.Versions[i]
+	}
+	return nil
+}
+
+// resolveChannelURI resolves the URI of a version relative to the URI of the index.
+func resolveChannelURI(indexURI string, uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.IsAbs() || path.IsAbs(uri) {
+		return uri
+	}
+	if base, err := url.Parse(indexURI); err == nil && base.IsAbs() {
+		return base.ResolveReference(u).String()
+	}
+	return path.Join(path.Dir(indexURI), uri)
+}
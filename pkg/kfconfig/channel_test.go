@@ -0,0 +1,158 @@
+package kfconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	version "github.com/hashicorp/go-version"
+)
+
+func TestLatestChannelVersion(t *testing.T) {
+	index := &ChannelIndex{Versions: []ChannelVersion{
+		{Version: "1.0.0"},
+		{Version: "1.2.0"},
+		{Version: "1.10.1"},
+		{Version: "2.0.0-rc.1"},
+		{Version: "not-a-version"},
+		{Version: "2.1.0"},
+	}}
+	type testCase struct {
+		constraint string
+		expected   string
+	}
+	testCases := []testCase{
+		{constraint: "", expected: "2.1.0"},
+		{constraint: "< 2.0", expected: "1.10.1"},
+		{constraint: "~> 1.2.0", expected: "1.2.0"},
+		{constraint: ">= 1.10, < 2.1", expected: "1.10.1"},
+		{constraint: "= 2.0.0-rc.1", expected: "2.0.0-rc.1"},
+		{constraint: "> 3", expected: ""},
+	}
+	for _, c := range testCases {
+		var constraints version.Constraints
+		if c.constraint != "" {
+			var err error
+			if constraints, err = version.NewConstraint(c.constraint); err != nil {
+				t.Fatalf("Invalid constraint %v: %v", c.constraint, err)
+			}
+		}
+		actual := ""
+		if latest := latestChannelVersion(index, constraints); latest != nil {
+			actual = latest.Version
+		}
+		if actual != c.expected {
+			t.Errorf("%q: expect %v, got %v", c.constraint, c.expected, actual)
+		}
+	}
+}
+
+func TestPollChannels(t *testing.T) {
+	testDir := t.TempDir()
+	indexPath := path.Join(testDir, "index.yaml")
+	writeIndex := func(index string) {
+		if err := ioutil.WriteFile(indexPath, []byte(index), 0644); err != nil {
+			t.Fatalf("Failed to write index: %v", err)
+		}
+	}
+	writeIndex(`
+versions:
+- version: 1.0.0
+  uri: manifests-1.0.0.tar.gz
+- version: 2.0.0
+  uri: https://example.com/manifests-2.0.0.tar.gz
+`)
+
+	newConfig := func(approval string) *KfConfig {
+		config := &KfConfig{Spec: KfConfigSpec{Repos: []Repo{{
+			Name:    "manifests",
+			Channel: &RepoChannel{IndexURI: indexPath, Constraint: "< 2.0", Approval: approval},
+		}}}}
+		config.Namespace = "kubeflow"
+		return config
+	}
+	poll := func(config *KfConfig, interval time.Duration, expectChanged bool, expectVersion string, expectLatest string) {
+		t.Helper()
+		changed, err := config.PollChannels(interval)
+		if err != nil {
+			t.Fatalf("Failed to poll: %v", err)
+		}
+		status, _ := config.GetChannelStatus("manifests")
+		if changed != expectChanged || status.Version != expectVersion || status.LatestVersion != expectLatest {
+			t.Errorf("expect changed %v, version %v and latest %v, got %v, %+v", expectChanged, expectVersion,
+				expectLatest, changed, status)
+		}
+	}
+
+	automatic := newConfig("")
+	manual := newConfig(ManualApproval)
+	poll(automatic, time.Hour, true, "1.0.0", "1.0.0")
+	poll(manual, time.Hour, true, "1.0.0", "1.0.0")
+	if status, _ := automatic.GetChannelStatus("manifests"); status.URI != path.Join(testDir, "manifests-1.0.0.tar.gz") {
+		t.Errorf("expect the URI to be resolved against the index, got %v", status.URI)
+	}
+
+	writeIndex(`
+versions:
+- version: 1.0.0
+  uri: manifests-1.0.0.tar.gz
+- version: 1.1.0
+  uri: manifests-1.1.0.tar.gz
+  sha256: ab01
+`)
+	// not due yet
+	poll(automatic, time.Hour, false, "1.0.0", "1.0.0")
+	poll(automatic, 0, true, "1.1.0", "1.1.0")
+	if status, _ := automatic.GetChannelStatus("manifests"); status.Sha256 != "ab01" {
+		t.Errorf("expect the sha256 of the index, got %v", status.Sha256)
+	}
+	poll(manual, 0, false, "1.0.0", "1.1.0")
+
+	// an approval is applied without waiting for the next poll
+	manual.SetAnnotations(map[string]string{ChannelApprovalAnnotation + "manifests": "1.1.0"})
+	poll(manual, time.Hour, true, "1.1.0", "1.1.0")
+
+	manual.SetAnnotations(map[string]string{ChannelApprovalAnnotation + "manifests": "2.0.0"})
+	if _, err := manual.PollChannels(time.Hour); err == nil {
+		t.Errorf("expect an error approving a version not satisfying the constraint")
+	}
+	if status, _ := manual.GetChannelStatus("manifests"); status.Version != "1.1.0" || status.Message == "" {
+		t.Errorf("expect version 1.1.0 to be kept and the error to be recorded, got %+v", status)
+	}
+}
+
+func TestSyncCacheChannel(t *testing.T) {
+	testDir := t.TempDir()
+	ioutil.WriteFile(path.Join(testDir, "manifests-1.0.0.tar.gz"), testTarball(t), 0644)
+	ioutil.WriteFile(path.Join(testDir, "index.yaml"), []byte(`
+versions:
+- version: 1.0.0
+  uri: manifests-1.0.0.tar.gz
+`), 0644)
+
+	appDir := path.Join(testDir, "app")
+	config := &KfConfig{Spec: KfConfigSpec{AppDir: appDir, Repos: []Repo{{
+		Name:    "manifests",
+		Channel: &RepoChannel{IndexURI: "file:" + path.Join(testDir, "index.yaml")},
+	}}}}
+	if err := config.SyncCache(); err != nil {
+		t.Fatalf("Failed to sync cache: %v", err)
+	}
+	if status, _ := config.GetChannelStatus("manifests"); status.Version != "1.0.0" {
+		t.Errorf("expect version 1.0.0 to be applied, got %+v", status)
+	}
+	if _, err := os.Stat(path.Join(appDir, DefaultCacheDir, "manifests", "manifests", "file1")); err != nil {
+		t.Errorf("expect the tarball of the version to be extracted: %v", err)
+	}
+
+	config = &KfConfig{Spec: KfConfigSpec{AppDir: path.Join(testDir, "invalid"), Repos: []Repo{{
+		Name:    "manifests",
+		URI:     path.Join(testDir, "manifests-1.0.0.tar.gz"),
+		Channel: &RepoChannel{IndexURI: path.Join(testDir, "index.yaml")},
+	}}}}
+	if err := config.SyncCache(); err == nil {
+		t.Errorf("expect an error with both a URI and a channel")
+	}
+}
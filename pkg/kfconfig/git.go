@@ -0,0 +1,266 @@
+package kfconfig
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+	kfapis "github.com/opendatahub-io/opendatahub-operator/apis"
+)
+
+// gitURIPrefix forces a repository to be fetched with git, as with go-getter.
+const gitURIPrefix = "git::"
+
+// gitCommitID matches full and abbreviated commit ids.
+var gitCommitID = regexp.MustCompile("^[0-9a-fA-F]{7,40}$")
+
+// IsGitRepo returns true if the repository is fetched with git rather than copied or downloaded as a tarball.
+func IsGitRepo(r Repo) bool {
+	if r.Ref != "" || r.Tag != "" || r.Commit != "" || strings.HasPrefix(r.URI, gitURIPrefix) {
+		return true
+	}
+	// scp-like addresses, e.g. git@github.com:opendatahub-io/odh-manifests.git, aren't URLs
+	if u, err := url.Parse(r.URI); err == nil && (u.Scheme == "git" || u.Scheme == "ssh") {
+		return true
+	}
+	uri := r.URI
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		uri = uri[:i]
+	}
+	return strings.HasSuffix(strings.TrimSuffix(uri, "/"), ".git")
+}
+
+// gitRevision returns the revision of the repository to fetch, checking that at most one is set.
+func gitRevision(r Repo) (string, error) {
+	revisions := []string{}
+	for _, rev := range []string{r.Ref, r.Tag, r.Commit} {
+		if rev != "" {
+			revisions = append(revisions, rev)
+		}
+	}
+	if len(revisions) > 1 {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("repo %v: only one of ref, tag and commit can be set", r.Name),
+		}
+	}
+	switch {
+	case r.Commit != "":
+		if !gitCommitID.MatchString(r.Commit) {
+			return "", &kfapis.KfError{
+				Code:    int(kfapis.INVALID_ARGUMENT),
+				Message: fmt.Sprintf("repo %v: invalid commit %v", r.Name, r.Commit),
+			}
+		}
+		return strings.ToLower(r.Commit), nil
+	case r.Tag != "":
+		return "refs/tags/" + r.Tag, nil
+	case r.Ref != "":
+		return r.Ref, nil
+	}
+	return "HEAD", nil
+}
+
+func init() {
+	// The repositories are fetched without git, which the operator image doesn't have:
+	// local repositories are served in-process, and the HTTP client of the credentials of a fetch is used.
+	client.InstallProtocol("file", server.DefaultServer)
+	client.InstallProtocol("https", gitHTTPTransport{})
+	client.InstallProtocol("http", gitHTTPTransport{})
+}
+
+// gitHTTPTransport fetches git repositories over HTTP with the client of the credentials of the fetch,
+// which trusts their CA bundle and presents their client certificate, or with the default client.
+type gitHTTPTransport struct{}
+
+func (gitHTTPTransport) transport(auth transport.AuthMethod) transport.Transport {
+	if a, ok := auth.(*gitHTTPAuth); ok {
+		return githttp.NewClient(a.client)
+	}
+	return githttp.DefaultClient
+}
+
+func (t gitHTTPTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	return t.transport(auth).NewUploadPackSession(ep, auth)
+}
+
+func (t gitHTTPTransport) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	return t.transport(auth).NewReceivePackSession(ep, auth)
+}
+
+// fetchGitRepo checks out the revision of the git repository r into dir with a shallow fetch,
+// and returns the id of the commit checked out. The git metadata isn't kept. The repository is fetched
+// with the credentials of auth, which can be nil.
+func fetchGitRepo(r Repo, dir string, auth *RepoAuth) (string, error) {
+	revision, err := gitRevision(r)
+	if err != nil {
+		return "", err
+	}
+	uri := strings.TrimPrefix(r.URI, gitURIPrefix)
+	ep, err := transport.NewEndpoint(uri)
+	if err != nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("repo %v: invalid git URI %v: %v", r.Name, r.URI, err),
+		}
+	}
+	method, err := auth.gitAuth(ep)
+	if err != nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("repo %v: invalid credentials: %v", r.Name, err),
+		}
+	}
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return "", err
+	}
+	remote, err := repo.CreateRemote(&gitconfig.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{uri}})
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := repoFetchContext()
+	defer cancel()
+	var commit *object.Commit
+	if r.Commit == "" {
+		commit, err = fetchGitRef(ctx, repo, remote, ep, method, revision)
+		if err != nil {
+			return "", &kfapis.KfError{
+				Code:    int(kfapis.INVALID_ARGUMENT),
+				Message: fmt.Sprintf("couldn't fetch %v of %v: %v", revision, r.URI, err),
+			}
+		}
+	} else {
+		// abbreviated ids can't be fetched and some servers don't allow fetching a commit by id:
+		// fetch the branches and tags, and look for the commit in their history
+		commit, err = fetchGitCommit(ctx, repo, remote, method, revision)
+		if err != nil {
+			return "", &kfapis.KfError{
+				Code:    int(kfapis.INVALID_ARGUMENT),
+				Message: fmt.Sprintf("repo %v: couldn't fetch commit %v of %v: %v", r.Name, r.Commit, r.URI, err),
+			}
+		}
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: commit.Hash, Force: true}); err != nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't check out %v of %v: %v", revision, r.URI, err),
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+		return "", err
+	}
+	return commit.Hash.String(), nil
+}
+
+// repoFetchContext returns the context of a git fetch, canceled after the RepoFetchTimeout.
+func repoFetchContext() (context.Context, context.CancelFunc) {
+	if RepoFetchTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), RepoFetchTimeout)
+}
+
+// fetchGitRef fetches the commit of the reference revision of remote, resolved as git does: HEAD,
+// a full reference name, or a tag or branch name.
+func fetchGitRef(ctx context.Context, repo *git.Repository, remote *git.Remote, ep *transport.Endpoint,
+	auth transport.AuthMethod, revision string) (*object.Commit, error) {
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, err
+	}
+	byName := map[plumbing.ReferenceName]*plumbing.Reference{}
+	for _, ref := range refs {
+		byName[ref.Name()] = ref
+	}
+	var ref *plumbing.Reference
+	for _, name := range []string{revision, "refs/" + revision, "refs/tags/" + revision, "refs/heads/" + revision} {
+		if ref = byName[plumbing.ReferenceName(name)]; ref != nil {
+			break
+		}
+	}
+	if ref != nil && ref.Type() == plumbing.SymbolicReference {
+		ref = byName[ref.Target()]
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("reference not found")
+	}
+
+	fetched := plumbing.ReferenceName("refs/fetched/" + strings.TrimPrefix(ref.Name().String(), "refs/"))
+	opts := &git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%v:%v", ref.Name(), fetched))},
+		Auth:     auth,
+		Tags:     git.NoTags,
+	}
+	// local repositories are served in-process, without shallow fetches
+	if ep.Protocol != "file" {
+		opts.Depth = 1
+	}
+	if err := remote.FetchContext(ctx, opts); err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+	return gitCommit(repo, ref.Hash())
+}
+
+// fetchGitCommit fetches the branches and tags of remote, and returns the commit whose id starts with id.
+func fetchGitCommit(ctx context.Context, repo *git.Repository, remote *git.Remote, auth transport.AuthMethod,
+	id string) (*object.Commit, error) {
+	err := remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []gitconfig.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+		Auth:     auth,
+		Tags:     git.AllTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+	if len(id) == 40 {
+		return repo.CommitObject(plumbing.NewHash(id))
+	}
+	commits, err := repo.CommitObjects()
+	if err != nil {
+		return nil, err
+	}
+	var found *object.Commit
+	err = commits.ForEach(func(c *object.Commit) error {
+		if !strings.HasPrefix(c.Hash.String(), id) {
+			return nil
+		}
+		if found != nil && found.Hash != c.Hash {
+			return fmt.Errorf("abbreviated commit %v is ambiguous", id)
+		}
+		found = c
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	return found, nil
+}
+
+// gitCommit returns the commit of hash, which is a commit or an annotated tag.
+func gitCommit(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	if tag, err := repo.TagObject(hash); err == nil {
+		return tag.Commit()
+	}
+	return repo.CommitObject(hash)
+}
@@ -0,0 +1,169 @@
+package kfconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestIsGitRepo(t *testing.T) {
+	type testCase struct {
+		repo     Repo
+		expected bool
+	}
+	testCases := []testCase{
+		{repo: Repo{URI: "https://github.com/opendatahub-io/odh-manifests/tarball/master"}, expected: false},
+		{repo: Repo{URI: "/tmp/manifests"}, expected: false},
+		{repo: Repo{URI: "https://github.com/opendatahub-io/odh-manifests.git"}, expected: true},
+		{repo: Repo{URI: "git@github.com:opendatahub-io/odh-manifests.git"}, expected: true},
+		{repo: Repo{URI: "ssh://git@github.com/opendatahub-io/odh-manifests"}, expected: true},
+		{repo: Repo{URI: "git::https://example.com/manifests"}, expected: true},
+		{repo: Repo{URI: "https://example.com/manifests", Tag: "v1.0.0"}, expected: true},
+	}
+	for _, c := range testCases {
+		if actual := IsGitRepo(c.repo); actual != c.expected {
+			t.Errorf("IsGitRepo(%v): expect %v, got %v", c.repo.URI, c.expected, actual)
+		}
+	}
+}
+
+// newBareRepo creates a bare repository with two commits on main, the first one tagged v1.
+// It returns the path of the repository and the ids of the commits.
+func newBareRepo(t *testing.T, dir string) (string, string, string) {
+	work := path.Join(dir, "work")
+	bare := path.Join(dir, "manifests.git")
+	git := func(dir string, args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("Failed to create repository: %v", err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.MkdirAll(work, os.ModePerm); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	git(work, "init", "-q", "-b", "main")
+	ioutil.WriteFile(path.Join(work, "version"), []byte("v1"), 0644)
+	git(work, "add", "version")
+	git(work, "commit", "-q", "-m", "v1")
+	git(work, "tag", "-a", "v1", "-m", "v1")
+	first := git(work, "rev-parse", "HEAD")
+	ioutil.WriteFile(path.Join(work, "version"), []byte("v2"), 0644)
+	git(work, "commit", "-q", "-a", "-m", "v2")
+	second := git(work, "rev-parse", "HEAD")
+	git(dir, "clone", "-q", "--bare", work, bare)
+	return bare, first, second
+}
+
+func TestSyncCacheGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	testDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+	bare, first, second := newBareRepo(t, testDir)
+
+	type testCase struct {
+		name            string
+		repo            Repo
+		status          []Cache
+		expectedVersion string
+		expectedCommit  string
+		expectErr       bool
+	}
+	testCases := []testCase{
+		{
+			name:            "default-branch",
+			repo:            Repo{URI: bare},
+			expectedVersion: "v2",
+			expectedCommit:  second,
+		},
+		{
+			name:            "ref",
+			repo:            Repo{URI: "file://" + bare, Ref: "main"},
+			expectedVersion: "v2",
+			expectedCommit:  second,
+		},
+		{
+			name:            "tag",
+			repo:            Repo{URI: bare, Tag: "v1"},
+			expectedVersion: "v1",
+			expectedCommit:  first,
+		},
+		{
+			name:            "commit",
+			repo:            Repo{URI: bare, Commit: first},
+			expectedVersion: "v1",
+			expectedCommit:  first,
+		},
+		{
+			name:            "abbreviated-commit",
+			repo:            Repo{URI: bare, Commit: first[:8]},
+			expectedVersion: "v1",
+			expectedCommit:  first,
+		},
+		{
+			name:            "pinned-commit-changed",
+			repo:            Repo{URI: bare, Commit: first},
+			status:          []Cache{{Name: "manifests", LocalPath: "stale", Commit: second}},
+			expectedVersion: "v1",
+			expectedCommit:  first,
+		},
+		{
+			name:      "ref-and-tag",
+			repo:      Repo{URI: bare, Ref: "main", Tag: "v1"},
+			expectErr: true,
+		},
+		{
+			name:      "missing-tag",
+			repo:      Repo{URI: bare, Tag: "v3"},
+			expectErr: true,
+		},
+	}
+	for _, c := range testCases {
+		appDir := path.Join(testDir, c.name)
+		c.repo.Name = "manifests"
+		config := &KfConfig{
+			Spec:   KfConfigSpec{AppDir: appDir, Repos: []Repo{c.repo}},
+			Status: Status{Caches: c.status},
+		}
+		if c.status != nil {
+			// the stale cache directory exists
+			os.MkdirAll(path.Join(appDir, DefaultCacheDir, "manifests"), os.ModePerm)
+		}
+		err := config.SyncCache()
+		if c.expectErr {
+			if err == nil {
+				t.Errorf("%v: expected an error", c.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.name, err)
+			continue
+		}
+		localPath := path.Join(appDir, DefaultCacheDir, "manifests")
+		if len(config.Status.Caches) != 1 || config.Status.Caches[0].LocalPath != localPath ||
+			config.Status.Caches[0].Commit != c.expectedCommit {
+			t.Errorf("%v: expect cache at %v with commit %v, got %+v", c.name, localPath, c.expectedCommit,
+				config.Status.Caches)
+			continue
+		}
+		version, err := ioutil.ReadFile(path.Join(localPath, "version"))
+		if err != nil || string(version) != c.expectedVersion {
+			t.Errorf("%v: expect version %v, got %v (%v)", c.name, c.expectedVersion, string(version), err)
+		}
+		if _, err := os.Stat(path.Join(localPath, ".git")); !os.IsNotExist(err) {
+			t.Errorf("%v: expect the git metadata to be removed", c.name)
+		}
+	}
+}
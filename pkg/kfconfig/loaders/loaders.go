@@ -42,6 +42,12 @@ func isValidUrl(toTest string) bool {
 //   AppDir = cwd if configFile is remote, or it will be the dir of configFile.
 //   ConfigFilename = the file name of configFile.
 func LoadConfigFromURI(configFile string) (*kfconfig.KfConfig, error) {
+	return LoadConfigFromURIWithAuth(configFile, nil)
+}
+
+// LoadConfigFromURIWithAuth is LoadConfigFromURI fetching a remote HTTP(S) configFile with the
+// credentials of auth, which can be nil.
+func LoadConfigFromURIWithAuth(configFile string, auth *kfconfig.RepoAuth) (*kfconfig.KfConfig, error) {
 	if configFile == "" {
 		return nil, fmt.Errorf("config file must be the URI of a KfDef spec")
 	}
@@ -74,7 +80,13 @@ func LoadConfigFromURI(configFile string) (*kfconfig.KfConfig, error) {
 			log.Errorf("Could not parse configFile url")
 		}
 		if isValidUrl(configFile) {
-			errGet := gogetter.GetFile(appFile, configFile)
+			var errGet error
+			if auth != nil && (configFileUri.Scheme == "http" || configFileUri.Scheme == "https") {
+				g := &gogetter.HttpGetter{Client: auth.HTTPClient(), Header: auth.Header()}
+				errGet = g.GetFile(appFile, configFileUri)
+			} else {
+				errGet = gogetter.GetFile(appFile, configFile)
+			}
 			if errGet != nil {
 				return nil, &kfapis.KfError{
 					Code:    int(kfapis.INVALID_ARGUMENT),
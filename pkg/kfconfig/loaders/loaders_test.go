@@ -1,9 +1,14 @@
 package loaders
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path"
 	"testing"
+
+	"github.com/opendatahub-io/opendatahub-operator/pkg/kfconfig"
 )
 
 // Make sure literal secrets are keeped during load kfdef -> kfconfig
@@ -18,3 +23,31 @@ func Test_loadKfdefLiteralSecrets(t *testing.T) {
 	}
 
 }
+
+func TestLoadConfigFromURIWithAuth(t *testing.T) {
+	wd, _ := os.Getwd()
+	kfdef, err := ioutil.ReadFile(path.Join(wd, "testdata", "v1.yaml"))
+	if err != nil {
+		t.Fatalf("Failed to read kfdef: %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(kfdef)
+	}))
+	defer srv.Close()
+
+	if _, err := LoadConfigFromURI(srv.URL + "/kfdef.yaml"); err == nil {
+		t.Errorf("expect an error without credentials")
+	}
+	auth, err := kfconfig.NewRepoAuth(map[string][]byte{"token": []byte("secret")})
+	if err != nil {
+		t.Fatalf("Failed to create credentials: %v", err)
+	}
+	config, err := LoadConfigFromURIWithAuth(srv.URL+"/kfdef.yaml", auth)
+	if err != nil || config == nil || config.Name == "" {
+		t.Errorf("expect the kfdef to be loaded, got %v (%v)", config, err)
+	}
+}
@@ -50,7 +50,8 @@ func (v V1) LoadKfConfig(def interface{}) (*kfconfig.KfConfig, error) {
 		}
 		if app.KustomizeConfig != nil {
 			kconfig := &kfconfig.KustomizeConfig{
-				Overlays: app.KustomizeConfig.Overlays,
+				Overlays:              app.KustomizeConfig.Overlays,
+				PatchesStrategicMerge: app.KustomizeConfig.PatchesStrategicMerge,
 			}
 			if app.KustomizeConfig.RepoRef != nil {
 				kref := &kfconfig.RepoRef{
@@ -69,10 +70,60 @@ func (v V1) LoadKfConfig(def interface{}) (*kfconfig.KfConfig, error) {
 					Name:  param.Name,
 					Value: param.Value,
 				}
+				if param.ValueFrom != nil {
+					p.ValueFrom = &kfconfig.ParameterSource{}
+					if ref := param.ValueFrom.ConfigMapKeyRef; ref != nil {
+						p.ValueFrom.ConfigMapKeyRef = &kfconfig.KeySelector{Name: ref.Name, Key: ref.Key}
+					}
+					if ref := param.ValueFrom.SecretKeyRef; ref != nil {
+						p.ValueFrom.SecretKeyRef = &kfconfig.KeySelector{Name: ref.Name, Key: ref.Key}
+					}
+				}
 				kconfig.Parameters = append(kconfig.Parameters, p)
 			}
+			for _, patch := range app.KustomizeConfig.PatchesJson6902 {
+				kconfig.PatchesJson6902 = append(kconfig.PatchesJson6902, kfconfig.JSONPatch{
+					Target: kfconfig.PatchTarget{
+						Group:     patch.Target.Group,
+						Version:   patch.Target.Version,
+						Kind:      patch.Target.Kind,
+						Name:      patch.Target.Name,
+						Namespace: patch.Target.Namespace,
+					},
+					Patch: patch.Patch,
+				})
+			}
+			for _, image := range app.KustomizeConfig.Images {
+				kconfig.Images = append(kconfig.Images, kfconfig.Image{
+					Name:    image.Name,
+					NewName: image.NewName,
+					NewTag:  image.NewTag,
+					Digest:  image.Digest,
+				})
+			}
 			application.KustomizeConfig = kconfig
 		}
+		if app.HelmConfig != nil {
+			hconfig := &kfconfig.HelmConfig{
+				ChartPath:   app.HelmConfig.ChartPath,
+				ReleaseName: app.HelmConfig.ReleaseName,
+				Namespace:   app.HelmConfig.Namespace,
+				Values:      app.HelmConfig.Values,
+			}
+			if app.HelmConfig.RepoRef != nil {
+				hconfig.RepoRef = &kfconfig.RepoRef{
+					Name: app.HelmConfig.RepoRef.Name,
+					Path: app.HelmConfig.RepoRef.Path,
+				}
+			}
+			for _, ref := range app.HelmConfig.ValuesFrom {
+				hconfig.ValuesFrom = append(hconfig.ValuesFrom, kfconfig.HelmValuesReference{
+					Name: ref.Name,
+					Key:  ref.Key,
+				})
+			}
+			application.HelmConfig = hconfig
+		}
 		config.Spec.Applications = append(config.Spec.Applications, application)
 	}
 
@@ -119,23 +170,72 @@ func (v V1) LoadKfConfig(def interface{}) (*kfconfig.KfConfig, error) {
 				Value: secret.SecretSource.LiteralSource.Value,
 			}
 		}
+		if secret.SecretSource.HashedSource != nil {
+			src.HashedSource = &kfconfig.HashedSource{
+				HashedValue: secret.SecretSource.HashedSource.HashedValue,
+			}
+		}
 		if secret.SecretSource.EnvSource != nil {
 			src.EnvSource = &kfconfig.EnvSource{
 				Name: secret.SecretSource.EnvSource.Name,
 			}
 		}
+		if ref := secret.SecretSource.SecretKeyRef; ref != nil {
+			src.SecretKeyRef = &kfconfig.KeySelector{Name: ref.Name, Key: ref.Key}
+		}
+		if ext := secret.SecretSource.ExternalSource; ext != nil {
+			src.ExternalSource = &kfconfig.ExternalSource{
+				Provider: ext.Provider,
+				Path:     ext.Path,
+				Key:      ext.Key,
+				Hashed:   ext.Hashed,
+			}
+		}
 		s.SecretSource = src
 		config.Spec.Secrets = append(config.Spec.Secrets, s)
 	}
 
 	for _, repo := range kfdef.Spec.Repos {
 		r := kfconfig.Repo{
-			Name: repo.Name,
-			URI:  repo.URI,
+			Name:   repo.Name,
+			URI:    repo.URI,
+			Ref:    repo.Ref,
+			Tag:    repo.Tag,
+			Commit: repo.Commit,
+			Sha256: repo.Sha256,
+		}
+		if repo.Signature != nil {
+			r.Signature = &kfconfig.RepoSignature{
+				Type: repo.Signature.Type,
+				URI:  repo.Signature.URI,
+				PublicKeySecretRef: kfconfig.KeySelector{
+					Name: repo.Signature.PublicKeySecretRef.Name,
+					Key:  repo.Signature.PublicKeySecretRef.Key,
+				},
+			}
+		}
+		if repo.AuthSecretRef != nil {
+			r.AuthSecretRef = &kfconfig.SecretRef{Name: repo.AuthSecretRef.Name}
+		}
+		if repo.Channel != nil {
+			r.Channel = &kfconfig.RepoChannel{
+				IndexURI:   repo.Channel.IndexURI,
+				Constraint: repo.Channel.Constraint,
+				Approval:   repo.Channel.Approval,
+			}
 		}
 		config.Spec.Repos = append(config.Spec.Repos, r)
 	}
 
+	if kfdef.Spec.Export != nil {
+		config.Spec.Export = &kfconfig.ExportSpec{
+			Path:                kfdef.Spec.Export.Path,
+			ConfigMap:           kfdef.Spec.Export.ConfigMap,
+			Archive:             kfdef.Spec.Export.Archive,
+			OperatorAnnotations: kfdef.Spec.Export.OperatorAnnotations,
+		}
+	}
+
 	for _, cond := range kfdef.Status.Conditions {
 		c := kfconfig.Condition{
 			Type:               kfconfig.ConditionType(cond.Type),
@@ -151,9 +251,22 @@ func (v V1) LoadKfConfig(def interface{}) (*kfconfig.KfConfig, error) {
 		c := kfconfig.Cache{
 			Name:      cache.Name,
 			LocalPath: cache.LocalPath,
+			Commit:    cache.Commit,
+			Version:   cache.Version,
 		}
 		config.Status.Caches = append(config.Status.Caches, c)
 	}
+	for _, channel := range kfdef.Status.Channels {
+		config.Status.Channels = append(config.Status.Channels, kfconfig.RepoChannelStatus{
+			Name:          channel.Name,
+			Version:       channel.Version,
+			URI:           channel.URI,
+			Sha256:        channel.Sha256,
+			LatestVersion: channel.LatestVersion,
+			LastPollTime:  channel.LastPollTime,
+			Message:       channel.Message,
+		})
+	}
 
 	return config, nil
 }
@@ -174,7 +287,8 @@ func (v V1) LoadKfDef(config kfconfig.KfConfig, out interface{}) error {
 		}
 		if app.KustomizeConfig != nil {
 			kconfig := &kfdeftypes.KustomizeConfig{
-				Overlays: app.KustomizeConfig.Overlays,
+				Overlays:              app.KustomizeConfig.Overlays,
+				PatchesStrategicMerge: app.KustomizeConfig.PatchesStrategicMerge,
 			}
 			if app.KustomizeConfig.RepoRef != nil {
 				kref := &kfdeftypes.RepoRef{
@@ -188,10 +302,60 @@ func (v V1) LoadKfDef(config kfconfig.KfConfig, out interface{}) error {
 					Name:  param.Name,
 					Value: param.Value,
 				}
+				if param.ValueFrom != nil {
+					p.ValueFrom = &kfdeftypes.ParameterSource{}
+					if ref := param.ValueFrom.ConfigMapKeyRef; ref != nil {
+						p.ValueFrom.ConfigMapKeyRef = &kfdeftypes.KeySelector{Name: ref.Name, Key: ref.Key}
+					}
+					if ref := param.ValueFrom.SecretKeyRef; ref != nil {
+						p.ValueFrom.SecretKeyRef = &kfdeftypes.KeySelector{Name: ref.Name, Key: ref.Key}
+					}
+				}
 				kconfig.Parameters = append(kconfig.Parameters, p)
 			}
+			for _, patch := range app.KustomizeConfig.PatchesJson6902 {
+				kconfig.PatchesJson6902 = append(kconfig.PatchesJson6902, kfdeftypes.JSONPatch{
+					Target: kfdeftypes.PatchTarget{
+						Group:     patch.Target.Group,
+						Version:   patch.Target.Version,
+						Kind:      patch.Target.Kind,
+						Name:      patch.Target.Name,
+						Namespace: patch.Target.Namespace,
+					},
+					Patch: patch.Patch,
+				})
+			}
+			for _, image := range app.KustomizeConfig.Images {
+				kconfig.Images = append(kconfig.Images, kfdeftypes.Image{
+					Name:    image.Name,
+					NewName: image.NewName,
+					NewTag:  image.NewTag,
+					Digest:  image.Digest,
+				})
+			}
 			application.KustomizeConfig = kconfig
 		}
+		if app.HelmConfig != nil {
+			hconfig := &kfdeftypes.HelmConfig{
+				ChartPath:   app.HelmConfig.ChartPath,
+				ReleaseName: app.HelmConfig.ReleaseName,
+				Namespace:   app.HelmConfig.Namespace,
+				Values:      app.HelmConfig.Values,
+			}
+			if app.HelmConfig.RepoRef != nil {
+				hconfig.RepoRef = &kfdeftypes.RepoRef{
+					Name: app.HelmConfig.RepoRef.Name,
+					Path: app.HelmConfig.RepoRef.Path,
+				}
+			}
+			for _, ref := range app.HelmConfig.ValuesFrom {
+				hconfig.ValuesFrom = append(hconfig.ValuesFrom, kfdeftypes.HelmValuesReference{
+					Name: ref.Name,
+					Key:  ref.Key,
+				})
+			}
+			application.HelmConfig = hconfig
+		}
 		kfdef.Spec.Applications = append(kfdef.Spec.Applications, application)
 	}
 
@@ -211,23 +375,72 @@ func (v V1) LoadKfDef(config kfconfig.KfConfig, out interface{}) error {
 		if secret.SecretSource != nil {
 			s.SecretSource = &kfdeftypes.SecretSource{}
 			// We don't want to store literalSource explictly, becasue we want the config to be checked into source control and don't want secrets in source control.
+			if secret.SecretSource.HashedSource != nil {
+				s.SecretSource.HashedSource = &kfdeftypes.HashedSource{
+					HashedValue: secret.SecretSource.HashedSource.HashedValue,
+				}
+			}
 			if secret.SecretSource.EnvSource != nil {
 				s.SecretSource.EnvSource = &kfdeftypes.EnvSource{
 					Name: secret.SecretSource.EnvSource.Name,
 				}
 			}
+			if ref := secret.SecretSource.SecretKeyRef; ref != nil {
+				s.SecretSource.SecretKeyRef = &kfdeftypes.KeySelector{Name: ref.Name, Key: ref.Key}
+			}
+			if ext := secret.SecretSource.ExternalSource; ext != nil {
+				s.SecretSource.ExternalSource = &kfdeftypes.ExternalSource{
+					Provider: ext.Provider,
+					Path:     ext.Path,
+					Key:      ext.Key,
+					Hashed:   ext.Hashed,
+				}
+			}
 		}
 		kfdef.Spec.Secrets = append(kfdef.Spec.Secrets, s)
 	}
 
 	for _, repo := range config.Spec.Repos {
 		r := kfdeftypes.Repo{
-			Name: repo.Name,
-			URI:  repo.URI,
+			Name:   repo.Name,
+			URI:    repo.URI,
+			Ref:    repo.Ref,
+			Tag:    repo.Tag,
+			Commit: repo.Commit,
+			Sha256: repo.Sha256,
+		}
+		if repo.Signature != nil {
+			r.Signature = &kfdeftypes.RepoSignature{
+				Type: repo.Signature.Type,
+				URI:  repo.Signature.URI,
+				PublicKeySecretRef: kfdeftypes.KeySelector{
+					Name: repo.Signature.PublicKeySecretRef.Name,
+					Key:  repo.Signature.PublicKeySecretRef.Key,
+				},
+			}
+		}
+		if repo.AuthSecretRef != nil {
+			r.AuthSecretRef = &kfdeftypes.SecretRef{Name: repo.AuthSecretRef.Name}
+		}
+		if repo.Channel != nil {
+			r.Channel = &kfdeftypes.RepoChannel{
+				IndexURI:   repo.Channel.IndexURI,
+				Constraint: repo.Channel.Constraint,
+				Approval:   repo.Channel.Approval,
+			}
 		}
 		kfdef.Spec.Repos = append(kfdef.Spec.Repos, r)
 	}
 
+	if config.Spec.Export != nil {
+		kfdef.Spec.Export = &kfdeftypes.ExportSpec{
+			Path:                config.Spec.Export.Path,
+			ConfigMap:           config.Spec.Export.ConfigMap,
+			Archive:             config.Spec.Export.Archive,
+			OperatorAnnotations: config.Spec.Export.OperatorAnnotations,
+		}
+	}
+
 	for _, cond := range config.Status.Conditions {
 		c := kfdeftypes.KfDefCondition{
 			Type:               kfdeftypes.KfDefConditionType(cond.Type),
@@ -244,10 +457,24 @@ func (v V1) LoadKfDef(config kfconfig.KfConfig, out interface{}) error {
 		c := kfdeftypes.RepoCache{
 			Name:      cache.Name,
 			LocalPath: cache.LocalPath,
+			Commit:    cache.Commit,
+			Version:   cache.Version,
 		}
 		kfdef.Status.ReposCache = append(kfdef.Status.ReposCache, c)
 	}
 
+	for _, channel := range config.Status.Channels {
+		kfdef.Status.Channels = append(kfdef.Status.Channels, kfdeftypes.RepoChannelStatus{
+			Name:          channel.Name,
+			Version:       channel.Version,
+			URI:           channel.URI,
+			Sha256:        channel.Sha256,
+			LatestVersion: channel.LatestVersion,
+			LastPollTime:  channel.LastPollTime,
+			Message:       channel.Message,
+		})
+	}
+
 	kfdefBytes, err := yaml.Marshal(kfdef)
 	if err != nil {
 		return &kfapis.KfError{
@@ -0,0 +1,408 @@
+package kfconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/otiai10/copy"
+	log "github.com/sirupsen/logrus"
+)
+
+// SharedRepoCache is the process-wide cache of repositories used by SyncCache. Each KfConfig downloads
+// the repositories it uses when it's nil.
+var SharedRepoCache *RepoCache
+
+// RepoCacheOptions configure a RepoCache.
+type RepoCacheOptions struct {
+	// Dir holds the cached repositories. Its content is removed when the cache is created.
+	Dir string
+	// TTL is how long a repository no KfDef references is kept.
+	TTL time.Duration
+	// MaxSize is the total size in bytes of the cached repositories above which repositories no KfDef
+	// references are evicted, least recently used first. There is no limit when it's 0.
+	MaxSize int64
+	// RefreshInterval is how long the digest a URI resolved to is reused before the URI is fetched again.
+	// Repositories pinned to a sha256 or a full commit id are never fetched again while cached.
+	RefreshInterval time.Duration
+}
+
+// RepoCache caches repository tarballs and git checkouts, keyed by their URI and the sha256 or commit
+// they resolved to, so KfDefs using the same repositories fetch them once. Each KfDef references the
+// entries of its repositories until it syncs them to another digest or is released; referenced entries
+// are never evicted. Entries of repositories fetched with credentials are only shared by the KfDefs
+// using the same credentials Secret.
+//
+// A RepoCache is safe for concurrent use. The fetches of a repository are serialized, so concurrent
+// KfDefs wait for a repository being fetched rather than fetching it again, while the other repositories
+// are fetched concurrently.
+type RepoCache struct {
+	opts RepoCacheOptions
+
+	mu sync.Mutex
+	// entries by key
+	entries map[string]*repoCacheEntry
+	// keys of the entries the sources resolved to
+	resolved map[string]repoResolution
+	// keys of the entries referenced by each owner and repository
+	refs map[string]string
+	// locks of the sources being fetched
+	fetching map[string]*sourceLock
+	now      func() time.Time
+}
+
+// sourceLock serializes the fetches of a source.
+type sourceLock struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+type repoCacheEntry struct {
+	key string
+	// path is the tarball file or the checkout directory
+	path     string
+	commit   string
+	size     int64
+	refs     int
+	lastUsed time.Time
+}
+
+type repoResolution struct {
+	key string
+	at  time.Time
+}
+
+// NewRepoCache returns an empty cache storing the repositories in opts.Dir.
+func NewRepoCache(opts RepoCacheOptions) (*RepoCache, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("the repository cache directory must be specified")
+	}
+	if err := os.RemoveAll(opts.Dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, err
+	}
+	return &RepoCache{
+		opts:     opts,
+		entries:  map[string]*repoCacheEntry{},
+		resolved: map[string]repoResolution{},
+		refs:     map[string]string{},
+		fetching: map[string]*sourceLock{},
+		now:      time.Now,
+	}, nil
+}
+
+// repoCacheOwner identifies the KfDef of c referencing cache entries.
+func (c *KfConfig) repoCacheOwner() string {
+	return repoCacheOwner(c.Namespace, c.Name)
+}
+
+func repoCacheOwner(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// repoCacheScope restricts sharing the entries of r to the KfDefs with the same credentials.
+func (c *KfConfig) repoCacheScope(r Repo) string {
+	if r.AuthSecretRef == nil || r.AuthSecretRef.Name == "" {
+		return ""
+	}
+	return c.Namespace + "/" + r.AuthSecretRef.Name
+}
+
+// Tarball returns the tarball of r, calling fetch when it isn't cached. The entry is referenced by
+// repo of owner.
+func (rc *RepoCache) Tarball(owner string, scope string, r Repo, fetch func() ([]byte, error)) ([]byte, error) {
+	source := repoCacheKey(scope, r.URI, "")
+	unlock := rc.lockSource(source)
+	defer unlock()
+
+	key := ""
+	if r.Sha256 != "" {
+		key = repoCacheKey(scope, r.URI, "sha256:"+strings.ToLower(strings.TrimPrefix(r.Sha256, "sha256:")))
+	}
+	rc.mu.Lock()
+	if entry := rc.lookup(source, key); entry != nil {
+		if body, err := ioutil.ReadFile(entry.path); err == nil {
+			log.Infof("Using cached repo %v", r.URI)
+			rc.reference(owner, r.Name, entry)
+			rc.mu.Unlock()
+			return body, nil
+		}
+		rc.remove(entry)
+	}
+	rc.mu.Unlock()
+
+	// The other repositories aren't blocked while r is fetched
+	body, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	sum := sha256.Sum256(body)
+	key = repoCacheKey(scope, r.URI, "sha256:"+hex.EncodeToString(sum[:]))
+	entry, ok := rc.entries[key]
+	if !ok {
+		path := rc.entryPath(key)
+		if err := ioutil.WriteFile(path, body, 0644); err != nil {
+			log.Errorf("Could not cache repo %v; error %v", r.URI, err)
+			return body, nil
+		}
+		entry = &repoCacheEntry{key: key, path: path, size: int64(len(body))}
+		rc.entries[key] = entry
+	}
+	rc.resolved[source] = repoResolution{key: key, at: rc.now()}
+	rc.reference(owner, r.Name, entry)
+	rc.evict()
+	return body, nil
+}
+
+// Checkout copies the checkout of the git repository r to dir and returns its commit, calling fetch
+// to check it out when it isn't cached. The entry is referenced by repo of owner.
+func (rc *RepoCache) Checkout(owner string, scope string, r Repo, dir string, fetch func(dir string) (string, error)) (string, error) {
+	source := repoCacheKey(scope, r.URI, r.Ref+"#"+r.Tag+"#"+r.Commit)
+	unlock := rc.lockSource(source)
+	defer unlock()
+
+	key := ""
+	if len(r.Commit) == 40 {
+		key = repoCacheKey(scope, r.URI, strings.ToLower(r.Commit))
+	}
+	rc.mu.Lock()
+	if entry := rc.lookup(source, key); entry != nil {
+		if err := copy.Copy(entry.path, dir); err == nil {
+			log.Infof("Using cached repo %v at commit %v", r.URI, entry.commit)
+			rc.reference(owner, r.Name, entry)
+			rc.mu.Unlock()
+			return entry.commit, nil
+		}
+		rc.remove(entry)
+	}
+	rc.mu.Unlock()
+
+	// The other repositories aren't blocked while r is fetched
+	checkout, err := ioutil.TempDir(rc.opts.Dir, "checkout")
+	if err != nil {
+		return "", err
+	}
+	commit, err := fetch(checkout)
+	if err != nil {
+		os.RemoveAll(checkout)
+		return "", err
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	key = repoCacheKey(scope, r.URI, commit)
+	entry, ok := rc.entries[key]
+	if ok {
+		os.RemoveAll(checkout)
+	} else {
+		path := rc.entryPath(key)
+		if err := os.Rename(checkout, path); err != nil {
+			os.RemoveAll(checkout)
+			return "", err
+		}
+		entry = &repoCacheEntry{key: key, path: path, commit: commit, size: dirSize(path)}
+		rc.entries[key] = entry
+	}
+	rc.resolved[source] = repoResolution{key: key, at: rc.now()}
+	if err := copy.Copy(entry.path, dir); err != nil {
+		return "", err
+	}
+	rc.reference(owner, r.Name, entry)
+	rc.evict()
+	return commit, nil
+}
+
+// lockSource waits for the fetch of source in progress, and returns the function ending the fetch of
+// the caller.
+func (rc *RepoCache) lockSource(source string) func() {
+	rc.mu.Lock()
+	lock, ok := rc.fetching[source]
+	if !ok {
+		lock = &sourceLock{}
+		rc.fetching[source] = lock
+	}
+	lock.waiters++
+	rc.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(rc.fetching, source)
+		}
+	}
+}
+
+// Release drops the references of the KfDef name of namespace, letting its entries be evicted.
+// rc can be nil.
+func (rc *RepoCache) Release(namespace, name string) {
+	rc.Retain(namespace, name, nil)
+}
+
+// Retain drops the references of the repositories of the KfDef name of namespace that aren't in repos,
+// letting the entries of the repositories removed from its spec be evicted. rc can be nil.
+func (rc *RepoCache) Retain(namespace, name string, repos []string) {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	retained := map[string]bool{}
+	owner := repoCacheOwner(namespace, name)
+	for _, repo := range repos {
+		retained[owner+"\x00"+repo] = true
+	}
+	prefix := owner + "\x00"
+	for ref, key := range rc.refs {
+		if !strings.HasPrefix(ref, prefix) || retained[ref] {
+			continue
+		}
+		if entry, ok := rc.entries[key]; ok {
+			entry.refs--
+			entry.lastUsed = rc.now()
+		}
+		delete(rc.refs, ref)
+	}
+	rc.evict()
+}
+
+// Evict removes the expired entries, and the least recently used ones while the cache exceeds its maximum
+// size. The operator calls it periodically, as entries otherwise expire only when the cache is used.
+// rc can be nil.
+func (rc *RepoCache) Evict() {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.evict()
+}
+
+// Size returns the total size of the cached repositories.
+func (rc *RepoCache) Size() int64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.size()
+}
+
+func (rc *RepoCache) size() int64 {
+	var size int64
+	for _, entry := range rc.entries {
+		size += entry.size
+	}
+	return size
+}
+
+// lookup returns the entry key, or else the entry source resolved to if it's fresh.
+func (rc *RepoCache) lookup(source string, key string) *repoCacheEntry {
+	if key != "" {
+		return rc.entries[key]
+	}
+	resolution, ok := rc.resolved[source]
+	if !ok || rc.now().Sub(resolution.at) > rc.opts.RefreshInterval {
+		return nil
+	}
+	return rc.entries[resolution.key]
+}
+
+// reference makes repo of owner reference entry instead of the entry it referenced.
+func (rc *RepoCache) reference(owner string, repo string, entry *repoCacheEntry) {
+	ref := owner + "\x00" + repo
+	entry.lastUsed = rc.now()
+	if key, ok := rc.refs[ref]; ok {
+		if key == entry.key {
+			return
+		}
+		if previous, ok := rc.entries[key]; ok {
+			previous.refs--
+			previous.lastUsed = rc.now()
+		}
+	}
+	rc.refs[ref] = entry.key
+	entry.refs++
+}
+
+// evict removes the unreferenced entries unused for longer than the TTL, then the least recently used
+// unreferenced entries until the cache fits in the maximum size.
+func (rc *RepoCache) evict() {
+	unreferenced := []*repoCacheEntry{}
+	for _, entry := range rc.entries {
+		if entry.refs > 0 {
+			continue
+		}
+		if rc.now().Sub(entry.lastUsed) > rc.opts.TTL {
+			log.Infof("Evicting expired repo cache entry %v", entry.path)
+			rc.remove(entry)
+			continue
+		}
+		unreferenced = append(unreferenced, entry)
+	}
+	if rc.opts.MaxSize <= 0 {
+		return
+	}
+	sort.Slice(unreferenced, func(i, j int) bool {
+		return unreferenced[i].lastUsed.Before(unreferenced[j].lastUsed)
+	})
+	size := rc.size()
+	for _, entry := range unreferenced {
+		if size <= rc.opts.MaxSize {
+			return
+		}
+		log.Infof("Evicting repo cache entry %v; cache size %v exceeds %v", entry.path, size, rc.opts.MaxSize)
+		size -= entry.size
+		rc.remove(entry)
+	}
+	if size > rc.opts.MaxSize {
+		log.Warnf("Repo cache size %v exceeds %v with referenced entries only", size, rc.opts.MaxSize)
+	}
+}
+
+func (rc *RepoCache) remove(entry *repoCacheEntry) {
+	if err := os.RemoveAll(entry.path); err != nil {
+		log.Errorf("Could not remove repo cache entry %v; error %v", entry.path, err)
+	}
+	delete(rc.entries, entry.key)
+	for source, resolution := range rc.resolved {
+		if resolution.key == entry.key {
+			delete(rc.resolved, source)
+		}
+	}
+}
+
+// entryPath returns the path of the entry key, named after the digest of the key.
+func (rc *RepoCache) entryPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(rc.opts.Dir, hex.EncodeToString(sum[:]))
+}
+
+func repoCacheKey(scope string, uri string, digest string) string {
+	return scope + "\x00" + uri + "\x00" + digest
+}
+
+// dirSize returns the total size of the files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, fi os.FileInfo, err error) error {
+		if err == nil && fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size
+}
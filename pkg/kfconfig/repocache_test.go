@@ -0,0 +1,283 @@
+package kfconfig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestRepoCache returns a cache in a temp dir with a clock the test advances.
+func newTestRepoCache(t *testing.T, opts RepoCacheOptions) (*RepoCache, *time.Time) {
+	opts.Dir = path.Join(t.TempDir(), "repos")
+	rc, err := NewRepoCache(opts)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	now := time.Now()
+	rc.now = func() time.Time { return now }
+	return rc, &now
+}
+
+func TestSyncCacheShared(t *testing.T) {
+	tarball := testTarball(t)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write(tarball)
+	}))
+	defer srv.Close()
+
+	rc, _ := newTestRepoCache(t, RepoCacheOptions{TTL: time.Hour, RefreshInterval: time.Minute})
+	defer func(c *RepoCache) { SharedRepoCache = c }(SharedRepoCache)
+	SharedRepoCache = rc
+
+	testDir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		appDir := path.Join(testDir, fmt.Sprintf("app-%v", i))
+		config := &KfConfig{Spec: KfConfigSpec{AppDir: appDir, Repos: []Repo{{Name: "manifests", URI: srv.URL + "/manifests.tar.gz"}}}}
+		config.Namespace = "kubeflow"
+		config.Name = fmt.Sprintf("kfdef-%v", i)
+		if err := config.SyncCache(); err != nil {
+			t.Fatalf("Failed to sync cache: %v", err)
+		}
+		if _, err := os.Stat(path.Join(appDir, DefaultCacheDir, "manifests", "manifests", "file1")); err != nil {
+			t.Errorf("%v: expect the tarball to be extracted: %v", config.Name, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expect the tarball to be downloaded once, got %v downloads", requests)
+	}
+	if len(rc.entries) != 1 {
+		t.Fatalf("expect one entry, got %v", len(rc.entries))
+	}
+	for _, entry := range rc.entries {
+		if entry.refs != 10 || entry.size != int64(len(tarball)) {
+			t.Errorf("expect an entry of size %v referenced 10 times, got %+v", len(tarball), entry)
+		}
+	}
+}
+
+func TestRepoCacheTarball(t *testing.T) {
+	rc, now := newTestRepoCache(t, RepoCacheOptions{TTL: time.Hour, RefreshInterval: time.Minute})
+	content := map[string]string{"a": "a1"}
+	fetches := 0
+	tarball := func(owner string, scope string, r Repo) string {
+		body, err := rc.Tarball(owner, scope, r, func() ([]byte, error) {
+			fetches++
+			return []byte(content[r.URI]), nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to get tarball: %v", err)
+		}
+		return string(body)
+	}
+	sha := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tarball("ns/one", "", Repo{Name: "manifests", URI: "a"})
+	tarball("ns/two", "", Repo{Name: "manifests", URI: "a"})
+	if fetches != 1 {
+		t.Errorf("expect a single fetch, got %v", fetches)
+	}
+	tarball("ns/three", "ns/creds", Repo{Name: "manifests", URI: "a"})
+	if fetches != 2 {
+		t.Errorf("expect a fetch with other credentials, got %v fetches", fetches)
+	}
+
+	// the resolution of unpinned URIs expires, pinned ones are used as long as they are cached
+	content["a"] = "a2"
+	*now = now.Add(2 * time.Minute)
+	if body := tarball("ns/one", "", Repo{Name: "manifests", URI: "a", Sha256: "sha256:" + sha("a1")}); body != "a1" || fetches != 2 {
+		t.Errorf("expect the pinned tarball a1 from the cache, got %v after %v fetches", body, fetches)
+	}
+	if body := tarball("ns/two", "", Repo{Name: "manifests", URI: "a"}); body != "a2" || fetches != 3 {
+		t.Errorf("expect tarball a2 to be fetched, got %v after %v fetches", body, fetches)
+	}
+	if body := tarball("ns/three", "", Repo{Name: "manifests", URI: "a"}); body != "a2" || fetches != 3 {
+		t.Errorf("expect tarball a2 from the cache, got %v after %v fetches", body, fetches)
+	}
+	if body := tarball("ns/four", "", Repo{Name: "manifests", URI: "a", Sha256: sha("other")}); body != "a2" || fetches != 4 {
+		t.Errorf("expect a tarball pinned to a missing digest to be fetched, got %v after %v fetches", body, fetches)
+	}
+}
+
+func TestRepoCacheEviction(t *testing.T) {
+	rc, now := newTestRepoCache(t, RepoCacheOptions{TTL: time.Hour, MaxSize: 10, RefreshInterval: time.Minute})
+	content := map[string]string{"a": "aaaaaa", "b": "bbbbbb", "c": "cccccc"}
+	tarball := func(name string, uri string) {
+		_, err := rc.Tarball(repoCacheOwner("ns", name), "", Repo{Name: "manifests", URI: uri},
+			func() ([]byte, error) { return []byte(content[uri]), nil })
+		if err != nil {
+			t.Fatalf("Failed to get tarball: %v", err)
+		}
+	}
+	cached := func(uri string) bool {
+		sum := sha256.Sum256([]byte(content[uri]))
+		entry, ok := rc.entries[repoCacheKey("", uri, "sha256:"+hex.EncodeToString(sum[:]))]
+		if !ok {
+			return false
+		}
+		_, err := os.Stat(entry.path)
+		return err == nil
+	}
+
+	tarball("one", "a")
+	tarball("two", "b")
+	if !cached("a") || !cached("b") || rc.Size() != 12 {
+		t.Fatalf("expect referenced entries above the maximum size to be kept, got size %v", rc.Size())
+	}
+
+	// releasing one makes the cache evict it to fit in the maximum size
+	rc.Release("ns", "one")
+	if cached("a") || !cached("b") {
+		t.Errorf("expect a to be evicted")
+	}
+
+	// another URI replacing the reference of an owner
+	tarball("two", "c")
+	if !cached("c") || cached("b") {
+		t.Errorf("expect b to be evicted once unreferenced")
+	}
+
+	// unreferenced entries expire
+	rc.opts.MaxSize = 0
+	tarball("three", "a")
+	rc.Release("ns", "three")
+	if !cached("a") {
+		t.Errorf("expect a to be kept until it expires")
+	}
+	*now = now.Add(2 * time.Hour)
+	rc.Release("ns", "two")
+	if cached("a") || !cached("c") {
+		t.Errorf("expect a to expire and c to be kept")
+	}
+}
+
+func TestRepoCacheCheckout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	testDir := t.TempDir()
+	bare, first, second := newBareRepo(t, testDir)
+	rc, _ := newTestRepoCache(t, RepoCacheOptions{TTL: time.Hour, RefreshInterval: time.Minute})
+
+	fetches := 0
+	checkout := func(owner string, r Repo, expectedCommit string, expectedVersion string) {
+		dir := path.Join(testDir, "checkouts", owner)
+		commit, err := rc.Checkout(owner, "", r, dir, func(dir string) (string, error) {
+			fetches++
+			return fetchGitRepo(r, dir, nil)
+		})
+		if err != nil {
+			t.Fatalf("Failed to check out %v: %v", r.URI, err)
+		}
+		version, err := ioutil.ReadFile(path.Join(dir, "version"))
+		if commit != expectedCommit || err != nil || string(version) != expectedVersion {
+			t.Errorf("%v: expect version %v at %v, got %v at %v (%v)", owner, expectedVersion, expectedCommit,
+				string(version), commit, err)
+		}
+	}
+
+	checkout("ns/one", Repo{Name: "manifests", URI: bare}, second, "v2")
+	checkout("ns/two", Repo{Name: "manifests", URI: bare}, second, "v2")
+	checkout("ns/three", Repo{Name: "manifests", URI: bare, Tag: "v1"}, first, "v1")
+	checkout("ns/four", Repo{Name: "manifests", URI: bare, Commit: first}, first, "v1")
+	if fetches != 2 {
+		t.Errorf("expect 2 fetches, got %v", fetches)
+	}
+	if len(rc.entries) != 2 {
+		t.Errorf("expect an entry per commit, got %v", len(rc.entries))
+	}
+}
+
+func TestRepoCacheRetain(t *testing.T) {
+	rc, now := newTestRepoCache(t, RepoCacheOptions{TTL: time.Hour, RefreshInterval: time.Minute})
+	tarball := func(repo string, uri string) string {
+		_, err := rc.Tarball(repoCacheOwner("ns", "kfdef"), "", Repo{Name: repo, URI: uri},
+			func() ([]byte, error) { return []byte(uri), nil })
+		if err != nil {
+			t.Fatalf("Failed to get tarball: %v", err)
+		}
+		sum := sha256.Sum256([]byte(uri))
+		return repoCacheKey("", uri, "sha256:"+hex.EncodeToString(sum[:]))
+	}
+	cached := func(key string) bool {
+		_, ok := rc.entries[key]
+		return ok
+	}
+
+	kept := tarball("manifests", "a")
+	removed := tarball("extra", "b")
+
+	// the repository removed from the spec is no longer referenced, and expires without the cache being used
+	rc.Retain("ns", "kfdef", []string{"manifests"})
+	*now = now.Add(2 * time.Hour)
+	rc.Evict()
+	if !cached(kept) || cached(removed) {
+		t.Errorf("expect the entry of the removed repository to expire and the other to be kept")
+	}
+
+	rc.Release("ns", "kfdef")
+	*now = now.Add(2 * time.Hour)
+	rc.Evict()
+	if cached(kept) {
+		t.Errorf("expect the released entry to expire")
+	}
+
+	var nilCache *RepoCache
+	nilCache.Retain("ns", "kfdef", nil)
+	nilCache.Evict()
+}
+
+func TestRepoCacheConcurrentFetch(t *testing.T) {
+	rc, _ := newTestRepoCache(t, RepoCacheOptions{TTL: time.Hour, RefreshInterval: time.Minute})
+
+	// a slow fetch of a source doesn't block the other sources
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := rc.Tarball(repoCacheOwner("ns", "one"), "", Repo{Name: "manifests", URI: "slow"}, func() ([]byte, error) {
+			close(started)
+			<-release
+			return []byte("slow"), nil
+		})
+		done <- err
+	}()
+	<-started
+	if _, err := rc.Tarball(repoCacheOwner("ns", "two"), "", Repo{Name: "manifests", URI: "fast"},
+		func() ([]byte, error) { return []byte("fast"), nil }); err != nil {
+		t.Errorf("Failed to get tarball: %v", err)
+	}
+
+	// the concurrent fetches of a source are done once
+	var fetches int32
+	waiting := make(chan error)
+	go func() {
+		_, err := rc.Tarball(repoCacheOwner("ns", "three"), "", Repo{Name: "manifests", URI: "slow"}, func() ([]byte, error) {
+			atomic.AddInt32(&fetches, 1)
+			return []byte("slow"), nil
+		})
+		waiting <- err
+	}()
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Failed to get tarball: %v", err)
+	}
+	if err := <-waiting; err != nil {
+		t.Errorf("Failed to get tarball: %v", err)
+	}
+	if fetches != 0 {
+		t.Errorf("expect the waiting fetch to use the cached tarball, got %v fetches", fetches)
+	}
+}
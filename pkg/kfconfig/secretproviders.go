@@ -0,0 +1,178 @@
+package kfconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	kfapis "github.com/opendatahub-io/opendatahub-operator/apis"
+)
+
+const (
+	// FileSecretProviderName is the name of the provider reading secrets from mounted files.
+	FileSecretProviderName = "file"
+	// VaultSecretProviderName is the name of the provider reading secrets from a Vault-compatible KV API.
+	VaultSecretProviderName = "vault"
+)
+
+// SecretProvider reads secrets from an external secrets store. The secrets of a KfDef are scoped to
+// its namespace, so that a KfDef can't read the secrets of the other namespaces.
+type SecretProvider interface {
+	// GetSecret returns the value of key in the secret at path of namespace.
+	GetSecret(namespace string, path string, key string) (string, error)
+}
+
+// SecretProviders are the providers of the external secret sources by name, configured by the operator.
+var SecretProviders = map[string]SecretProvider{}
+
+// FileSecretProvider reads secrets from files under Dir, e.g. the mount of a secrets store CSI volume.
+// The key of the secret at path of namespace is the file <Dir>/<namespace>/<path>/<key>, or
+// <Dir>/<namespace>/<path> when key is empty.
+type FileSecretProvider struct {
+	Dir string
+}
+
+// GetSecret implements SecretProvider.
+func (p *FileSecretProvider) GetSecret(namespace string, path string, key string) (string, error) {
+	dir := filepath.Join(p.Dir, namespace)
+	if namespace == "" || strings.ContainsRune(namespace, '/') || filepath.Dir(dir) != filepath.Clean(p.Dir) {
+		return "", fmt.Errorf("invalid namespace %q", namespace)
+	}
+	name := filepath.Join(dir, filepath.FromSlash(path), key)
+	rel, err := filepath.Rel(dir, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("secret path %v escapes the secrets of namespace %v", filepath.Join(path, key), namespace)
+	}
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// VaultSecretProvider reads secrets from a Vault-compatible HTTP API, with KV version 1 or 2 engines.
+// The secret at path of namespace is read from <Mount>/<namespace>/<path>, or from
+// <Mount>/data/<namespace>/<path> with KV version 2.
+type VaultSecretProvider struct {
+	// Address of the server, e.g. https://vault.example.com:8200.
+	Address string
+	// Mount of the KV engine, secret when empty.
+	Mount string
+	// KVVersion of the engine, 1 or 2; 2 when zero.
+	KVVersion int
+	// Token authenticating the requests. TokenFile is read on every request when Token is empty,
+	// so that rotated tokens are used.
+	Token     string
+	TokenFile string
+	// Namespace of the secrets, for servers with namespaces.
+	Namespace string
+	// Client sends the requests; vaultClient when nil.
+	Client *http.Client
+}
+
+// vaultClient sends the requests of the vault providers without a client, so that an unresponsive
+// server doesn't block the reconciles.
+var vaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// vaultResponse is the body of a read, {"data": {...}} with KV version 1 and
+// {"data": {"data": {...}, "metadata": {...}}} with KV version 2.
+type vaultResponse struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// GetSecret implements SecretProvider.
+func (p *VaultSecretProvider) GetSecret(namespace string, secretPath string, key string) (string, error) {
+	if namespace == "" || strings.ContainsRune(namespace, '/') || namespace == "." || namespace == ".." {
+		return "", fmt.Errorf("invalid namespace %q", namespace)
+	}
+	mount := strings.Trim(p.Mount, "/")
+	if mount == "" {
+		mount = "secret"
+	}
+	if p.KVVersion != 1 {
+		mount += "/data"
+	}
+	// Cleaning the path from the root keeps it under the secrets of the namespace
+	secretPath = strings.TrimPrefix(path.Clean("/"+secretPath), "/")
+	if secretPath == "" {
+		return "", fmt.Errorf("the secret path is empty")
+	}
+	token := p.Token
+	if token == "" && p.TokenFile != "" {
+		data, err := ioutil.ReadFile(p.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("couldn't read the Vault token: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	u, err := url.Parse(strings.TrimSuffix(p.Address, "/") + "/v1/" + path.Join(mount, namespace, secretPath))
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+	client := p.Client
+	if client == nil {
+		client = vaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("couldn't read secret %v: %v", secretPath, resp.Status)
+	}
+	body := &vaultResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return "", fmt.Errorf("invalid response reading secret %v: %v", secretPath, err)
+	}
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %v not found in secret %v", key, secretPath)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("key %v of secret %v isn't a string", key, secretPath)
+	}
+	return s, nil
+}
+
+// readExternalSecret returns the value of the secret name of a KfDef of namespace read by the provider of src.
+func readExternalSecret(namespace string, name string, src *ExternalSource) (string, error) {
+	p, ok := SecretProviders[src.Provider]
+	if !ok {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("secret %v uses unknown secret provider %v", name, src.Provider),
+		}
+	}
+	value, err := p.GetSecret(namespace, src.Path, src.Key)
+	if err != nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't read secret %v from provider %v: %v", name, src.Provider, err),
+		}
+	}
+	return value, nil
+}
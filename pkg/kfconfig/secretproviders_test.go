@@ -0,0 +1,152 @@
+package kfconfig
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFileSecretProvider(t *testing.T) {
+	testDir := t.TempDir()
+	os.MkdirAll(path.Join(testDir, "secrets", "opendatahub", "db"), 0755)
+	os.MkdirAll(path.Join(testDir, "secrets", "other"), 0755)
+	ioutil.WriteFile(path.Join(testDir, "secrets", "opendatahub", "db", "password"), []byte("dbpassword\n"), 0600)
+	ioutil.WriteFile(path.Join(testDir, "secrets", "opendatahub", "token"), []byte("sometoken"), 0600)
+	ioutil.WriteFile(path.Join(testDir, "secrets", "other", "token"), []byte("othertoken"), 0600)
+	ioutil.WriteFile(path.Join(testDir, "outside"), []byte("outside"), 0600)
+	p := &FileSecretProvider{Dir: path.Join(testDir, "secrets")}
+
+	type testCase struct {
+		namespace   string
+		path        string
+		key         string
+		expected    string
+		expectError bool
+	}
+	testCases := []testCase{
+		{namespace: "opendatahub", path: "db", key: "password", expected: "dbpassword"},
+		{namespace: "opendatahub", path: "token", expected: "sometoken"},
+		{namespace: "opendatahub", path: "db", key: "missing", expectError: true},
+		{namespace: "opendatahub", path: "..", key: "outside", expectError: true},
+		{namespace: "opendatahub", path: "../other", key: "token", expectError: true},
+		{namespace: "opendatahub", path: "db/../..", key: "outside", expectError: true},
+		{namespace: "opendatahub", path: "", expectError: true},
+		{namespace: "..", path: "outside", expectError: true},
+		{namespace: "", path: "other/token", expectError: true},
+	}
+	for _, c := range testCases {
+		actual, err := p.GetSecret(c.namespace, c.path, c.key)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("%v/%v: expect an error, got %v", c.path, c.key, actual)
+			}
+			continue
+		}
+		if err != nil || actual != c.expected {
+			t.Errorf("%v/%v: expect %v, got %v (%v)", c.path, c.key, c.expected, actual, err)
+		}
+	}
+}
+
+func TestVaultSecretProvider(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" || r.Header.Get("X-Vault-Namespace") != "odh" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/opendatahub/db":
+			w.Write([]byte(`{"data": {"data": {"password": "v2password"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/opendatahub/db":
+			w.Write([]byte(`{"data": {"password": "v1password", "port": 5432}}`))
+		case "/v1/secret/data/other/db":
+			w.Write([]byte(`{"data": {"data": {"password": "otherpassword"}, "metadata": {"version": 1}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tokenFile := path.Join(t.TempDir(), "token")
+	ioutil.WriteFile(tokenFile, []byte("root\n"), 0600)
+	p := &VaultSecretProvider{Address: srv.URL + "/", TokenFile: tokenFile, Namespace: "odh"}
+
+	v1 := &VaultSecretProvider{Address: srv.URL, Mount: "kv", KVVersion: 1, TokenFile: tokenFile, Namespace: "odh"}
+
+	type testCase struct {
+		provider    *VaultSecretProvider
+		path        string
+		key         string
+		expected    string
+		expectError bool
+	}
+	testCases := []testCase{
+		{provider: p, path: "db", key: "password", expected: "v2password"},
+		{provider: p, path: "/db", key: "password", expected: "v2password"},
+		{provider: p, path: "../other/db", key: "password", expectError: true},
+		{provider: p, path: "..", key: "password", expectError: true},
+		{provider: v1, path: "db", key: "password", expected: "v1password"},
+		{provider: v1, path: "db", key: "port", expectError: true},
+		{provider: v1, path: "db", key: "missing", expectError: true},
+		{provider: v1, path: "missing", key: "password", expectError: true},
+	}
+	for _, c := range testCases {
+		actual, err := c.provider.GetSecret("opendatahub", c.path, c.key)
+		if c.expectError {
+			if err == nil {
+				t.Errorf("%v/%v: expect an error, got %v", c.path, c.key, actual)
+			}
+			continue
+		}
+		if err != nil || actual != c.expected {
+			t.Errorf("%v/%v: expect %v, got %v (%v)", c.path, c.key, c.expected, actual, err)
+		}
+	}
+
+	p.TokenFile = ""
+	p.Token = "other"
+	if _, err := p.GetSecret("opendatahub", "db", "password"); err == nil {
+		t.Errorf("expect an error with an invalid token")
+	}
+}
+
+func TestKfConfig_GetExternalSecret(t *testing.T) {
+	testDir := t.TempDir()
+	os.MkdirAll(path.Join(testDir, "opendatahub"), 0755)
+	ioutil.WriteFile(path.Join(testDir, "opendatahub", "password"), []byte("somepassword"), 0600)
+	ioutil.WriteFile(path.Join(testDir, "opendatahub", "hash"), []byte("somehash"), 0600)
+	defer func(providers map[string]SecretProvider) { SecretProviders = providers }(SecretProviders)
+	SecretProviders = map[string]SecretProvider{FileSecretProviderName: &FileSecretProvider{Dir: testDir}}
+
+	c := &KfConfig{ObjectMeta: metav1.ObjectMeta{Namespace: "opendatahub"}, Spec: KfConfigSpec{Secrets: []Secret{
+		{Name: "password", SecretSource: &SecretSource{
+			ExternalSource: &ExternalSource{Provider: FileSecretProviderName, Path: "password"},
+		}},
+		{Name: "hash", SecretSource: &SecretSource{
+			ExternalSource: &ExternalSource{Provider: FileSecretProviderName, Path: "hash", Hashed: true},
+		}},
+		{Name: "vault", SecretSource: &SecretSource{
+			ExternalSource: &ExternalSource{Provider: VaultSecretProviderName, Path: "db", Key: "password"},
+		}},
+	}}}
+
+	if value, err := c.GetSecret("password"); err != nil || value != "somepassword" {
+		t.Errorf("expect somepassword, got %v (%v)", value, err)
+	}
+	src, err := c.GetSecretSource("password")
+	if err != nil || src.LiteralSource == nil || src.LiteralSource.Value != "somepassword" {
+		t.Errorf("expect a literal source, got %+v (%v)", src, err)
+	}
+	src, err = c.GetSecretSource("hash")
+	if err != nil || src.HashedSource == nil || src.HashedSource.HashedValue != "somehash" {
+		t.Errorf("expect a hashed source, got %+v (%v)", src, err)
+	}
+	if _, err := c.GetSecret("vault"); err == nil {
+		t.Errorf("expect an error with a provider that isn't configured")
+	}
+}
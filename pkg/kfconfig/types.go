@@ -1,9 +1,6 @@
 package kfconfig
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
 	"fmt"
 	"github.com/ghodss/yaml"
 	"github.com/hashicorp/go-getter/helper/url"
@@ -12,7 +9,6 @@ import (
 	"github.com/otiai10/copy"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-	"io"
 	"io/ioutil"
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +19,7 @@ import (
 	"path/filepath"
 	"sigs.k8s.io/kustomize/v3/pkg/types"
 	"strings"
+	"time"
 )
 
 const (
@@ -76,18 +73,80 @@ type KfConfigSpec struct {
 	Plugins      []Plugin      `json:"plugins,omitempty"`
 	Secrets      []Secret      `json:"secrets,omitempty"`
 	Repos        []Repo        `json:"repos,omitempty"`
+	Export       *ExportSpec   `json:"export,omitempty"`
 }
 
+type ExportSpec struct {
+	Path                string `json:"path,omitempty"`
+	ConfigMap           string `json:"configMap,omitempty"`
+	Archive             string `json:"archive,omitempty"`
+	OperatorAnnotations bool   `json:"operatorAnnotations,omitempty"`
+}
+
+// ExportRoot is the directory of the operator filesystem the Path and Archive exports are written under,
+// in a subdirectory per namespace, configured by the operator. Exports to the filesystem are disabled when empty.
+var ExportRoot = ""
+
+// ChartRoot is the directory of the operator filesystem the charts of HelmConfig.ChartPath are read from,
+// configured by the operator. Charts of the operator filesystem are disabled when empty.
+var ChartRoot = ""
+
+// DefaultUserFieldManagers are the field managers of the changes made by users: kubectl, oc and the
+// OpenShift console, whose changes are managed by the user agent of the browser.
+var DefaultUserFieldManagers = []string{"kubectl", "oc", "Mozilla"}
+
+// UserFieldManagers are the field managers whose changes of the configurable resources are preserved, configured
+// by the operator. A field manager matches a name, or a name followed by "-" and a command, e.g. kubectl-edit.
+var UserFieldManagers = DefaultUserFieldManagers
+
 // Application defines an application to install
 type Application struct {
 	Name            string           `json:"name,omitempty"`
 	KustomizeConfig *KustomizeConfig `json:"kustomizeConfig,omitempty"`
+	HelmConfig      *HelmConfig      `json:"helmConfig,omitempty"`
 }
 
 type KustomizeConfig struct {
-	RepoRef    *RepoRef    `json:"repoRef,omitempty"`
-	Overlays   []string    `json:"overlays,omitempty"`
-	Parameters []NameValue `json:"parameters,omitempty"`
+	RepoRef               *RepoRef    `json:"repoRef,omitempty"`
+	Overlays              []string    `json:"overlays,omitempty"`
+	Parameters            []NameValue `json:"parameters,omitempty"`
+	PatchesStrategicMerge []string    `json:"patchesStrategicMerge,omitempty"`
+	PatchesJson6902       []JSONPatch `json:"patchesJson6902,omitempty"`
+	Images                []Image     `json:"images,omitempty"`
+}
+
+type JSONPatch struct {
+	Target PatchTarget `json:"target,omitempty"`
+	Patch  string      `json:"patch,omitempty"`
+}
+
+type PatchTarget struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+type Image struct {
+	Name    string `json:"name,omitempty"`
+	NewName string `json:"newName,omitempty"`
+	NewTag  string `json:"newTag,omitempty"`
+	Digest  string `json:"digest,omitempty"`
+}
+
+type HelmConfig struct {
+	RepoRef     *RepoRef              `json:"repoRef,omitempty"`
+	ChartPath   string                `json:"chartPath,omitempty"`
+	ReleaseName string                `json:"releaseName,omitempty"`
+	Namespace   string                `json:"namespace,omitempty"`
+	Values      *runtime.RawExtension `json:"values,omitempty"`
+	ValuesFrom  []HelmValuesReference `json:"valuesFrom,omitempty"`
+}
+
+type HelmValuesReference struct {
+	Name string `json:"name,omitempty"`
+	Key  string `json:"key,omitempty"`
 }
 
 type RepoRef struct {
@@ -96,8 +155,19 @@ type RepoRef struct {
 }
 
 type NameValue struct {
-	Name  string `json:"name,omitempty"`
-	Value string `json:"value,omitempty"`
+	Name      string           `json:"name,omitempty"`
+	Value     string           `json:"value,omitempty"`
+	ValueFrom *ParameterSource `json:"valueFrom,omitempty"`
+}
+
+type ParameterSource struct {
+	ConfigMapKeyRef *KeySelector `json:"configMapKeyRef,omitempty"`
+	SecretKeyRef    *KeySelector `json:"secretKeyRef,omitempty"`
+}
+
+type KeySelector struct {
+	Name string `json:"name,omitempty"`
+	Key  string `json:"key,omitempty"`
 }
 
 type Plugin struct {
@@ -115,9 +185,11 @@ type Secret struct {
 }
 
 type SecretSource struct {
-	LiteralSource *LiteralSource `json:"literalSource,omitempty"`
-	HashedSource  *HashedSource  `json:"hashedSource,omitempty"`
-	EnvSource     *EnvSource     `json:"envSource,omitempty"`
+	LiteralSource  *LiteralSource  `json:"literalSource,omitempty"`
+	HashedSource   *HashedSource   `json:"hashedSource,omitempty"`
+	EnvSource      *EnvSource      `json:"envSource,omitempty"`
+	SecretKeyRef   *KeySelector    `json:"secretKeyRef,omitempty"`
+	ExternalSource *ExternalSource `json:"externalSource,omitempty"`
 }
 
 type LiteralSource struct {
@@ -132,6 +204,13 @@ type EnvSource struct {
 	Name string `json:"name,omitempty"`
 }
 
+type ExternalSource struct {
+	Provider string `json:"provider,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Hashed   bool   `json:"hashed,omitempty"`
+}
+
 // SecretRef is a reference to a secret
 type SecretRef struct {
 	// Name of the secret
@@ -146,12 +225,42 @@ type Repo struct {
 	// URI where repository can be obtained.
 	// Can use any URI understood by go-getter:
 	// https://github.com/hashicorp/go-getter/blob/master/README.md#installation-and-usage
-	URI string `json:"uri,omitempty"`
+	URI           string         `json:"uri,omitempty"`
+	Ref           string         `json:"ref,omitempty"`
+	Tag           string         `json:"tag,omitempty"`
+	Commit        string         `json:"commit,omitempty"`
+	Sha256        string         `json:"sha256,omitempty"`
+	Signature     *RepoSignature `json:"signature,omitempty"`
+	AuthSecretRef *SecretRef     `json:"authSecretRef,omitempty"`
+	Channel       *RepoChannel   `json:"channel,omitempty"`
+}
+
+type RepoChannel struct {
+	IndexURI   string `json:"indexURI"`
+	Constraint string `json:"constraint,omitempty"`
+	Approval   string `json:"approval,omitempty"`
+}
+
+type RepoSignature struct {
+	Type               string      `json:"type"`
+	URI                string      `json:"uri,omitempty"`
+	PublicKeySecretRef KeySelector `json:"publicKeySecretRef"`
 }
 
 type Status struct {
-	Conditions []Condition `json:"conditions,omitempty"`
-	Caches     []Cache     `json:"caches,omitempty"`
+	Conditions []Condition         `json:"conditions,omitempty"`
+	Caches     []Cache             `json:"caches,omitempty"`
+	Channels   []RepoChannelStatus `json:"channels,omitempty"`
+}
+
+type RepoChannelStatus struct {
+	Name          string      `json:"name"`
+	Version       string      `json:"version,omitempty"`
+	URI           string      `json:"uri,omitempty"`
+	Sha256        string      `json:"sha256,omitempty"`
+	LatestVersion string      `json:"latestVersion,omitempty"`
+	LastPollTime  metav1.Time `json:"lastPollTime,omitempty"`
+	Message       string      `json:"message,omitempty"`
 }
 
 type Condition struct {
@@ -172,6 +281,8 @@ type Condition struct {
 type Cache struct {
 	Name      string `json:"name,omitempty"`
 	LocalPath string `json:"localPath,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	Version   string `json:"version,omitempty"`
 }
 
 type PluginKindType string
@@ -456,7 +567,24 @@ func (c *KfConfig) SyncCache() error {
 		}
 	}
 
+	// The entries of the repositories removed from the spec can be evicted
+	repos := []string{}
 	for _, r := range c.Spec.Repos {
+		repos = append(repos, r.Name)
+	}
+	SharedRepoCache.Retain(c.Namespace, c.Name, repos)
+
+	for _, r := range c.Spec.Repos {
+		r, err := c.channelRepo(r)
+		if err != nil {
+			return err
+		}
+		bundle := BundleMetadata{}
+		if IsBundledRepo(r) {
+			if r.URI, bundle, err = resolveBundledRepo(r.URI); err != nil {
+				return err
+			}
+		}
 		cacheDir := path.Join(baseCacheDir, r.Name)
 
 		// Can we use a checksum or other mechanism to verify if the existing location is good?
@@ -466,7 +594,10 @@ func (c *KfConfig) SyncCache() error {
 			shouldSkip := false
 			for _, cache := range c.Status.Caches {
 				if cache.Name == r.Name && cache.LocalPath != "" {
-					shouldSkip = true
+					// a git repository pinned to another commit is fetched again
+					shouldSkip = r.Commit == "" || strings.HasPrefix(cache.Commit, strings.ToLower(r.Commit))
+					// so is a bundled repository replaced by another version
+					shouldSkip = shouldSkip && cache.Version == bundle.Version
 					break
 				}
 			}
@@ -488,13 +619,7 @@ func (c *KfConfig) SyncCache() error {
 				return errors.WithStack(err)
 			}
 		}
-
-		u, err := url.Parse(r.URI)
-
-		if err != nil {
-			log.Errorf("Could not parse URI %v; error %v", r.URI, err)
-			return errors.WithStack(err)
-		}
+		c.removeCache(r.Name)
 
 		log.Infof("Fetching %v to %v", r.URI, cacheDir)
 		if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
@@ -503,7 +628,50 @@ func (c *KfConfig) SyncCache() error {
 		}
 
 		// Manifests are local dir
-		if fi, err := os.Stat(r.URI); err == nil && fi.Mode().IsDir() {
+		fi, statErr := os.Stat(r.URI)
+		isLocalDir := statErr == nil && fi.Mode().IsDir()
+		if (r.Sha256 != "" || r.Signature != nil) && (isLocalDir || IsGitRepo(r)) {
+			return &kfapis.KfError{
+				Code:    int(kfapis.INVALID_ARGUMENT),
+				Message: fmt.Sprintf("repo %v: sha256 and signature can only be verified for tarballs", r.Name),
+			}
+		}
+
+		auth, err := c.repoAuth(r)
+		if err != nil {
+			return err
+		}
+
+		if IsGitRepo(r) {
+			var commit string
+			if SharedRepoCache != nil {
+				commit, err = SharedRepoCache.Checkout(c.repoCacheOwner(), c.repoCacheScope(r), r, cacheDir,
+					func(dir string) (string, error) { return fetchGitRepo(r, dir, auth) })
+			} else {
+				commit, err = fetchGitRepo(r, cacheDir, auth)
+			}
+			if err != nil {
+				log.Errorf("Could not fetch git repository %v; error %v", r.URI, err)
+				return err
+			}
+			c.Status.Caches = append(c.Status.Caches, Cache{
+				Name:      r.Name,
+				LocalPath: cacheDir,
+				Commit:    commit,
+				Version:   bundle.Version,
+			})
+			log.Infof("Fetch succeeded; LocalPath %v, commit %v", cacheDir, commit)
+			continue
+		}
+
+		u, err := url.Parse(r.URI)
+
+		if err != nil {
+			log.Errorf("Could not parse URI %v; error %v", r.URI, err)
+			return errors.WithStack(err)
+		}
+
+		if isLocalDir {
 			// check whether the cache directory is a sub directory of manifests
 			absCacheDir, err := filepath.Abs(cacheDir)
 			if err != nil {
@@ -528,27 +696,20 @@ func (c *KfConfig) SyncCache() error {
 				return errors.WithStack(err)
 			}
 		} else {
-			t := &http.Transport{
-				Proxy: http.ProxyFromEnvironment,
+			var body []byte
+			if SharedRepoCache != nil {
+				body, err = SharedRepoCache.Tarball(c.repoCacheOwner(), c.repoCacheScope(r), r,
+					func() ([]byte, error) { return download(r.URI, auth) })
+			} else {
+				body, err = download(r.URI, auth)
 			}
-			t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
-			t.RegisterProtocol("", http.NewFileTransport(http.Dir("/")))
-			hclient := &http.Client{Transport: t}
-			req, _ := http.NewRequest("GET", r.URI, nil)
-			req.Header.Set("User-Agent", "kfctl")
-			resp, err := hclient.Do(req)
 			if err != nil {
-				return &kfapis.KfError{
-					Code:    int(kfapis.INVALID_ARGUMENT),
-					Message: fmt.Sprintf("couldn't download URI %v: %v", r.URI, err),
-				}
+				return err
 			}
-			defer resp.Body.Close()
-
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				log.Errorf("Could not read response body; error %v", err)
-				return errors.WithStack(err)
+			// The tarball is verified before anything is extracted from it.
+			if err := c.verifyRepo(r, body, auth); err != nil {
+				log.Errorf("Could not verify %v; error %v", r.URI, err)
+				return err
 			}
 			if err := untar(body, cacheDir); err != nil {
 				log.Errorf("Could not untar file %v; error %v", r.URI, err)
@@ -586,6 +747,7 @@ func (c *KfConfig) SyncCache() error {
 		c.Status.Caches = append(c.Status.Caches, Cache{
 			Name:      r.Name,
 			LocalPath: localPath,
+			Version:   bundle.Version,
 		})
 
 		log.Infof("Fetch succeeded; LocalPath %v", localPath)
@@ -593,52 +755,60 @@ func (c *KfConfig) SyncCache() error {
 	return nil
 }
 
-func untar(body []byte, cacheDir string) error {
-	gzf, err := gzip.NewReader(bytes.NewReader(body))
+// RepoFetchTimeout bounds the download or git fetch of a repository, configured by the operator, so an
+// unresponsive server doesn't block the reconciles. There is no limit when it's 0.
+var RepoFetchTimeout = 10 * time.Minute
+
+// download returns the content at uri, a HTTP(S) URL or a local file, with the credentials of auth,
+// which can be nil.
+func download(uri string, auth *RepoAuth) ([]byte, error) {
+	t := auth.Transport()
+	t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	t.RegisterProtocol("", http.NewFileTransport(http.Dir("/")))
+	hclient := &http.Client{Transport: t, Timeout: RepoFetchTimeout}
+	req, err := http.NewRequest("GET", uri, nil)
 	if err != nil {
-		return err
-	}
-
-	tarReader := tar.NewReader(gzf)
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
+		return nil, &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't download URI %v: %v", uri, err),
 		}
-		if err != nil {
-			return err
+	}
+	if req.URL.Scheme == "http" || req.URL.Scheme == "https" {
+		req.Header = auth.Header()
+	}
+	req.Header.Set("User-Agent", "kfctl")
+	resp, err := hclient.Do(req)
+	if err != nil {
+		return nil, &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't download URI %v: %v", uri, err),
 		}
-		if header == nil {
-			continue
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't download URI %v: %v", uri, resp.Status),
 		}
+	}
 
-		target := filepath.Join(cacheDir, header.Name)
-
-		switch header.Typeflag {
-
-		case tar.TypeDir:
-			if _, err := os.Stat(target); err != nil {
-				if err := os.MkdirAll(target, 0755); err != nil {
-					return err
-				}
-			}
-
-		case tar.TypeReg:
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return err
-			}
-
-			if _, err := io.Copy(f, tarReader); err != nil {
-				return err
-			}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("Could not read response body; error %v", err)
+		return nil, errors.WithStack(err)
+	}
+	return body, nil
+}
 
-			if err := f.Close(); err != nil {
-				return err
-			}
+// removeCache removes the status of the cache of the repository name.
+func (c *KfConfig) removeCache(name string) {
+	var caches []Cache
+	for _, cache := range c.Status.Caches {
+		if cache.Name != name {
+			caches = append(caches, cache)
 		}
 	}
-	return nil
+	c.Status.Caches = caches
 }
 
 // GetSecret returns the specified secret or an error if the secret isn't specified.
@@ -656,6 +826,12 @@ func (c *KfConfig) GetSecret(name string) (string, error) {
 		if s.SecretSource.EnvSource != nil {
 			return os.Getenv(s.SecretSource.EnvSource.Name), nil
 		}
+		if ref := s.SecretSource.SecretKeyRef; ref != nil {
+			return c.readSecretKey(name, ref)
+		}
+		if s.SecretSource.ExternalSource != nil {
+			return readExternalSecret(c.Namespace, name, s.SecretSource.ExternalSource)
+		}
 
 		return "", fmt.Errorf("No secret source provided for secret %v", name)
 	}
@@ -663,21 +839,52 @@ func (c *KfConfig) GetSecret(name string) (string, error) {
 }
 
 // GetSecretSource returns the SecretSource of the specified name or an error if the secret isn't specified.
+// The source of a secret read from a provider is its value, hashed or not.
 func (c *KfConfig) GetSecretSource(name string) (*SecretSource, error) {
 	for _, s := range c.Spec.Secrets {
-		if s.Name == name {
+		if s.Name != name {
+			continue
+		}
+		if s.SecretSource == nil || s.SecretSource.ExternalSource == nil {
 			return s.SecretSource, nil
 		}
+		value, err := readExternalSecret(c.Namespace, name, s.SecretSource.ExternalSource)
+		if err != nil {
+			return nil, err
+		}
+		if s.SecretSource.ExternalSource.Hashed {
+			return &SecretSource{HashedSource: &HashedSource{HashedValue: value}}, nil
+		}
+		return &SecretSource{LiteralSource: &LiteralSource{Value: value}}, nil
 	}
 	return nil, NewSecretNotFound(name)
 }
 
+// readSecretKey returns the value of the key of the Secret referenced by the secret name.
+func (c *KfConfig) readSecretKey(name string, ref *KeySelector) (string, error) {
+	data, err := SecretReader(c.Namespace, ref.Name)
+	value, ok := data[ref.Key]
+	if err == nil && !ok {
+		err = fmt.Errorf("key %v not found in Secret %v", ref.Key, ref.Name)
+	}
+	if err != nil {
+		return "", &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't read secret %v: %v", name, err),
+		}
+	}
+	return string(value), nil
+}
+
 // GetApplicationParameter gets the desired application parameter.
 func (c *KfConfig) GetApplicationParameter(appName string, paramName string) (string, bool) {
 	// First we check applications for an application with the specified name.
 	if c.Spec.Applications != nil {
 		for _, a := range c.Spec.Applications {
 			if a.Name == appName {
+				if a.KustomizeConfig == nil {
+					return "", false
+				}
 				return getParameter(a.KustomizeConfig.Parameters, paramName)
 			}
 		}
@@ -1055,6 +1262,7 @@ func setParameter(parameters []NameValue, paramName string, value string) []Name
 
 	parameters[pIndex].Name = paramName
 	parameters[pIndex].Value = value
+	parameters[pIndex].ValueFrom = nil
 
 	return parameters
 }
@@ -16,6 +16,7 @@ package kfconfig
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/ghodss/yaml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pkg/errors"
@@ -342,9 +343,35 @@ func TestKfConfig_GetSecret(t *testing.T) {
 						},
 					},
 				},
+				{
+					Name: "s3",
+					SecretSource: &SecretSource{
+						HashedSource: &HashedSource{
+							HashedValue: "somehash",
+						},
+					},
+				},
+				{
+					Name: "s4",
+					SecretSource: &SecretSource{
+						SecretKeyRef: &KeySelector{
+							Name: "credentials",
+							Key:  "password",
+						},
+					},
+				},
 			},
 		},
 	}
+	d.Namespace = "kubeflow"
+
+	defer func(f func(namespace, name string) (map[string][]byte, error)) { SecretReader = f }(SecretReader)
+	SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		if namespace != "kubeflow" || name != "credentials" {
+			return nil, fmt.Errorf("secret %v/%v not found", namespace, name)
+		}
+		return map[string][]byte{"password": []byte("somepassword")}, nil
+	}
 
 	type testCase struct {
 		SecretName    string
@@ -360,6 +387,14 @@ func TestKfConfig_GetSecret(t *testing.T) {
 			SecretName:    "s2",
 			ExpectedValue: "somesecret",
 		},
+		{
+			SecretName:    "s3",
+			ExpectedValue: "somehash",
+		},
+		{
+			SecretName:    "s4",
+			ExpectedValue: "somepassword",
+		},
 	}
 
 	os.Setenv("s2", "somesecret")
@@ -373,6 +408,11 @@ func TestKfConfig_GetSecret(t *testing.T) {
 			t.Errorf("Secret %v value doesn't match %v", c.SecretName, cmp.Diff(actual, c.ExpectedValue))
 		}
 	}
+
+	d.Spec.Secrets[3].SecretSource.SecretKeyRef.Key = "missing"
+	if _, err := d.GetSecret("s4"); err == nil {
+		t.Errorf("expect an error reading a missing key")
+	}
 }
 
 func TestKfConfig_SetSecret(t *testing.T) {
@@ -0,0 +1,262 @@
+package kfconfig
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// untarLimits bound the content extracted from a repository tarball.
+type untarLimits struct {
+	// MaxSize is the maximum total size of the entries, as declared by their headers.
+	MaxSize int64
+	// MaxEntries is the maximum number of entries.
+	MaxEntries int
+}
+
+// defaultUntarLimits are large enough for any manifests repository.
+var defaultUntarLimits = untarLimits{
+	MaxSize:    1 << 30,
+	MaxEntries: 100000,
+}
+
+// untar extracts the gzipped tarball body into cacheDir with the default limits.
+func untar(body []byte, cacheDir string) error {
+	return untarWithLimits(bytes.NewReader(body), cacheDir, defaultUntarLimits)
+}
+
+// untarWithLimits extracts the gzipped tarball r into dir. Directories, regular files and links are
+// extracted, other entries are skipped. It fails without writing outside of dir when an entry or the
+// target of a link isn't within dir, or when the tarball exceeds the limits. Entries are never written
+// through a symbolic link, and file modes are restricted to the permission bits without group and
+// other write access.
+func untarWithLimits(r io.Reader, dir string, limits untarLimits) error {
+	gzf, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzf.Close()
+
+	var size int64
+	entries := 0
+	symlinks := false
+	tarReader := tar.NewReader(gzf)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		entries++
+		if entries > limits.MaxEntries {
+			return fmt.Errorf("tarball has more than %v entries", limits.MaxEntries)
+		}
+		if header.Size < 0 || header.Size > limits.MaxSize-size {
+			return fmt.Errorf("tarball is larger than %v bytes", limits.MaxSize)
+		}
+		size += header.Size
+
+		name, err := localPath(header.Name)
+		if err != nil {
+			return fmt.Errorf("invalid tarball entry %q: %v", header.Name, err)
+		}
+		if name == "." {
+			continue
+		}
+		if err := checkNoSymlinks(dir, path.Dir(name)); err != nil {
+			return fmt.Errorf("invalid tarball entry %q: %v", header.Name, err)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if fi, err := os.Lstat(target); err == nil {
+				if !fi.IsDir() {
+					return fmt.Errorf("invalid tarball entry %q: not a directory", header.Name)
+				}
+				continue
+			}
+			if err := os.MkdirAll(target, dirMode(header)); err != nil {
+				return err
+			}
+
+		case tar.TypeReg, tar.TypeRegA:
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode(header))
+			if err != nil {
+				return err
+			}
+			// The reader doesn't return more than the declared size, CopyN checks it isn't shorter.
+			if _, err := io.CopyN(f, tarReader, header.Size); err != nil {
+				f.Close()
+				return fmt.Errorf("invalid tarball entry %q: %v", header.Name, err)
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+
+		case tar.TypeSymlink:
+			if path.IsAbs(header.Linkname) {
+				return fmt.Errorf("invalid tarball entry %q: absolute link target %q", header.Name, header.Linkname)
+			}
+			if _, err := localPath(path.Join(path.Dir(name), header.Linkname)); err != nil {
+				return fmt.Errorf("invalid tarball entry %q: link target %q: %v", header.Name, header.Linkname, err)
+			}
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+			symlinks = true
+
+		case tar.TypeLink:
+			linkname, err := localPath(header.Linkname)
+			if err != nil {
+				return fmt.Errorf("invalid tarball entry %q: link target %q: %v", header.Name, header.Linkname, err)
+			}
+			if err := checkNoSymlinks(dir, path.Dir(linkname)); err != nil {
+				return fmt.Errorf("invalid tarball entry %q: link target %q: %v", header.Name, header.Linkname, err)
+			}
+			source := filepath.Join(dir, filepath.FromSlash(linkname))
+			if fi, err := os.Lstat(source); err != nil || !fi.Mode().IsRegular() {
+				return fmt.Errorf("invalid tarball entry %q: link target %q isn't an extracted file", header.Name, header.Linkname)
+			}
+			if err := removeExisting(target); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+
+		default:
+			log.Infof("Skipping tarball entry %v of type %v", header.Name, header.Typeflag)
+		}
+	}
+	if symlinks {
+		return checkSymlinks(dir)
+	}
+	return nil
+}
+
+// checkSymlinks checks that the symbolic links under dir resolve within dir. Each link target is checked
+// when extracted, but a chain of links, or a link created later on the path of a target, can still escape.
+// An escaping or dangling link is removed.
+func checkSymlinks(dir string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			var rel string
+			if rel, err = filepath.Rel(root, resolved); err == nil && (rel == ".." || strings.HasPrefix(rel, "../")) {
+				err = fmt.Errorf("resolves to %v outside of the extraction directory", resolved)
+			}
+		}
+		if err != nil {
+			if removeErr := os.Remove(p); removeErr != nil {
+				log.Errorf("Could not remove invalid link %v; error %v", p, removeErr)
+			}
+			return fmt.Errorf("invalid link %v: %v", p, err)
+		}
+		return nil
+	})
+}
+
+// localPath returns the cleaned slash separated path name, checking it's relative and doesn't
+// refer to a parent directory.
+func localPath(name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("empty path")
+	}
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("absolute path")
+	}
+	if strings.Contains(name, `\`) {
+		return "", fmt.Errorf("backslash in path")
+	}
+	cleaned := path.Clean(name)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("path outside of the extraction directory")
+	}
+	return cleaned, nil
+}
+
+// checkNoSymlinks checks that none of the existing elements of the slash separated path rel
+// within dir is a symbolic link, so writing to rel can't escape dir.
+func checkNoSymlinks(dir string, rel string) error {
+	current := dir
+	for _, element := range strings.Split(rel, "/") {
+		if element == "." || element == "" {
+			continue
+		}
+		current = filepath.Join(current, element)
+		fi, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("path through symbolic link %v", element)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%v isn't a directory", element)
+		}
+	}
+	return nil
+}
+
+// removeExisting removes a file or link replaced by a later entry. Directories aren't replaced.
+func removeExisting(target string) error {
+	fi, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("%v is a directory", target)
+	}
+	return os.Remove(target)
+}
+
+func fileMode(header *tar.Header) os.FileMode {
+	return header.FileInfo().Mode().Perm()&0755 | 0600
+}
+
+func dirMode(header *tar.Header) os.FileMode {
+	return header.FileInfo().Mode().Perm()&0755 | 0700
+}
@@ -0,0 +1,184 @@
+package kfconfig
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type tarEntry struct {
+	name     string
+	typeflag byte
+	linkname string
+	mode     int64
+	content  string
+	size     int64
+}
+
+// newTarball returns a gzipped tarball of entries. The size of an entry defaults to the size of its content.
+func newTarball(t testing.TB, entries []tarEntry) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		mode := e.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		size := e.size
+		if size == 0 {
+			size = int64(len(e.content))
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: mode, Size: size}); err != nil {
+			t.Fatalf("Failed to write tarball: %v", err)
+		}
+		if e.content != "" {
+			if _, err := tw.Write([]byte(e.content)); err != nil {
+				t.Fatalf("Failed to write tarball: %v", err)
+			}
+		}
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// maliciousTarballs are rejected by untar.
+var maliciousTarballs = map[string][]tarEntry{
+	"parent-traversal": {
+		{name: "../evil", typeflag: tar.TypeReg, content: "evil"},
+	},
+	"nested-parent-traversal": {
+		{name: "manifests/../../evil", typeflag: tar.TypeReg, content: "evil"},
+	},
+	"absolute-path": {
+		{name: "/tmp/evil", typeflag: tar.TypeReg, content: "evil"},
+	},
+	"absolute-symlink": {
+		{name: "etc", typeflag: tar.TypeSymlink, linkname: "/etc"},
+	},
+	"escaping-symlink": {
+		{name: "manifests/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "manifests/up", typeflag: tar.TypeSymlink, linkname: "../.."},
+	},
+	"symlink-chain": {
+		{name: "manifests/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "manifests/root", typeflag: tar.TypeSymlink, linkname: ".."},
+		{name: "manifests/up", typeflag: tar.TypeSymlink, linkname: "root/.."},
+	},
+	"write-through-symlink": {
+		{name: "manifests/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "manifests"},
+		{name: "link/file", typeflag: tar.TypeReg, content: "evil"},
+	},
+	"escaping-hardlink": {
+		{name: "passwd", typeflag: tar.TypeLink, linkname: "../../etc/passwd"},
+	},
+	"hardlink-through-symlink": {
+		{name: "manifests/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "link", typeflag: tar.TypeSymlink, linkname: "manifests"},
+		{name: "file", typeflag: tar.TypeLink, linkname: "link/file"},
+	},
+	"too-many-entries": {
+		{name: "a", typeflag: tar.TypeReg}, {name: "b", typeflag: tar.TypeReg}, {name: "c", typeflag: tar.TypeReg},
+		{name: "d", typeflag: tar.TypeReg}, {name: "e", typeflag: tar.TypeReg}, {name: "f", typeflag: tar.TypeReg},
+	},
+	"too-large": {
+		{name: "a", typeflag: tar.TypeReg, content: strings.Repeat("a", 600)},
+		{name: "b", typeflag: tar.TypeReg, content: strings.Repeat("b", 600)},
+	},
+}
+
+var testUntarLimits = untarLimits{MaxSize: 1024, MaxEntries: 5}
+
+func TestUntar(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "cache")
+	tarball := newTarball(t, []tarEntry{
+		{name: "manifests/", typeflag: tar.TypeDir, mode: 0777},
+		{name: "manifests/kustomization.yaml", typeflag: tar.TypeReg, mode: 04777, content: "resources: []\n"},
+		{name: "manifests/base", typeflag: tar.TypeSymlink, linkname: "."},
+		{name: "manifests/copy.yaml", typeflag: tar.TypeLink, linkname: "manifests/kustomization.yaml"},
+		{name: "manifests/fifo", typeflag: tar.TypeFifo},
+	})
+	if err := untarWithLimits(bytes.NewReader(tarball), dir, testUntarLimits); err != nil {
+		t.Fatalf("Failed to untar: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "manifests", "base", "copy.yaml"))
+	if err != nil || string(data) != "resources: []\n" {
+		t.Errorf("expect the file through the link, got %q (%v)", string(data), err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "manifests", "kustomization.yaml"))
+	if err != nil || fi.Mode() != 0755 {
+		t.Errorf("expect mode 0755, got %v (%v)", fi.Mode(), err)
+	}
+	fi, err = os.Stat(filepath.Join(dir, "manifests"))
+	if err != nil || fi.Mode().Perm() != 0755 {
+		t.Errorf("expect directory mode 0755, got %v (%v)", fi.Mode(), err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "manifests", "fifo")); !os.IsNotExist(err) {
+		t.Errorf("expect the fifo to be skipped")
+	}
+
+	for name, entries := range maliciousTarballs {
+		dir := filepath.Join(parent, name)
+		if err := untarWithLimits(bytes.NewReader(newTarball(t, entries)), dir, testUntarLimits); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+		checkExtracted(t, parent, dir)
+	}
+}
+
+// checkExtracted checks that nothing was written outside of dir and the links under dir resolve within dir.
+func checkExtracted(t *testing.T, parent string, dir string) {
+	entries, err := os.ReadDir(parent)
+	if err != nil {
+		t.Fatalf("Failed to read %v: %v", parent, err)
+	}
+	for _, e := range entries {
+		if e.Name() != "evil" {
+			continue
+		}
+		t.Fatalf("%v was written outside of %v", filepath.Join(parent, e.Name()), dir)
+	}
+	root, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		t.Fatalf("Failed to resolve %v: %v", parent, err)
+	}
+	filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		resolved, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			t.Errorf("dangling link %v left", p)
+			return nil
+		}
+		if rel, _ := filepath.Rel(root, resolved); rel == ".." || strings.HasPrefix(rel, "../") ||
+			!strings.HasPrefix(resolved, filepath.Join(root, filepath.Base(dir))) {
+			t.Errorf("link %v resolves to %v outside of %v", p, resolved, dir)
+		}
+		return nil
+	})
+}
+
+func FuzzUntar(f *testing.F) {
+	f.Add(newTarball(f, []tarEntry{
+		{name: "manifests/", typeflag: tar.TypeDir, mode: 0755},
+		{name: "manifests/kustomization.yaml", typeflag: tar.TypeReg, content: "resources: []\n"},
+		{name: "manifests/base", typeflag: tar.TypeSymlink, linkname: "."},
+	}))
+	for _, entries := range maliciousTarballs {
+		f.Add(newTarball(f, entries))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parent := t.TempDir()
+		dir := filepath.Join(parent, "cache")
+		untarWithLimits(bytes.NewReader(data), dir, testUntarLimits)
+		checkExtracted(t, parent, dir)
+	})
+}
@@ -0,0 +1,135 @@
+package kfconfig
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	kfapis "github.com/opendatahub-io/opendatahub-operator/apis"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+)
+
+const (
+	// CosignSignature is a base64 encoded ASN.1 ECDSA signature of the SHA-256 digest, as produced by cosign sign-blob.
+	CosignSignature = "cosign"
+	// GPGSignature is an armored or binary OpenPGP detached signature.
+	GPGSignature = "gpg"
+)
+
+// RepoVerificationError is returned by SyncCache when a repository tarball doesn't match its checksum or signature.
+type RepoVerificationError struct {
+	Repo    string
+	Message string
+}
+
+func (e *RepoVerificationError) Error() string {
+	return fmt.Sprintf("verification of repo %v failed: %v", e.Repo, e.Message)
+}
+
+// verifyRepo checks the tarball of r against its checksum and signature, when set.
+// The signature is downloaded with the credentials of the repository.
+func (c *KfConfig) verifyRepo(r Repo, body []byte, auth *RepoAuth) error {
+	if r.Sha256 != "" {
+		sum := sha256.Sum256(body)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), strings.TrimPrefix(r.Sha256, "sha256:")) {
+			return &RepoVerificationError{
+				Repo:    r.Name,
+				Message: fmt.Sprintf("sha256 %x doesn't match %v", sum, r.Sha256),
+			}
+		}
+		log.Infof("Verified sha256 of repo %v", r.Name)
+	}
+	if r.Signature == nil {
+		return nil
+	}
+
+	ref := r.Signature.PublicKeySecretRef
+	data, err := SecretReader(c.Namespace, ref.Name)
+	key, ok := data[ref.Key]
+	if err == nil && !ok {
+		err = fmt.Errorf("key %v not found in Secret %v", ref.Key, ref.Name)
+	}
+	if err != nil {
+		return &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("couldn't read the public key of repo %v: %v", r.Name, err),
+		}
+	}
+	sigURI := r.Signature.URI
+	if sigURI == "" {
+		sigURI = r.URI + ".sig"
+	}
+	sig, err := download(sigURI, auth)
+	if err != nil {
+		return &RepoVerificationError{
+			Repo:    r.Name,
+			Message: fmt.Sprintf("couldn't download the signature: %v", err),
+		}
+	}
+
+	switch r.Signature.Type {
+	case CosignSignature:
+		err = verifyCosignSignature(body, sig, key)
+	case GPGSignature:
+		err = verifyGPGSignature(body, sig, key)
+	default:
+		return &kfapis.KfError{
+			Code:    int(kfapis.INVALID_ARGUMENT),
+			Message: fmt.Sprintf("repo %v: unknown signature type %v", r.Name, r.Signature.Type),
+		}
+	}
+	if err != nil {
+		return &RepoVerificationError{Repo: r.Name, Message: err.Error()}
+	}
+	log.Infof("Verified %v signature of repo %v", r.Signature.Type, r.Name)
+	return nil
+}
+
+// verifyCosignSignature verifies a cosign blob signature of body with a PEM encoded ECDSA public key.
+func verifyCosignSignature(body []byte, sig []byte, key []byte) error {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return fmt.Errorf("invalid public key: no PEM data found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %v", err)
+	}
+	ecdsaKey, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	digest := sha256.Sum256(body)
+	if !ecdsa.VerifyASN1(ecdsaKey, digest[:], raw) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// verifyGPGSignature verifies an OpenPGP detached signature of body with an armored public keyring.
+func verifyGPGSignature(body []byte, sig []byte, key []byte) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	if err != nil {
+		return fmt.Errorf("invalid public keyring: %v", err)
+	}
+	if bytes.Contains(sig, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(body), bytes.NewReader(sig))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(body), bytes.NewReader(sig))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return nil
+}
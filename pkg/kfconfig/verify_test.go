@@ -0,0 +1,164 @@
+package kfconfig
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+// testTarball returns a gzipped tarball holding a single file.
+func testTarball(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	content := []byte("manifests")
+	if err := tw.WriteHeader(&tar.Header{Name: "manifests/", Mode: 0755, Typeflag: tar.TypeDir}); err != nil {
+		t.Fatalf("Failed to write tarball: %v", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "manifests/file1", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatalf("Failed to write tarball: %v", err)
+	}
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+// cosignKeys returns a PEM encoded ECDSA public key and a function signing blobs as cosign sign-blob does.
+func cosignKeys(t *testing.T) ([]byte, func([]byte) []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	sign := func(blob []byte) []byte {
+		digest := sha256.Sum256(blob)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return []byte(base64.StdEncoding.EncodeToString(sig))
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), sign
+}
+
+// gpgKeys returns an armored public keyring and a function producing armored detached signatures.
+func gpgKeys(t *testing.T) ([]byte, func([]byte) []byte) {
+	entity, err := openpgp.NewEntity("manifests", "", "manifests@example.com", nil)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	keyring := &bytes.Buffer{}
+	w, err := armor.Encode(keyring, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("Failed to armor key: %v", err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatalf("Failed to serialize key: %v", err)
+	}
+	w.Close()
+	sign := func(blob []byte) []byte {
+		sig := &bytes.Buffer{}
+		if err := openpgp.ArmoredDetachSign(sig, entity, bytes.NewReader(blob), nil); err != nil {
+			t.Fatalf("Failed to sign: %v", err)
+		}
+		return sig.Bytes()
+	}
+	return keyring.Bytes(), sign
+}
+
+func TestSyncCacheVerification(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	tarball := testTarball(t)
+	tarballPath := path.Join(testDir, "manifests.tar.gz")
+	ioutil.WriteFile(tarballPath, tarball, 0644)
+	sum := sha256.Sum256(tarball)
+
+	cosignKey, cosignSign := cosignKeys(t)
+	otherKey, _ := cosignKeys(t)
+	gpgKeyring, gpgSign := gpgKeys(t)
+	ioutil.WriteFile(tarballPath+".sig", cosignSign(tarball), 0644)
+	ioutil.WriteFile(path.Join(testDir, "manifests.tar.gz.asc"), gpgSign(tarball), 0644)
+	ioutil.WriteFile(path.Join(testDir, "other.tar.gz.asc"), gpgSign([]byte("other")), 0644)
+
+	keys := map[string][]byte{
+		"cosign": cosignKey,
+		"other":  otherKey,
+		"gpg":    gpgKeyring,
+	}
+	defer func(f func(namespace, name string) (map[string][]byte, error)) { SecretReader = f }(SecretReader)
+	SecretReader = func(namespace, name string) (map[string][]byte, error) {
+		if namespace != "kubeflow" || name != "manifests-keys" {
+			return nil, fmt.Errorf("Secret %v/%v not found", namespace, name)
+		}
+		return keys, nil
+	}
+	signature := func(sigType, uri, key string) *RepoSignature {
+		return &RepoSignature{Type: sigType, URI: uri, PublicKeySecretRef: KeySelector{Name: "manifests-keys", Key: key}}
+	}
+
+	type testCase struct {
+		name            string
+		repo            Repo
+		expectVerifyErr bool
+		expectErr       bool
+	}
+	testCases := []testCase{
+		{name: "unverified", repo: Repo{}},
+		{name: "sha256", repo: Repo{Sha256: hex.EncodeToString(sum[:])}},
+		{name: "sha256-prefixed", repo: Repo{Sha256: "sha256:" + hex.EncodeToString(sum[:])}},
+		{name: "sha256-mismatch", repo: Repo{Sha256: hex.EncodeToString(make([]byte, 32))}, expectVerifyErr: true},
+		{name: "cosign", repo: Repo{Signature: signature(CosignSignature, "", "cosign")}},
+		{name: "cosign-other-key", repo: Repo{Signature: signature(CosignSignature, "", "other")}, expectVerifyErr: true},
+		{name: "cosign-missing-signature", repo: Repo{Signature: signature(CosignSignature, tarballPath+".missing", "cosign")}, expectVerifyErr: true},
+		{name: "gpg", repo: Repo{Signature: signature(GPGSignature, path.Join(testDir, "manifests.tar.gz.asc"), "gpg")}},
+		{name: "gpg-other-content", repo: Repo{Signature: signature(GPGSignature, path.Join(testDir, "other.tar.gz.asc"), "gpg")}, expectVerifyErr: true},
+		{name: "missing-key", repo: Repo{Signature: signature(CosignSignature, "", "missing")}, expectErr: true},
+		{name: "unknown-type", repo: Repo{Signature: signature("x509", "", "cosign")}, expectErr: true},
+	}
+	for _, c := range testCases {
+		appDir := path.Join(testDir, c.name)
+		c.repo.Name = "manifests"
+		c.repo.URI = "file:" + tarballPath
+		config := &KfConfig{Spec: KfConfigSpec{AppDir: appDir, Repos: []Repo{c.repo}}}
+		config.Namespace = "kubeflow"
+		err := config.SyncCache()
+
+		var verifyErr *RepoVerificationError
+		if errors.As(err, &verifyErr) != c.expectVerifyErr {
+			t.Errorf("%v: expect verification error %v, got %v", c.name, c.expectVerifyErr, err)
+		}
+		if (err != nil) != (c.expectVerifyErr || c.expectErr) {
+			t.Errorf("%v: unexpected error %v", c.name, err)
+		}
+		_, statErr := os.Stat(path.Join(appDir, DefaultCacheDir, "manifests", "manifests", "file1"))
+		if extracted := statErr == nil; extracted != (err == nil) {
+			t.Errorf("%v: expect extracted %v, got %v", c.name, err == nil, extracted)
+		}
+	}
+}
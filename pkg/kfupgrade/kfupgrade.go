@@ -57,8 +57,12 @@ func createNewKfApp(baseConfig string, version string, oldKfCfg *kfconfig.KfConf
 		}
 	}
 
-	// Load the new KfCfg from the base config
-	newKfCfg, err := kfconfigloaders.LoadConfigFromURI(baseConfig)
+	// Load the new KfCfg from the base config, with the credentials of the repository it's fetched from
+	auth, err := oldKfCfg.RepoAuthForURI(baseConfig)
+	if err != nil {
+		return nil, "", err
+	}
+	newKfCfg, err := kfconfigloaders.LoadConfigFromURIWithAuth(baseConfig, auth)
 	if err != nil {
 		return nil, "", &kfapis.KfError{
 			Code:    int(kfapis.INTERNAL_ERROR),
@@ -35,18 +35,15 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8stypes "k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/sets"
 	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
-	"k8s.io/cli-runtime/pkg/printers"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	kubectlapply "k8s.io/kubectl/pkg/cmd/apply"
-	kubectldelete "k8s.io/kubectl/pkg/cmd/delete"
+	"k8s.io/client-go/restmapper"
 	cmdutil "k8s.io/kubectl/pkg/cmd/util"
 	"math/rand"
 	netUrl "net/url"
-	"os"
 	"path"
 	"regexp"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -68,6 +65,8 @@ const (
 	SetAnnotation              = "set-kubeflow-annotation"
 	KfDefInstance              = "kfdef-instance"
 	InstallByOperator          = "install-by-operator"
+	// ApplyFieldManager is the field manager of the server-side apply of the manifests.
+	ApplyFieldManager = "application/apply-patch+yaml"
 )
 
 func generateRandStr(length int) string {
@@ -257,9 +256,10 @@ type Apply struct {
 	matchVersionKubeConfigFlags *cmdutil.MatchVersionFlags
 	factory                     cmdutil.Factory
 	clientset                   *kubernetes.Clientset
-	options                     *kubectlapply.ApplyOptions
-	tmpfile                     *os.File
-	stdin                       *os.File
+	// clients of ApplyObject, created on first use
+	dynamicClient    dynamic.Interface
+	mapper           *restmapper.DeferredDiscoveryRESTMapper
+	defaultNamespace string
 }
 
 func NewApply(namespace string, restConfig *rest.Config) (*Apply, error) {
@@ -306,101 +306,76 @@ func (a *Apply) IfNamespaceExist(name string) bool {
 	return true
 }
 
-func (a *Apply) Apply(data []byte) error {
-	a.tmpfile = a.tempFile(data)
-	a.stdin = os.Stdin
-	os.Stdin = a.tmpfile
-	defer a.cleanup()
-	ioStreams := genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr}
-	a.options = kubectlapply.NewApplyOptions(ioStreams)
-	deleteFlags := a.deleteFlags("that contains the configuration to apply")
-	dynamicClient, clierr := a.factory.DynamicClient()
-	if clierr != nil {
-		fmt.Printf("error in client %v\n", clierr)
-		return clierr
-	}
-	a.options.DeleteOptions, clierr = deleteFlags.ToOptions(dynamicClient, ioStreams)
-	if clierr != nil {
-		fmt.Printf("error in delete options %v\n", clierr)
-		return clierr
-	}
-	a.options.FieldManager = "application/apply-patch+yaml" // This is a required field for server-side apply
-	a.options.ServerSideApply = true
-	// This is required to apply aggregated cluster roles :
-	// https://kubernetes.io/docs/reference/access-authn-authz/rbac/#aggregated-clusterroles
-	a.options.ForceConflicts = true
-	initializeErr := a.init()
-	if initializeErr != nil {
+// ApplyObject applies a single object with server-side apply, with the ApplyFieldManager field manager. Namespaced objects without a namespace are applied
+// to the namespace of the kubeconfig context.
+func (a *Apply) ApplyObject(obj *unstructured.Unstructured) error {
+	if err := a.initObjectClients(); err != nil {
 		return &kfapis.KfError{
 			Code:    int(kfapis.INTERNAL_ERROR),
-			Message: fmt.Sprintf("could not initialize : %v", initializeErr),
+			Message: fmt.Sprintf("could not initialize : %v", err),
 		}
 	}
-	var err error
-	func() {
-		defer func() {
-			if temp := recover(); temp != nil {
-				err = temp.(error)
-			}
-		}()
-		err = a.run()
-	}()
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// the kind may be defined by a CustomResourceDefinition applied since discovery was cached
+		a.mapper.Reset()
+		mapping, err = a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return &kfapis.KfError{
+			Code:    int(kfapis.INTERNAL_ERROR),
+			Message: fmt.Sprintf("couldn't find resource of %v %v: %v", gvk.Kind, obj.GetName(), err),
+		}
+	}
+	var resource dynamic.ResourceInterface = a.dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = a.defaultNamespace
+		}
+		resource = a.dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+	data, err := obj.MarshalJSON()
 	if err != nil {
 		return err
 	}
-	return nil
-}
-
-func (a *Apply) run() error {
-	resourcesErr := a.options.Run()
-	if resourcesErr != nil {
+	// Forcing the conflicts is required to apply aggregated cluster roles :
+	// https://kubernetes.io/docs/reference/access-authn-authz/rbac/#aggregated-clusterroles
+	force := true
+	_, err = resource.Patch(context.TODO(), obj.GetName(), k8stypes.ApplyPatchType, data, metav1.PatchOptions{
+		FieldManager: ApplyFieldManager,
+		Force:        &force,
+	})
+	if err != nil {
 		return &kfapis.KfError{
 			Code:    int(kfapis.INTERNAL_ERROR),
-			Message: fmt.Sprintf("Apply.Run : %v", resourcesErr),
+			Message: fmt.Sprintf("couldn't apply %v %v: %v", gvk.Kind, obj.GetName(), err),
 		}
 	}
+	log.Infof("%v %v serverside-applied", strings.ToLower(gvk.Kind), obj.GetName())
 	return nil
 }
 
-func (a *Apply) cleanup() error {
-	os.Stdin = a.stdin
-	if a.tmpfile != nil {
-		if err := a.tmpfile.Close(); err != nil {
-			return err
-		}
-		return os.Remove(a.tmpfile.Name())
+func (a *Apply) initObjectClients() error {
+	if a.dynamicClient != nil {
+		return nil
 	}
-	return nil
-}
-
-func (a *Apply) init() error {
-	var err error
-	var o = a.options
-	var f = a.factory
-	// allow for a success message operation to be specified at print time
-	o.ToPrinter = func(operation string) (printers.ResourcePrinter, error) {
-		o.PrintFlags.NamePrintFlags.Operation = operation
-		return o.PrintFlags.ToPrinter()
-	}
-
-	o.OpenAPIPatch = true
-	o.OpenAPISchema, _ = f.OpenAPISchema()
-	//o.Validator, err = f.Validator(o.ValidationDirective, false)
-	o.Builder = f.NewBuilder()
-	o.Mapper, err = f.ToRESTMapper()
+	dynamicClient, err := a.factory.DynamicClient()
 	if err != nil {
 		return err
 	}
-	o.DynamicClient, err = f.DynamicClient()
+	discoveryClient, err := a.factory.ToDiscoveryClient()
 	if err != nil {
 		return err
 	}
-	o.Namespace, o.EnforceNamespace, err = f.ToRawKubeConfigLoader().Namespace()
+	namespace, _, err := a.factory.ToRawKubeConfigLoader().Namespace()
 	if err != nil {
 		return err
 	}
-	o.VisitedNamespaces = sets.NewString()
-	o.VisitedUids = sets.NewString()
+	a.mapper = restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	a.defaultNamespace = namespace
+	a.dynamicClient = dynamicClient
 	return nil
 }
 
@@ -475,49 +450,6 @@ func (a *Apply) namespace(namespace string) error {
 	return nil
 }
 
-func (a *Apply) tempFile(data []byte) *os.File {
-	tmpfile, err := ioutil.TempFile("/tmp", "kout")
-	if err != nil {
-		log.Fatal(err)
-	}
-	if _, err := tmpfile.Write(data); err != nil {
-		log.Fatal(err)
-	}
-	if _, err := tmpfile.Seek(0, 0); err != nil {
-		log.Fatal(err)
-	}
-	return tmpfile
-}
-
-func (a *Apply) deleteFlags(usage string) *kubectldelete.DeleteFlags {
-	gracePeriod := -1
-	// setup command defaults
-	all := false
-	force := false
-	ignoreNotFound := false
-	now := false
-	output := ""
-	labelSelector := ""
-	fieldSelector := ""
-	timeout := time.Duration(0)
-	wait := true
-	filenames := []string{a.tmpfile.Name()}
-	recursive := false
-	return &kubectldelete.DeleteFlags{
-		FileNameFlags:  &genericclioptions.FileNameFlags{Usage: usage, Filenames: &filenames, Recursive: &recursive},
-		LabelSelector:  &labelSelector,
-		FieldSelector:  &fieldSelector,
-		GracePeriod:    &gracePeriod,
-		All:            &all,
-		Force:          &force,
-		IgnoreNotFound: &ignoreNotFound,
-		Now:            &now,
-		Timeout:        &timeout,
-		Wait:           &wait,
-		Output:         &output,
-	}
-}
-
 // DeleteResource removes resource. Prior to that it checks whether the resource is created through the kubeflow operator.
 // always removes the resource if it is not created by the Kubeflow operator, otherwise checks the annotation to
 // be sure the resource is part of the deployment and then remove.